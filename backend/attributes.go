@@ -0,0 +1,149 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Attribute keys with a meaning of their own. Aggregators serve these back as
+// response headers, which is how downloads recover the type and file name.
+const (
+	AttributeContentType        = "content-type"
+	AttributeContentDisposition = "content-disposition"
+)
+
+// BlobAttributes combines a content type, original file name and free-form
+// tags into the attribute set stored with a blob. Empty values are skipped.
+func BlobAttributes(contentType, filename string, tags map[string]string) map[string]string {
+	attrs := make(map[string]string, len(tags)+2)
+	for key, value := range tags {
+		attrs[key] = value
+	}
+	if contentType != "" {
+		attrs[AttributeContentType] = contentType
+	}
+	if filename != "" {
+		attrs[AttributeContentDisposition] = mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	}
+	return attrs
+}
+
+// ParseTags turns "key=value" strings into a tag map
+func ParseTags(specs []string) (map[string]string, error) {
+	tags := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag %q (expected key=value)", spec)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// filenameFromDisposition extracts the filename parameter of a
+// Content-Disposition header, if there is one
+func filenameFromDisposition(header string) string {
+	if header == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+	return params["filename"]
+}
+
+// transportHeaders are response headers that HTTP, the aggregator's server or
+// a proxy in front of it set, as opposed to blob attributes
+var transportHeaders = map[string]bool{
+	"accept-ranges": true, "age": true, "alt-svc": true, "connection": true,
+	"content-length": true, "content-range": true, "date": true, "etag": true,
+	"keep-alive": true, "last-modified": true, "server": true,
+	"strict-transport-security": true, "transfer-encoding": true, "vary": true,
+	"via": true, "x-content-type-options": true,
+}
+
+// attributeHeaders returns the blob attributes an aggregator served back as
+// response headers, keyed in lower case. Which attributes come back is up to
+// the aggregator, which only serves the headers it is configured to allow.
+// The content type and file name have fields of their own and are left out.
+func attributeHeaders(header http.Header) map[string]string {
+	var attrs map[string]string
+	for name, values := range header {
+		key := strings.ToLower(name)
+		if len(values) == 0 || transportHeaders[key] || strings.HasPrefix(key, "access-control-") ||
+			key == AttributeContentType || key == AttributeContentDisposition {
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		attrs[key] = values[0]
+	}
+	return attrs
+}
+
+// sourceAttributes is the attribute set for a blob copied from S3: the
+// object's content type, name, cache control, user metadata and tags, plus
+// where it was copied from, and how it was compressed and encrypted
+func sourceAttributes(name string, entry SimpleFileEntry) map[string]string {
+	attrs := BlobAttributes(entry.ContentType, path.Base(name), entry.Tags)
+	if entry.Compression != nil {
+		attrs[AttributeCompression] = entry.Compression.Codec
+	}
+	if entry.Encryption != nil {
+		attrs["encryption"] = entry.Encryption.Scheme
+		attrs["encryption-key-id"] = entry.Encryption.KeyID
+	}
+	source := entry.Source
+	if source == nil {
+		return attrs
+	}
+	if source.CacheControl != "" {
+		attrs["cache-control"] = source.CacheControl
+	}
+	for key, value := range source.Metadata {
+		attrs["x-amz-meta-"+key] = value
+	}
+	attrs["s3-source"] = "s3://" + source.Bucket + "/" + source.Key
+	if source.VersionID != "" {
+		attrs["s3-version-id"] = source.VersionID
+	}
+	return attrs
+}
+
+// OwnsStoredBlobs reports whether stored blobs end up owned by the
+// configured wallet: uploads go through a relay, or publishers are told to
+// send blob objects to wallet.address. Otherwise the publisher keeps them.
+func (c *WalrusClient) OwnsStoredBlobs() bool {
+	return (c.UseUploadRelay && c.UploadRelayURL != "") || c.SendObjectTo != ""
+}
+
+// CanSetAttributes reports whether attributes can be attached to stored
+// blobs, which takes owning them
+func (c *WalrusClient) CanSetAttributes() bool {
+	return c.OwnsStoredBlobs()
+}
+
+// SetBlobAttributes attaches attributes to the blob object with the given
+// Sui object ID. The configured wallet must own the object.
+func (c *WalrusClient) SetBlobAttributes(objectID string, attrs map[string]string) error {
+	return c.SetBlobAttributesContext(context.Background(), objectID, attrs)
+}
+
+// SetBlobAttributesContext is SetBlobAttributes bound to ctx
+func (c *WalrusClient) SetBlobAttributesContext(ctx context.Context, objectID string, attrs map[string]string) error {
+	if objectID == "" {
+		return fmt.Errorf("no blob object to attach attributes to (blob was already certified by someone else?)")
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	return c.walrusCLI().SetBlobAttributes(ctx, objectID, attrs)
+}
@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestBlobAttributes(t *testing.T) {
+	attrs := BlobAttributes("application/pdf", "report 1.pdf", map[string]string{"project": "apollo"})
+	want := map[string]string{
+		"project":                   "apollo",
+		AttributeContentType:        "application/pdf",
+		AttributeContentDisposition: `attachment; filename="report 1.pdf"`,
+	}
+	if !reflect.DeepEqual(attrs, want) {
+		t.Fatalf("BlobAttributes = %v, want %v", attrs, want)
+	}
+	if name := filenameFromDisposition(attrs[AttributeContentDisposition]); name != "report 1.pdf" {
+		t.Fatalf("file name = %q", name)
+	}
+}
+
+func TestGetBlobStatusReadsAttributes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Type", "application/pdf")
+		h.Set("Content-Disposition", `attachment; filename="report.pdf"`)
+		h.Set("Content-Language", "en")
+		h.Set("Project", "apollo")
+		h.Set("Access-Control-Allow-Origin", "*")
+		h.Set("Content-Length", "1234")
+		h.Set("Etag", `"abc"`)
+	}))
+	defer srv.Close()
+
+	info, err := NewWalrusClient(srv.URL, srv.URL).GetBlobStatusContext(context.Background(), "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ContentType != "application/pdf" || info.Identifier != "report.pdf" || info.Size != 1234 {
+		t.Fatalf("info = %+v", info)
+	}
+	if want := map[string]string{"content-language": "en", "project": "apollo"}; !reflect.DeepEqual(info.Tags, want) {
+		t.Fatalf("Tags = %v, want %v", info.Tags, want)
+	}
+}
@@ -1,6 +1,7 @@
 package backend
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -34,15 +35,35 @@ func NewBlobIndexerService(suiRPCURL, walrusAggregatorURL, walrusPublisherURL st
 	}
 }
 
+// SetWalrusClient replaces the Walrus client used for availability checks,
+// e.g. with one that fails over between several aggregators
+func (bis *BlobIndexerService) SetWalrusClient(client *WalrusClient) {
+	bis.walrusClient = client
+}
+
+// SetRetryPolicy applies a retry policy to both the Sui and Walrus clients
+func (bis *BlobIndexerService) SetRetryPolicy(policy RetryPolicy) {
+	bis.suiClient.Retry = policy
+	bis.walrusClient.Retry = policy
+}
+
 // GetUserBlobs fetches all blobs owned by a user address
 func (bis *BlobIndexerService) GetUserBlobs(userAddress string) ([]IndexedBlob, error) {
+	return bis.GetUserBlobsContext(context.Background(), userAddress)
+}
+
+// GetUserBlobsContext is GetUserBlobs bound to ctx
+func (bis *BlobIndexerService) GetUserBlobsContext(ctx context.Context, userAddress string) ([]IndexedBlob, error) {
 	if userAddress == "" {
 		return nil, fmt.Errorf("user address is required")
 	}
 
 	// Fetch Walrus blob objects from Sui blockchain
-	walrusObjects, err := bis.suiClient.GetWalrusBlobsForAddress(userAddress)
+	walrusObjects, err := bis.suiClient.GetWalrusBlobsForAddressContext(ctx, userAddress)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		// If we can't fetch from Sui, return empty list rather than error
 		// This allows the app to still function even if Sui indexing fails
 		return []IndexedBlob{}, nil
@@ -50,6 +71,10 @@ func (bis *BlobIndexerService) GetUserBlobs(userAddress string) ([]IndexedBlob,
 
 	var indexedBlobs []IndexedBlob
 	for _, obj := range walrusObjects {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		blob := IndexedBlob{
 			BlobID:        obj.BlobID,
 			SuiObjectID:   obj.ObjectID,
@@ -63,7 +88,7 @@ func (bis *BlobIndexerService) GetUserBlobs(userAddress string) ([]IndexedBlob,
 		}
 
 		// Check if blob is still available on Walrus
-		if blobInfo, err := bis.walrusClient.GetBlobStatus(obj.BlobID); err == nil {
+		if blobInfo, err := bis.walrusClient.GetBlobStatusContext(ctx, obj.BlobID); err == nil {
 			blob.Available = true
 			blob.ContentType = blobInfo.ContentType
 			blob.Identifier = blobInfo.Identifier
@@ -77,7 +102,12 @@ func (bis *BlobIndexerService) GetUserBlobs(userAddress string) ([]IndexedBlob,
 
 // SearchBlobs searches for blobs by criteria
 func (bis *BlobIndexerService) SearchBlobs(userAddress string, query string) ([]IndexedBlob, error) {
-	allBlobs, err := bis.GetUserBlobs(userAddress)
+	return bis.SearchBlobsContext(context.Background(), userAddress, query)
+}
+
+// SearchBlobsContext is SearchBlobs bound to ctx
+func (bis *BlobIndexerService) SearchBlobsContext(ctx context.Context, userAddress string, query string) ([]IndexedBlob, error) {
+	allBlobs, err := bis.GetUserBlobsContext(ctx, userAddress)
 	if err != nil {
 		return nil, err
 	}
@@ -98,8 +128,13 @@ func (bis *BlobIndexerService) SearchBlobs(userAddress string, query string) ([]
 
 // GetBlobDetails fetches detailed information about a specific blob
 func (bis *BlobIndexerService) GetBlobDetails(blobID string) (*IndexedBlob, error) {
+	return bis.GetBlobDetailsContext(context.Background(), blobID)
+}
+
+// GetBlobDetailsContext is GetBlobDetails bound to ctx
+func (bis *BlobIndexerService) GetBlobDetailsContext(ctx context.Context, blobID string) (*IndexedBlob, error) {
 	// Get blob info from Walrus
-	blobInfo, err := bis.walrusClient.GetBlobStatus(blobID)
+	blobInfo, err := bis.walrusClient.GetBlobStatusContext(ctx, blobID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get blob info: %w", err)
 	}
@@ -119,8 +154,17 @@ func (bis *BlobIndexerService) GetBlobDetails(blobID string) (*IndexedBlob, erro
 
 // RefreshBlobStatus refreshes the availability status of blobs
 func (bis *BlobIndexerService) RefreshBlobStatus(blobs []IndexedBlob) []IndexedBlob {
+	return bis.RefreshBlobStatusContext(context.Background(), blobs)
+}
+
+// RefreshBlobStatusContext is RefreshBlobStatus bound to ctx. Blobs not yet
+// checked when ctx is cancelled keep their previous status.
+func (bis *BlobIndexerService) RefreshBlobStatusContext(ctx context.Context, blobs []IndexedBlob) []IndexedBlob {
 	for i, blob := range blobs {
-		if _, err := bis.walrusClient.GetBlobStatus(blob.BlobID); err == nil {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := bis.walrusClient.GetBlobStatusContext(ctx, blob.BlobID); err == nil {
 			blobs[i].Available = true
 		} else {
 			blobs[i].Available = false
@@ -0,0 +1,379 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// ChunkManifestFormat identifies the manifest blobs of chunked objects
+const ChunkManifestFormat = "walrus-cli-chunked-v1"
+
+// DefaultMaxBlobSize is the largest object stored as a single blob when
+// WalrusClient.MaxBlobSize is not set. It stays below the network's limit of
+// about 13.6 GiB; many public publishers accept far less.
+const DefaultMaxBlobSize = 10 << 30
+
+// ChunkManifest is the blob that stands for an object stored in parts. It
+// lists the part blobs in order with the size and SHA-256 of each.
+type ChunkManifest struct {
+	Format     string      `json:"format"`
+	Size       int64       `json:"size"`                 // Object size, before any encryption
+	PartSize   int64       `json:"part_size,omitempty"`  // Object bytes per part; the last part may be shorter
+	Chunking   string      `json:"chunking,omitempty"`   // Set when parts are content-defined and vary in size
+	Encryption string      `json:"encryption,omitempty"` // Scheme each part is encrypted with on its own
+	Parts      []ChunkPart `json:"parts"`
+}
+
+// ChunkPart is one part blob of a chunked object
+type ChunkPart struct {
+	BlobID      string `json:"blob_id"`
+	Size        int64  `json:"size"`   // Stored size of the blob
+	SHA256      string `json:"sha256"` // Of the stored bytes
+	SuiObjectID string `json:"sui_object_id,omitempty"`
+}
+
+// ChunkedUpload is an object to store in parts
+type ChunkedUpload struct {
+	Size     int64
+	Open     func(offset, length int64) (io.ReadCloser, error) // Opens length bytes of the object from offset
+	Encrypt  *EncryptionKey                                    // Encrypt every part on its own
+	Progress *progressbar.ProgressBar                          // Fed object bytes as they go out
+}
+
+// NeedsChunking reports whether an object of size bytes is too large to be
+// stored as one blob
+func (c *WalrusClient) NeedsChunking(size int64) bool {
+	return size > c.maxBlobSize()
+}
+
+func (c *WalrusClient) maxBlobSize() int64 {
+	if c.MaxBlobSize > 0 {
+		return c.MaxBlobSize
+	}
+	return DefaultMaxBlobSize
+}
+
+// PartCount returns how many parts an object of size bytes is stored in
+func (c *WalrusClient) PartCount(size int64, encrypted bool) int64 {
+	partSize := c.partSize(encrypted)
+	if partSize <= 0 {
+		return 0
+	}
+	return (size + partSize - 1) / partSize
+}
+
+// partSize is how many object bytes go into a part, leaving room for the
+// encryption overhead when parts are encrypted
+func (c *WalrusClient) partSize(encrypted bool) int64 {
+	limit := c.maxBlobSize()
+	if !encrypted {
+		return limit
+	}
+	return limit - encryptionHeaderSize - (limit/encryptionChunkSize+1)*encryptionTagSize
+}
+
+// StoreChunkedContext stores an object too large for one blob: it is split
+// into parts of at most the max blob size, each stored as its own blob, then
+// a manifest blob listing them is stored. The response is the manifest's;
+// its blob ID stands for the whole object. Parts are stored one at a time
+// with the same options as the manifest, but attributes only go on the
+// manifest. A failed part leaves the parts before it stored until they expire.
+func (c *WalrusClient) StoreChunkedContext(ctx context.Context, upload ChunkedUpload, opts StoreOptions) (*StoreResponse, *ChunkManifest, error) {
+	partSize := c.partSize(upload.Encrypt != nil)
+	if partSize <= 0 {
+		return nil, nil, fmt.Errorf("max blob size of %d bytes is too small to store parts", c.maxBlobSize())
+	}
+
+	manifest := &ChunkManifest{Format: ChunkManifestFormat, Size: upload.Size, PartSize: partSize}
+	if upload.Encrypt != nil {
+		manifest.Encryption = EncryptionScheme
+	}
+	count := c.PartCount(upload.Size, upload.Encrypt != nil)
+
+	partOpts := opts
+	partOpts.Attributes = nil
+	for offset := int64(0); offset < upload.Size; offset += partSize {
+		part, _, err := c.storePart(ctx, upload, offset, min(partSize, upload.Size-offset), partOpts)
+		if err != nil {
+			return nil, manifest, fmt.Errorf("storing part %d of %d: %w", len(manifest.Parts)+1, count, err)
+		}
+		manifest.Parts = append(manifest.Parts, *part)
+	}
+	return c.storeManifest(ctx, manifest, opts)
+}
+
+// storeManifest stores manifest as a blob of its own
+func (c *WalrusClient) storeManifest(ctx context.Context, manifest *ChunkManifest, opts StoreOptions) (*StoreResponse, *ChunkManifest, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, manifest, err
+	}
+	open := func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	resp, err := c.StoreBlobWithOptionsContext(ctx, open, int64(len(data)), opts)
+	if err != nil && resp == nil {
+		return nil, manifest, fmt.Errorf("storing manifest: %w", err)
+	}
+	return resp, manifest, err
+}
+
+// storePart stores length bytes of the object from offset as one blob,
+// hashing what is sent. A retry re-opens the part and takes back the
+// progress it reported.
+func (c *WalrusClient) storePart(ctx context.Context, upload ChunkedUpload, offset, length int64, opts StoreOptions) (*ChunkPart, *StoreResponse, error) {
+	size := length
+	if upload.Encrypt != nil {
+		size = EncryptedSize(length)
+	}
+
+	var sum hash.Hash
+	var reported int64
+	open := func() (io.ReadCloser, error) {
+		if reported > 0 {
+			upload.Progress.Add64(-reported)
+			reported = 0
+		}
+		body, err := upload.Open(offset, length)
+		if err != nil {
+			return nil, err
+		}
+		if upload.Progress != nil {
+			body = &progressBody{ReadCloser: body, bar: upload.Progress, reported: &reported}
+		}
+		var r io.Reader = body
+		if upload.Encrypt != nil {
+			if r, err = NewEncryptingReader(body, upload.Encrypt); err != nil {
+				body.Close()
+				return nil, err
+			}
+		}
+		sum = sha256.New()
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(r, sum), body}, nil
+	}
+
+	resp, err := c.StoreBlobWithOptionsContext(ctx, open, size, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ChunkPart{
+		BlobID:      resp.BlobID,
+		Size:        size,
+		SHA256:      hex.EncodeToString(sum.Sum(nil)),
+		SuiObjectID: resp.SuiObjectID,
+	}, resp, nil
+}
+
+// FetchChunkManifestContext reads the manifest blob of a chunked object
+func (c *WalrusClient) FetchChunkManifestContext(ctx context.Context, blobID string) (*ChunkManifest, error) {
+	data, err := c.RetrieveBlobContext(ctx, blobID)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	var manifest ChunkManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Format != ChunkManifestFormat {
+		return nil, fmt.Errorf("blob %s is not a chunk manifest", blobID)
+	}
+	return &manifest, nil
+}
+
+// RetrieveChunkedToContext streams a chunked object into w, checking every
+// part against the manifest. Encrypted parts are decrypted with key.
+func (c *WalrusClient) RetrieveChunkedToContext(ctx context.Context, manifestID string, w io.Writer, key *EncryptionKey) (int64, error) {
+	manifest, err := c.FetchChunkManifestContext(ctx, manifestID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := c.copyParts(ctx, manifest, 0, w, key, nil)
+	if err == nil && n != manifest.Size {
+		err = fmt.Errorf("reassembled %d bytes, manifest says %d", n, manifest.Size)
+	}
+	return n, err
+}
+
+// DownloadChunkedToFileContext reassembles a chunked object into path,
+// staged in a .part file like DownloadBlobToFileContext. Resuming keeps the
+// leading parts of the .part file that still match their hashes. Ranges are
+// not supported.
+func (c *WalrusClient) DownloadChunkedToFileContext(ctx context.Context, manifestID, path string, opts DownloadOptions) (int64, error) {
+	if opts.Range != nil {
+		return 0, errors.New("a chunked object can only be downloaded whole, without a range")
+	}
+	if opts.decodes() && opts.Resume {
+		return 0, errors.New("an encrypted or compressed download can only be fetched whole, without resume or a range")
+	}
+
+	manifest, err := c.FetchChunkManifestContext(ctx, manifestID)
+	if err != nil {
+		return 0, err
+	}
+	if manifest.Encryption != "" && opts.Decrypt == nil {
+		return 0, errors.New("the parts of this object are encrypted; a key is needed to reassemble them")
+	}
+
+	partPath := PartialPath(path)
+	flags := os.O_CREATE | os.O_RDWR | os.O_TRUNC
+	if opts.Resume {
+		flags = os.O_CREATE | os.O_RDWR
+	}
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("opening %s: %w", partPath, err)
+	}
+
+	first, offset := 0, int64(0)
+	if opts.Resume {
+		if first, offset, err = verifiedParts(file, manifest); err == nil {
+			if err = file.Truncate(offset); err == nil {
+				_, err = file.Seek(offset, io.SeekStart)
+			}
+		}
+		if err != nil {
+			file.Close()
+			return 0, fmt.Errorf("checking %s: %w", partPath, err)
+		}
+	}
+
+	// Parts are decrypted one by one, but the object was compressed whole
+	var w io.Writer = file
+	var finish func() (int64, error)
+	if opts.Decompress != "" {
+		if w, finish, err = decodingWriter(file, nil, opts.Decompress); err != nil {
+			file.Close()
+			return 0, err
+		}
+	}
+	n, err := c.copyParts(ctx, manifest, first, w, opts.Decrypt, opts.Progress)
+	if err == nil && offset+n != manifest.Size {
+		err = fmt.Errorf("reassembled %d bytes, manifest says %d", offset+n, manifest.Size)
+	}
+	if finish != nil {
+		var finishErr error
+		n, finishErr = finish()
+		if err == nil {
+			err = finishErr
+		}
+	}
+	if err != nil {
+		file.Close()
+		if opts.decodes() {
+			os.Remove(partPath)
+		}
+		return offset + n, err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return offset + n, fmt.Errorf("syncing %s: %w", partPath, err)
+	}
+	if err := file.Close(); err != nil {
+		return offset + n, fmt.Errorf("closing %s: %w", partPath, err)
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return offset + n, fmt.Errorf("moving %s into place: %w", partPath, err)
+	}
+	return offset + n, nil
+}
+
+// copyParts copies the parts of manifest from first on into w and returns
+// the number of object bytes written. Each part is staged in a temporary file
+// and checked against its size and hash before any of it reaches w, so a bad
+// part never leaves unchecked bytes in a stream that cannot take them back,
+// such as an S3 upload. progress, when set, receives the stored bytes as they
+// arrive.
+func (c *WalrusClient) copyParts(ctx context.Context, manifest *ChunkManifest, first int, w io.Writer, key *EncryptionKey, progress io.Writer) (int64, error) {
+	if manifest.Encryption != "" && key == nil {
+		return 0, errors.New("the parts of this object are encrypted; a key is needed to reassemble them")
+	}
+	if first >= len(manifest.Parts) {
+		return 0, nil
+	}
+
+	stage, err := os.CreateTemp("", "walrus-part-*")
+	if err != nil {
+		return 0, fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(stage.Name())
+	defer stage.Close()
+
+	var total int64
+	for i := first; i < len(manifest.Parts); i++ {
+		part := manifest.Parts[i]
+		n, err := c.stagePart(ctx, part, stage, progress)
+		if err != nil {
+			return total, fmt.Errorf("part %d of %d: %w", i+1, len(manifest.Parts), err)
+		}
+
+		if manifest.Encryption != "" {
+			decrypter := NewDecryptingWriter(w, key)
+			_, err = io.Copy(decrypter, io.NewSectionReader(stage, 0, n))
+			if err == nil {
+				err = decrypter.Close()
+			}
+			total += decrypter.Written()
+		} else {
+			n, err = io.Copy(w, io.NewSectionReader(stage, 0, n))
+			total += n
+		}
+		if err != nil {
+			return total, fmt.Errorf("part %d of %d: %w", i+1, len(manifest.Parts), err)
+		}
+	}
+	return total, nil
+}
+
+// stagePart downloads part into stage, replacing what it held, and checks it
+// against the manifest's size and hash
+func (c *WalrusClient) stagePart(ctx context.Context, part ChunkPart, stage *os.File, progress io.Writer) (int64, error) {
+	if err := stage.Truncate(0); err != nil {
+		return 0, err
+	}
+	if _, err := stage.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	sum := sha256.New()
+	writers := []io.Writer{sum, stage}
+	if progress != nil {
+		writers = append(writers, progress)
+	}
+	n, err := c.streamBlob(ctx, blobResource(part.BlobID), 0, -1, io.MultiWriter(writers...))
+	if err != nil {
+		return n, err
+	}
+	if n != part.Size || hex.EncodeToString(sum.Sum(nil)) != part.SHA256 {
+		return n, fmt.Errorf("%s does not match the manifest", part.BlobID)
+	}
+	return n, nil
+}
+
+// verifiedParts returns how many leading parts file already holds intact,
+// and the offset where they end
+func verifiedParts(file *os.File, manifest *ChunkManifest) (int, int64, error) {
+	var offset int64
+	for i, part := range manifest.Parts {
+		sum := sha256.New()
+		n, err := io.Copy(sum, io.NewSectionReader(file, offset, part.Size))
+		if err != nil {
+			return 0, 0, err
+		}
+		if n != part.Size || hex.EncodeToString(sum.Sum(nil)) != part.SHA256 {
+			return i, offset, nil
+		}
+		offset += part.Size
+	}
+	return len(manifest.Parts), offset, nil
+}
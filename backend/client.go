@@ -2,24 +2,44 @@ package backend
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 )
 
 // WalrusClient handles communication with Walrus storage network
 type WalrusClient struct {
-	AggregatorURL  string
-	PublisherURL   string
-	UploadRelayURL string // Optional upload relay to reduce client requests
+	AggregatorURL  string        // Primary aggregator, first entry of Aggregators
+	PublisherURL   string        // Primary publisher, first entry of Publishers
+	Aggregators    *EndpointPool // All aggregators reads fail over between
+	Publishers     *EndpointPool // All publishers writes fail over between
+	UploadRelayURL string        // Upload relay used when UseUploadRelay is set
 	HTTPClient     *http.Client
-	UseUploadRelay bool
+	UseUploadRelay bool          // Store through the upload relay instead of a publisher
+	MaxRelayTip    uint64        // Refuse relay uploads whose tip exceeds this many MIST (0 = no limit)
+	SendObjectTo   string        // Sui address publishers transfer new blob objects to
+	WalrusCLI      *WalrusCLI    // Walrus binary used for wallet-signed operations
+	CallTimeout    time.Duration // Optional deadline for each call whose context has none
+	Retry          RetryPolicy   // Retry/backoff applied to every request
+	MaxBlobSize    int64         // Larger objects are stored in parts (0 = DefaultMaxBlobSize)
+	DedupChunkSize int64         // Average chunk size of deduplicated uploads (0 = DefaultDedupChunkSize)
+	ShardCount     int           // Shards of the network, for encoded sizes (0 = DefaultShardCount)
 }
 
+// BodyOpener returns a fresh reader positioned at the start of the blob each
+// time it is called, so an upload can be replayed on retry without buffering
+type BodyOpener func() (io.ReadCloser, error)
+
+// errBodyNotReplayable is returned by one-shot openers asked for a second body
+var errBodyNotReplayable = errors.New("upload body cannot be replayed")
+
 // BlobInfo represents information about a stored blob
 type BlobInfo struct {
 	BlobID      string            `json:"blobId"`
@@ -42,6 +62,8 @@ type StoreResponse struct {
 	Size             int64  `json:"size"`
 	AlreadyCertified bool   `json:"alreadyCertified"`
 	SuiObjectID      string `json:"suiObjectId,omitempty"`
+	Deletable        bool   `json:"deletable,omitempty"`
+	RelayTip         uint64 `json:"relayTip,omitempty"` // Tip paid to the upload relay, in MIST
 }
 
 type storeResponseEnvelope struct {
@@ -79,10 +101,12 @@ func (p walrusStoragePayload) size() int64 {
 
 type walrusNewlyCreatedLegacy struct {
 	BlobObject struct {
+		ID              string               `json:"id"`
 		BlobID          string               `json:"blobId"`
 		RegisteredEpoch int                  `json:"registeredEpoch"`
 		Storage         walrusStoragePayload `json:"storage"`
 		Size            int64                `json:"size"`
+		Deletable       bool                 `json:"deletable"`
 	} `json:"blobObject"`
 	Cost int64 `json:"cost"`
 }
@@ -105,32 +129,235 @@ type walrusAlreadyCertified struct {
 
 // NewWalrusClient creates a new Walrus client
 func NewWalrusClient(aggregatorURL, publisherURL string) *WalrusClient {
+	return NewWalrusClientWithEndpoints([]string{aggregatorURL}, []string{publisherURL})
+}
+
+// NewWalrusClientWithEndpoints creates a Walrus client that spreads requests
+// over several aggregators and publishers and fails over between them
+func NewWalrusClientWithEndpoints(aggregatorURLs, publisherURLs []string) *WalrusClient {
+	aggregators := NewEndpointPool(aggregatorURLs...)
+	publishers := NewEndpointPool(publisherURLs...)
 	return &WalrusClient{
-		AggregatorURL:  aggregatorURL,
-		PublisherURL:   publisherURL,
-		UploadRelayURL: "https://upload-relay.testnet.walrus.space", // Default upload relay
+		AggregatorURL:  firstURL(aggregators),
+		PublisherURL:   firstURL(publishers),
+		Aggregators:    aggregators,
+		Publishers:     publishers,
+		UploadRelayURL: DefaultUploadRelayURL,
+		// No overall client timeout: streaming a multi-GB blob can legitimately
+		// take far longer than any fixed limit, so deadlines come from the
+		// caller's context (or CallTimeout) instead.
 		HTTPClient: &http.Client{
-			Timeout: 60 * time.Second, // Increased timeout to match TS SDK
+			Transport: newHTTPTransport(),
 		},
-		UseUploadRelay: false, // Disabled until the relay flow is fully implemented
+		Retry: DefaultRetryPolicy(),
+	}
+}
+
+// newHTTPTransport returns a transport that bounds connection setup while
+// leaving the transfer itself to context deadlines
+func newHTTPTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSHandshakeTimeout = 15 * time.Second
+	transport.ExpectContinueTimeout = 1 * time.Second
+	return transport
+}
+
+func firstURL(pool *EndpointPool) string {
+	if urls := pool.URLs(); len(urls) > 0 {
+		return urls[0]
+	}
+	return ""
+}
+
+// ProbeEndpoints checks the health of every aggregator and publisher
+func (c *WalrusClient) ProbeEndpoints(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, pool := range []*EndpointPool{c.aggregatorPool(), c.publisherPool()} {
+		wg.Add(1)
+		go func(pool *EndpointPool) {
+			defer wg.Done()
+			pool.Probe(ctx, c.HTTPClient)
+		}(pool)
+	}
+	wg.Wait()
+}
+
+// aggregatorPool returns the aggregator pool, falling back to AggregatorURL
+// for clients that were built by hand
+func (c *WalrusClient) aggregatorPool() *EndpointPool {
+	if c.Aggregators == nil || c.Aggregators.Len() == 0 {
+		c.Aggregators = NewEndpointPool(c.AggregatorURL)
+	}
+	return c.Aggregators
+}
+
+// publisherPool returns the publisher pool, falling back to PublisherURL
+func (c *WalrusClient) publisherPool() *EndpointPool {
+	if c.Publishers == nil || c.Publishers.Len() == 0 {
+		c.Publishers = NewEndpointPool(c.PublisherURL)
+	}
+	return c.Publishers
+}
+
+// withEndpoints runs fn under the retry policy, moving to the next endpoint of
+// pool on every attempt and feeding each outcome and response time back into
+// the pool's health. fn must send its request with the ctx it is given for
+// the response time to be measured. The policy gets at least one attempt per
+// endpoint so every one is tried.
+func (c *WalrusClient) withEndpoints(ctx context.Context, pool *EndpointPool, fn func(ctx context.Context, attempt int, baseURL string) error) error {
+	candidates := pool.Candidates()
+	if len(candidates) == 0 {
+		return fmt.Errorf("no Walrus endpoints configured")
+	}
+
+	policy := c.Retry.WithDefaults()
+	if policy.MaxAttempts < len(candidates) {
+		policy.MaxAttempts = len(candidates)
+	}
+
+	return policy.Do(ctx, func(attempt int) error {
+		baseURL := candidates[(attempt-1)%len(candidates)]
+		var timer responseTimer
+		err := fn(timer.trace(ctx), attempt, baseURL)
+		if latency := timer.latency(); latency > 0 {
+			pool.ObserveLatency(baseURL, latency)
+		}
+		pool.Report(baseURL, err)
+		return err
+	})
+}
+
+// callContext applies CallTimeout to ctx unless the caller already set a deadline
+func (c *WalrusClient) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.CallTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
 	}
+	return context.WithTimeout(ctx, c.CallTimeout)
 }
 
-// StoreBlob uploads data to Walrus storage, optionally using upload relay
+// StoreBlob uploads data to Walrus storage, through the upload relay when enabled
 func (c *WalrusClient) StoreBlob(data []byte, epochs int) (*StoreResponse, error) {
-	// Use upload relay if configured and available
-	baseURL := c.PublisherURL
-	if c.UseUploadRelay && c.UploadRelayURL != "" {
-		baseURL = c.UploadRelayURL
+	return c.StoreBlobContext(context.Background(), data, epochs)
+}
+
+// StoreBlobContext is StoreBlob with cancellation and deadlines taken from ctx
+func (c *WalrusClient) StoreBlobContext(ctx context.Context, data []byte, epochs int) (*StoreResponse, error) {
+	return c.StoreBlobFromReaderContext(ctx, bytes.NewReader(data), int64(len(data)), epochs)
+}
+
+// StoreBlobFromReader streams size bytes from r to Walrus storage. The body is
+// sent with a fixed Content-Length so the blob is never held in memory.
+func (c *WalrusClient) StoreBlobFromReader(r io.Reader, size int64, epochs int) (*StoreResponse, error) {
+	return c.StoreBlobFromReaderContext(context.Background(), r, size, epochs)
+}
+
+// StoreBlobFromReaderContext is StoreBlobFromReader bound to ctx. Cancelling
+// ctx aborts the upload mid-stream. Failed uploads are retried only when r is
+// an io.Seeker that can be rewound to where it started.
+func (c *WalrusClient) StoreBlobFromReaderContext(ctx context.Context, r io.Reader, size int64, epochs int) (*StoreResponse, error) {
+	return c.StoreBlobFromOpenerContext(ctx, readerOpener(r), size, epochs)
+}
+
+// StoreBlobFromOpenerContext streams size bytes to Walrus storage, calling open
+// for a fresh body on every attempt so transient failures can be retried
+func (c *WalrusClient) StoreBlobFromOpenerContext(ctx context.Context, open BodyOpener, size int64, epochs int) (*StoreResponse, error) {
+	return c.StoreBlobWithOptionsContext(ctx, open, size, StoreOptions{Epochs: epochs})
+}
+
+// StoreBlobWithOptionsContext is StoreBlobFromOpenerContext with extra store
+// options. Attributes are attached once the blob is stored; if that step
+// fails the store response is returned together with the error, since the
+// blob itself is already on Walrus.
+func (c *WalrusClient) StoreBlobWithOptionsContext(ctx context.Context, open BodyOpener, size int64, opts StoreOptions) (*StoreResponse, error) {
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	if size < 0 {
+		return nil, fmt.Errorf("invalid blob size %d", size)
 	}
 
-	url := fmt.Sprintf("%s/v1/blobs?epochs=%d", baseURL, epochs)
+	relay := c.UseUploadRelay && c.UploadRelayURL != ""
+	if len(opts.Attributes) > 0 && !c.CanSetAttributes() {
+		// Publishers keep the blob object unless told to hand it over, and
+		// only the owner can attach attributes to it
+		return nil, fmt.Errorf("blob attributes need wallet.address configured or an upload relay")
+	}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewReader(data))
+	var storeResp *StoreResponse
+	var err error
+	if relay {
+		storeResp, err = c.storeViaRelay(ctx, open, size, opts)
+	} else {
+		storeResp, err = c.storeViaPublisher(ctx, open, size, opts)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, err
+	}
+
+	if len(opts.Attributes) > 0 {
+		if err := c.SetBlobAttributesContext(ctx, storeResp.SuiObjectID, opts.Attributes); err != nil {
+			return storeResp, fmt.Errorf("blob %s stored but setting attributes failed: %w", storeResp.BlobID, err)
+		}
 	}
 
+	return storeResp, nil
+}
+
+// storeViaPublisher uploads through the publisher pool with retry and failover
+func (c *WalrusClient) storeViaPublisher(ctx context.Context, open BodyOpener, size int64, opts StoreOptions) (*StoreResponse, error) {
+	var storeResp *StoreResponse
+	var lastErr error
+	err := c.withEndpoints(ctx, c.publisherPool(), func(ctx context.Context, attempt int, baseURL string) error {
+		body, err := open()
+		if err != nil {
+			if errors.Is(err, errBodyNotReplayable) && lastErr != nil {
+				return Permanent(lastErr)
+			}
+			return Permanent(fmt.Errorf("opening upload body: %w", err))
+		}
+		defer body.Close()
+
+		storeResp, lastErr = c.storeBlobOnce(ctx, baseURL, body, size, opts)
+		return lastErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storeResp, nil
+}
+
+// storeQuery builds the publisher query string for opts
+func (c *WalrusClient) storeQuery(opts StoreOptions) string {
+	query := url.Values{}
+	query.Set("epochs", strconv.Itoa(opts.Epochs))
+	if opts.Deletable {
+		query.Set("deletable", "true")
+	}
+	if c.SendObjectTo != "" {
+		query.Set("send_object_to", c.SendObjectTo)
+	}
+	return query.Encode()
+}
+
+// storeBlobOnce performs a single PUT of size bytes from body to baseURL
+func (c *WalrusClient) storeBlobOnce(ctx context.Context, baseURL string, r io.Reader, size int64, opts StoreOptions) (*StoreResponse, error) {
+	reqURL := fmt.Sprintf("%s/v1/blobs?%s", baseURL, c.storeQuery(opts))
+
+	var body io.Reader = http.NoBody
+	if size > 0 {
+		body = io.LimitReader(r, size)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", reqURL, body)
+	if err != nil {
+		return nil, Permanent(fmt.Errorf("creating request: %w", err))
+	}
+
+	req.ContentLength = size
 	req.Header.Set("Content-Type", "application/octet-stream")
 
 	resp, err := c.HTTPClient.Do(req)
@@ -140,23 +367,51 @@ func (c *WalrusClient) StoreBlob(data []byte, epochs int) (*StoreResponse, error
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, body)
+		return nil, newHTTPStatusError("upload", resp)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("reading response body: %w", err)
 	}
 
-	storeResp, err := decodeStoreResponse(body, int64(len(data)))
+	storeResp, err := decodeStoreResponse(respBody, size)
 	if err != nil {
-		return nil, err
+		return nil, Permanent(err)
 	}
 
 	return storeResp, nil
 }
 
+// readerOpener adapts r to a BodyOpener. Seekable readers are rewound to
+// their starting offset for each attempt; anything else can be read once.
+func readerOpener(r io.Reader) BodyOpener {
+	var start int64
+	seeker, seekable := r.(io.Seeker)
+	if seekable {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			seekable = false
+		}
+		start = offset
+	}
+
+	used := false
+	return func() (io.ReadCloser, error) {
+		if seekable {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return io.NopCloser(r), nil
+		}
+		if used {
+			return nil, errBodyNotReplayable
+		}
+		used = true
+		return io.NopCloser(r), nil
+	}
+}
+
 func decodeStoreResponse(payload []byte, fallbackSize int64) (*StoreResponse, error) {
 	var envelope storeResponseEnvelope
 	if err := json.Unmarshal(payload, &envelope); err != nil {
@@ -211,6 +466,8 @@ func parseNewlyCreated(raw json.RawMessage, fallbackSize int64) (*StoreResponse,
 		endEpoch := int64(legacy.BlobObject.Storage.endEpoch())
 		resp := &StoreResponse{
 			BlobID:           legacy.BlobObject.BlobID,
+			SuiObjectID:      legacy.BlobObject.ID,
+			Deletable:        legacy.BlobObject.Deletable,
 			EndEpoch:         &endEpoch,
 			Size:             resolveSize(fallbackSize, legacy.BlobObject.Storage.size(), legacy.BlobObject.Size),
 			AlreadyCertified: false,
@@ -244,6 +501,8 @@ func parseAlreadyCertified(raw json.RawMessage, fallbackSize int64) (*StoreRespo
 		endEpoch := int64(legacy.BlobObject.Storage.endEpoch())
 		resp := &StoreResponse{
 			BlobID:           legacy.BlobObject.BlobID,
+			SuiObjectID:      legacy.BlobObject.ID,
+			Deletable:        legacy.BlobObject.Deletable,
 			EndEpoch:         &endEpoch,
 			Size:             resolveSize(fallbackSize, legacy.BlobObject.Storage.size(), legacy.BlobObject.Size),
 			AlreadyCertified: true,
@@ -266,61 +525,47 @@ func resolveSize(fallback int64, candidates ...int64) int64 {
 
 // RetrieveBlob downloads a blob from Walrus storage with retry logic
 func (c *WalrusClient) RetrieveBlob(blobID string) ([]byte, error) {
-	url := fmt.Sprintf("%s/v1/blobs/%s", c.AggregatorURL, blobID)
-
-	// Retry logic for transient failures
-	var lastErr error
-	for attempt := 0; attempt < 3; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff
-			time.Sleep(time.Duration(attempt) * 2 * time.Second)
-		}
-
-		resp, err := c.HTTPClient.Get(url)
-		if err != nil {
-			// Check if it's a retryable network error
-			if isRetryableError(err) {
-				lastErr = err
-				continue
-			}
-			return nil, fmt.Errorf("retrieving blob: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
-			// Retryable status codes
-			body, _ := io.ReadAll(resp.Body)
-			lastErr = fmt.Errorf("status %d: %s", resp.StatusCode, body)
-			continue
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("retrieval failed with status %d: %s", resp.StatusCode, body)
-		}
-
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("reading blob data: %w", err)
-		}
-
-		return data, nil
-	}
+	return c.RetrieveBlobContext(context.Background(), blobID)
+}
 
-	if lastErr != nil {
-		return nil, fmt.Errorf("failed after 3 attempts: %w", lastErr)
+// RetrieveBlobContext is RetrieveBlob bound to ctx
+func (c *WalrusClient) RetrieveBlobContext(ctx context.Context, blobID string) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := c.RetrieveBlobToContext(ctx, blobID, &buf); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("failed to retrieve blob")
+	return buf.Bytes(), nil
 }
 
 // GetBlobStatus checks if a blob exists and returns its info
 func (c *WalrusClient) GetBlobStatus(blobID string) (*BlobInfo, error) {
+	return c.GetBlobStatusContext(context.Background(), blobID)
+}
+
+// GetBlobStatusContext is GetBlobStatus bound to ctx
+func (c *WalrusClient) GetBlobStatusContext(ctx context.Context, blobID string) (*BlobInfo, error) {
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	var info *BlobInfo
+	err := c.withEndpoints(ctx, c.aggregatorPool(), func(ctx context.Context, attempt int, baseURL string) error {
+		var err error
+		info, err = c.getBlobStatusOnce(ctx, baseURL, blobID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (c *WalrusClient) getBlobStatusOnce(ctx context.Context, baseURL, blobID string) (*BlobInfo, error) {
 	// Try to retrieve just the headers to check if blob exists
-	url := fmt.Sprintf("%s/v1/blobs/%s", c.AggregatorURL, blobID)
+	url := fmt.Sprintf("%s/v1/blobs/%s", baseURL, blobID)
 
-	req, err := http.NewRequest("HEAD", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, Permanent(fmt.Errorf("creating request: %w", err))
 	}
 
 	resp, err := c.HTTPClient.Do(req)
@@ -330,11 +575,11 @@ func (c *WalrusClient) GetBlobStatus(blobID string) (*BlobInfo, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("blob not found")
+		return nil, Permanent(fmt.Errorf("blob not found"))
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status check failed with code %d", resp.StatusCode)
+		return nil, newHTTPStatusError("status check", resp)
 	}
 
 	// Extract metadata from response headers if available
@@ -347,6 +592,10 @@ func (c *WalrusClient) GetBlobStatus(blobID string) (*BlobInfo, error) {
 		info.ContentType = contentType
 	}
 
+	// Blob attributes come back as headers; the file name travels in Content-Disposition
+	info.Identifier = filenameFromDisposition(resp.Header.Get("Content-Disposition"))
+	info.Tags = attributeHeaders(resp.Header)
+
 	// Try to parse content-length
 	if contentLength := resp.Header.Get("Content-Length"); contentLength != "" {
 		if size, err := fmt.Sscanf(contentLength, "%d", &info.Size); err == nil && size == 1 {
@@ -366,16 +615,7 @@ func (c *WalrusClient) EstimateStorageCost(sizeBytes int64, epochs int) (int64,
 	// - Fixed metadata overhead of ~64MB for small files
 	// - Upload relay reduces network overhead
 
-	// Calculate encoded size (5x larger + metadata overhead)
-	encodedSizeBytes := sizeBytes * 5
-	fixedMetadataBytes := int64(64 * 1024 * 1024) // 64MB metadata overhead
-
-	// For small files, metadata dominates the cost
-	if sizeBytes < 10*1024*1024 { // Files < 10MB
-		encodedSizeBytes = fixedMetadataBytes
-	} else {
-		encodedSizeBytes += fixedMetadataBytes
-	}
+	encodedSizeBytes := estimateEncodedSize(sizeBytes)
 
 	// Convert to MB for pricing calculation
 	encodedSizeMB := (encodedSizeBytes + 1048575) / 1048576 // Round up to nearest MB
@@ -391,27 +631,15 @@ func (c *WalrusClient) EstimateStorageCost(sizeBytes int64, epochs int) (int64,
 	return totalCostFrost, nil
 }
 
-// isRetryableError checks if an error is retryable (network issues)
-func isRetryableError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	errStr := err.Error()
-	retryablePatterns := []string{
-		"connection refused",
-		"connection reset",
-		"timeout",
-		"temporary failure",
-		"no such host",
-		"network is unreachable",
-	}
+// estimateEncodedSize approximates the size of a blob after erasure coding
+func estimateEncodedSize(sizeBytes int64) int64 {
+	// Encoded size is ~5x larger plus a fixed metadata overhead
+	encodedSizeBytes := sizeBytes * 5
+	fixedMetadataBytes := int64(64 * 1024 * 1024) // 64MB metadata overhead
 
-	for _, pattern := range retryablePatterns {
-		if strings.Contains(strings.ToLower(errStr), pattern) {
-			return true
-		}
+	// For small files, metadata dominates the cost
+	if sizeBytes < 10*1024*1024 { // Files < 10MB
+		return fixedMetadataBytes
 	}
-
-	return false
+	return encodedSizeBytes + fixedMetadataBytes
 }
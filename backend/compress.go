@@ -0,0 +1,258 @@
+package backend
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codecs for compressing files before they are stored
+const (
+	CompressionZstd = "zstd"
+	CompressionGzip = "gzip"
+)
+
+// AttributeCompression is the blob attribute naming the codec a blob was
+// compressed with. It is not Content-Encoding, which would make HTTP clients
+// decompress the blob on their own.
+const AttributeCompression = "compression"
+
+// CompressionInfo records how a file was compressed before it was stored
+type CompressionInfo struct {
+	Codec string `json:"codec"`
+	Size  int64  `json:"size"` // Compressed size, before any encryption
+}
+
+// ParseCompression checks a codec name given on the command line
+func ParseCompression(codec string) (string, error) {
+	switch codec := strings.ToLower(codec); codec {
+	case "", CompressionZstd, CompressionGzip:
+		return codec, nil
+	default:
+		return "", fmt.Errorf("unknown compression %q (expected zstd or gzip)", codec)
+	}
+}
+
+// incompressibleTypes are content types whose data is already compressed
+var incompressibleTypes = map[string]bool{
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/zstd":             true,
+	"application/zip":              true,
+	"application/x-bzip2":          true,
+	"application/x-xz":             true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+	"application/vnd.rar":          true,
+	"application/java-archive":     true,
+	"application/epub+zip":         true,
+	"application/pdf":              true,
+	"font/woff":                    true,
+	"font/woff2":                   true,
+}
+
+// Compressible reports whether content of contentType is worth compressing.
+// Most images, audio and video, archives, and office documents (which are
+// zip files) are already compressed.
+func Compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	switch {
+	case incompressibleTypes[mediaType]:
+		return false
+	case mediaType == "image/svg+xml", mediaType == "image/bmp", mediaType == "audio/wav", mediaType == "audio/x-wav":
+		return true
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		return false
+	case strings.HasPrefix(mediaType, "application/vnd.openxmlformats-officedocument."),
+		strings.HasPrefix(mediaType, "application/vnd.oasis.opendocument."):
+		return false
+	}
+	return true
+}
+
+// CompressToFile compresses r with codec into a temporary file and returns
+// it rewound, so the compressed size is known before an upload starts. The
+// caller closes and removes the file.
+func CompressToFile(r io.Reader, codec string) (*os.File, error) {
+	file, err := os.CreateTemp("", "walrus-compress-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating compression spool: %w", err)
+	}
+	fail := func(err error) (*os.File, error) {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	enc, err := newCompressor(file, codec)
+	if err != nil {
+		return fail(err)
+	}
+	if _, err := io.Copy(enc, r); err != nil {
+		enc.Close()
+		return fail(fmt.Errorf("compressing: %w", err))
+	}
+	if err := enc.Close(); err != nil {
+		return fail(fmt.Errorf("compressing: %w", err))
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	return file, nil
+}
+
+// CompressedSize returns how many bytes compressing r with codec produces,
+// without keeping them
+func CompressedSize(r io.Reader, codec string) (int64, error) {
+	counter := &countingWriter{w: io.Discard}
+	enc, err := newCompressor(counter, codec)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(enc, r); err != nil {
+		enc.Close()
+		return 0, fmt.Errorf("compressing: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return 0, fmt.Errorf("compressing: %w", err)
+	}
+	return counter.n, nil
+}
+
+// newCompressor returns a writer compressing into w with codec
+func newCompressor(w io.Writer, codec string) (io.WriteCloser, error) {
+	switch codec {
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", codec)
+	}
+}
+
+// DecompressingWriter decompresses what is written to it into an underlying
+// writer. Close must be called to learn whether the stream was complete.
+type DecompressingWriter struct {
+	pw      *io.PipeWriter
+	done    chan error
+	written int64
+}
+
+// NewDecompressingWriter returns a writer that decompresses codec data into w
+func NewDecompressingWriter(w io.Writer, codec string) (*DecompressingWriter, error) {
+	if codec != CompressionZstd && codec != CompressionGzip {
+		return nil, fmt.Errorf("unknown compression %q", codec)
+	}
+
+	// The decoders pull from a reader, so they run on the far side of a pipe
+	pr, pw := io.Pipe()
+	d := &DecompressingWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		var dec io.Reader
+		var err error
+		switch codec {
+		case CompressionZstd:
+			var zr *zstd.Decoder
+			if zr, err = zstd.NewReader(pr, zstd.WithDecoderConcurrency(1)); err == nil {
+				defer zr.Close()
+				dec = zr
+			}
+		case CompressionGzip:
+			var gr *gzip.Reader
+			if gr, err = gzip.NewReader(pr); err == nil {
+				defer gr.Close()
+				dec = gr
+			}
+		}
+		if err == nil {
+			d.written, err = io.Copy(w, dec)
+		}
+		if err != nil {
+			err = fmt.Errorf("decompressing: %w", err)
+			pr.CloseWithError(err)
+		} else {
+			// Anything after the end of the stream is an error too
+			pr.CloseWithError(fmt.Errorf("decompressing: data after the end of the %s stream", codec))
+		}
+		d.done <- err
+	}()
+	return d, nil
+}
+
+func (d *DecompressingWriter) Write(p []byte) (int, error) {
+	return d.pw.Write(p)
+}
+
+// Close ends the compressed stream and waits for the rest of it to be
+// written out. It fails if the stream was cut short or corrupt.
+func (d *DecompressingWriter) Close() error {
+	d.pw.Close()
+	return <-d.done
+}
+
+// Written returns how many decompressed bytes reached the underlying writer
+func (d *DecompressingWriter) Written() int64 {
+	return d.written
+}
+
+// decodingWriter wraps w so that stored bytes written to it come out as the
+// original file: decrypted with key, then decompressed with codec, either of
+// which may be unset. finish flushes both and returns the number of bytes
+// that reached w; it has to be called even after a failed copy, to stop the
+// decompressor.
+func decodingWriter(w io.Writer, key *EncryptionKey, codec string) (io.Writer, func() (int64, error), error) {
+	counted := &countingWriter{w: w}
+	dst := io.Writer(counted)
+	var decompressor *DecompressingWriter
+	if codec != "" {
+		var err error
+		if decompressor, err = NewDecompressingWriter(counted, codec); err != nil {
+			return nil, nil, err
+		}
+		dst = decompressor
+	}
+	var decrypter *DecryptingWriter
+	if key != nil {
+		decrypter = NewDecryptingWriter(dst, key)
+		dst = decrypter
+	}
+
+	finish := func() (int64, error) {
+		if decrypter != nil {
+			if err := decrypter.Close(); err != nil {
+				if decompressor != nil {
+					decompressor.Close()
+				}
+				return counted.n, fmt.Errorf("decrypting: %w", err)
+			}
+		}
+		if decompressor != nil {
+			if err := decompressor.Close(); err != nil {
+				return counted.n, err
+			}
+		}
+		return counted.n, nil
+	}
+	return dst, finish, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
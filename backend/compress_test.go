@@ -0,0 +1,31 @@
+package backend
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestCompressedSizeMatchesSpool(t *testing.T) {
+	data := bytes.Repeat([]byte("walrus compresses well "), 4096)
+	for _, codec := range []string{CompressionZstd, CompressionGzip} {
+		spool, err := CompressToFile(bytes.NewReader(data), codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stat, _ := spool.Stat()
+		spool.Close()
+		os.Remove(spool.Name())
+
+		size, err := CompressedSize(bytes.NewReader(data), codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != stat.Size() || size >= int64(len(data)) {
+			t.Errorf("%s: CompressedSize = %d, spool holds %d of %d bytes", codec, size, stat.Size(), len(data))
+		}
+	}
+	if _, err := CompressedSize(bytes.NewReader(data), "lz4"); err == nil {
+		t.Error("unknown codec accepted")
+	}
+}
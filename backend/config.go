@@ -11,19 +11,54 @@ import (
 // Config represents the Walrus backend configuration
 type Config struct {
 	Walrus WalrusConfig `yaml:"walrus"`
+	Retry  RetryPolicy  `yaml:"retry,omitempty"`
+	S3     S3Config     `yaml:"s3,omitempty"`
 }
 
 // WalrusConfig contains Walrus-specific settings
 type WalrusConfig struct {
 	AggregatorURL string       `yaml:"aggregator_url"`
 	PublisherURL  string       `yaml:"publisher_url"`
+	Aggregators   []string     `yaml:"aggregators,omitempty"` // Extra aggregators to fail over to
+	Publishers    []string     `yaml:"publishers,omitempty"`  // Extra publishers to fail over to
 	Epochs        int          `yaml:"epochs"`
 	Wallet        WalletConfig `yaml:"wallet"`
+
+	UploadRelayURL string `yaml:"upload_relay_url,omitempty"` // Relay used instead of a publisher
+	UseUploadRelay bool   `yaml:"use_upload_relay,omitempty"` // Store through the relay by default
+	MaxRelayTip    uint64 `yaml:"max_relay_tip,omitempty"`    // Highest relay tip to pay, in MIST
+	WalrusBinary   string `yaml:"walrus_binary,omitempty"`    // Path to the official walrus client
+	ClientConfig   string `yaml:"client_config,omitempty"`    // walrus client config passed to the binary
+	MaxBlobSize    int64  `yaml:"max_blob_size,omitempty"`    // Bytes; larger files are stored in parts
+	DedupChunkSize int64  `yaml:"dedup_chunk_size,omitempty"` // Average chunk size for upload --dedup, in bytes
+}
+
+// AggregatorURLs returns the primary aggregator followed by any extra ones
+func (w WalrusConfig) AggregatorURLs() []string {
+	return append([]string{w.AggregatorURL}, w.Aggregators...)
+}
+
+// PublisherURLs returns the primary publisher followed by any extra ones
+func (w WalrusConfig) PublisherURLs() []string {
+	return append([]string{w.PublisherURL}, w.Publishers...)
 }
 
 // WalletConfig contains wallet settings
 type WalletConfig struct {
 	PrivateKey string `yaml:"private_key"`
+	ConfigPath string `yaml:"config_path,omitempty"` // Sui wallet config used to sign transactions
+	Address    string `yaml:"address,omitempty"`     // Sui address that receives blob objects from publishers
+}
+
+// S3Config holds the S3 source settings used by the s3 commands. Credentials
+// are never stored here; they come from the AWS credential chain, which a
+// profile selects from.
+type S3Config struct {
+	Profile      string `yaml:"profile,omitempty"`        // Named profile in ~/.aws/config and ~/.aws/credentials
+	Region       string `yaml:"region,omitempty"`         // Overrides the profile's region
+	Endpoint     string `yaml:"endpoint,omitempty"`       // S3-compatible endpoint URL
+	UsePathStyle bool   `yaml:"use_path_style,omitempty"` // Address buckets as endpoint/bucket
+	CACertFile   string `yaml:"ca_cert_file,omitempty"`   // Extra CA bundle to trust for the endpoint
 }
 
 // DefaultConfig returns the default configuration
@@ -37,28 +72,47 @@ func DefaultConfig() *Config {
 				PrivateKey: "",
 			},
 		},
+		Retry: DefaultRetryPolicy(),
+	}
+}
+
+// findConfig returns the first config file that exists in the default
+// locations, or "" if there is none
+func findConfig() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	defaultPaths := []string{
+		filepath.Join(home, ".config", "walrus-rclone", "config.yaml"),
+		filepath.Join(home, ".walrus-rclone", "config.yaml"),
+		"walrus-config.yaml",
+	}
+
+	for _, p := range defaultPaths {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// DefaultConfigPath returns the config file LoadConfig("") reads, or
+// ~/.walrus-rclone/config.yaml when none exists yet
+func DefaultConfigPath() string {
+	if path := findConfig(); path != "" {
+		return path
 	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".walrus-rclone", "config.yaml")
 }
 
 // LoadConfig loads configuration from file
 func LoadConfig(path string) (*Config, error) {
 	// If no path provided, try default locations
 	if path == "" {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			defaultPaths := []string{
-				filepath.Join(home, ".config", "walrus-rclone", "config.yaml"),
-				filepath.Join(home, ".walrus-rclone", "config.yaml"),
-				"walrus-config.yaml",
-			}
-
-			for _, p := range defaultPaths {
-				if _, err := os.Stat(p); err == nil {
-					path = p
-					break
-				}
-			}
-		}
+		path = findConfig()
 	}
 
 	// If still no path, return default config
@@ -80,6 +134,12 @@ func LoadConfig(path string) (*Config, error) {
 	}
 
 	// Set defaults for missing values
+	if config.Walrus.AggregatorURL == "" && len(config.Walrus.Aggregators) > 0 {
+		config.Walrus.AggregatorURL = config.Walrus.Aggregators[0]
+	}
+	if config.Walrus.PublisherURL == "" && len(config.Walrus.Publishers) > 0 {
+		config.Walrus.PublisherURL = config.Walrus.Publishers[0]
+	}
 	if config.Walrus.AggregatorURL == "" {
 		config.Walrus.AggregatorURL = "https://aggregator.walrus-testnet.walrus.space"
 	}
@@ -89,6 +149,7 @@ func LoadConfig(path string) (*Config, error) {
 	if config.Walrus.Epochs == 0 {
 		config.Walrus.Epochs = 5
 	}
+	config.Retry = config.Retry.WithDefaults()
 
 	return &config, nil
 }
@@ -124,5 +185,11 @@ func (c *Config) Validate() error {
 	if c.Walrus.Epochs <= 0 {
 		return fmt.Errorf("epochs must be positive")
 	}
+	if c.Walrus.MaxBlobSize < 0 {
+		return fmt.Errorf("max_blob_size cannot be negative")
+	}
+	if c.Walrus.DedupChunkSize < 0 {
+		return fmt.Errorf("dedup_chunk_size cannot be negative")
+	}
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,438 @@
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// DedupChunking names the content-defined chunking in the manifests of
+// deduplicated files. Chunk boundaries depend on the gear table and the
+// chunk sizes, so neither may change without a new name.
+const DedupChunking = "gear-cdc-v1"
+
+// DefaultDedupChunkSize is the average chunk size when
+// WalrusClient.DedupChunkSize is not set. Every blob carries a large fixed
+// storage overhead, so chunks are kept big.
+const DefaultDedupChunkSize = 16 << 20
+
+// gearTable maps each byte to a pseudo-random value for the rolling hash.
+// It is generated from a fixed seed so boundaries never change between
+// builds.
+var gearTable = func() (table [256]uint64) {
+	state := uint64(0x5741_4c52_5553_4344) // "WALRUSCD"
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// Chunker splits a stream into content-defined chunks with a gear rolling
+// hash, so an insertion or deletion only changes the chunks around it.
+// Chunks are between a quarter of and four times the average size.
+type Chunker struct {
+	r        io.Reader
+	min, max int
+	mask     uint64 // High bits of the hash that must be zero at a boundary
+	buf      []byte
+	next     int // Start of the unreturned data in buf
+	eof      bool
+}
+
+// NewChunker returns a Chunker over r with the given average chunk size,
+// which is rounded down to a power of two
+func NewChunker(r io.Reader, avg int) *Chunker {
+	bits := 0
+	for 2<<bits <= avg {
+		bits++
+	}
+	avg = 1 << bits
+	return &Chunker{
+		r:    r,
+		min:  avg / 4,
+		max:  avg * 4,
+		mask: ^uint64(0) << (64 - bits),
+		buf:  make([]byte, 0, avg*4),
+	}
+}
+
+// Next returns the next chunk, or io.EOF after the last one. The chunk is
+// only valid until the next call.
+func (c *Chunker) Next() ([]byte, error) {
+	c.buf = c.buf[:copy(c.buf, c.buf[c.next:])]
+	c.next = 0
+	if !c.eof && len(c.buf) < c.max {
+		n, err := io.ReadFull(c.r, c.buf[len(c.buf):c.max])
+		c.buf = c.buf[:len(c.buf)+n]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			c.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if len(c.buf) == 0 {
+		return nil, io.EOF
+	}
+
+	c.next = c.boundary(c.buf)
+	return c.buf[:c.next], nil
+}
+
+// boundary returns the length of the chunk at the start of data
+func (c *Chunker) boundary(data []byte) int {
+	if len(data) <= c.min {
+		return len(data)
+	}
+	end := min(len(data), c.max)
+	// Bit k of the hash depends on the last k+1 bytes, so the high bits are
+	// tested to look at the widest window
+	var hash uint64
+	for i := 0; i < end; i++ {
+		hash = hash<<1 + gearTable[data[i]]
+		if i >= c.min && hash&c.mask == 0 {
+			return i + 1
+		}
+	}
+	return end
+}
+
+// StoredChunk is a chunk the chunk store knows to be on Walrus
+type StoredChunk struct {
+	BlobID      string `json:"blob_id"`
+	Size        int64  `json:"size"`
+	SuiObjectID string `json:"sui_object_id,omitempty"`
+	ExpiryEpoch int    `json:"expiry_epoch,omitempty"` // As stored; renewals move it out
+}
+
+// ChunkStore remembers the blob holding each chunk uploaded with
+// deduplication, keyed by the chunk's SHA-256, so a chunk seen again is
+// referenced instead of stored twice
+type ChunkStore struct {
+	path string
+	mu   sync.Mutex
+
+	Chunks     map[string]StoredChunk `json:"chunks"`
+	SavedBytes int64                  `json:"saved_bytes"` // Bytes referenced instead of uploaded, over all uploads
+}
+
+// ChunkStorePath returns the path of the local chunk store
+func ChunkStorePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".walrus-chunk-store.json")
+}
+
+// LoadChunkStore reads the chunk store at path; a missing file is an empty store
+func LoadChunkStore(path string) (*ChunkStore, error) {
+	store := &ChunkStore{path: path, Chunks: make(map[string]StoredChunk)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("reading chunk store %s: %w", path, err)
+	}
+	if store.Chunks == nil {
+		store.Chunks = make(map[string]StoredChunk)
+	}
+	return store, nil
+}
+
+// Lookup returns the blob holding the chunk with the given SHA-256
+func (s *ChunkStore) Lookup(sum string) (StoredChunk, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chunk, ok := s.Chunks[sum]
+	return chunk, ok
+}
+
+// Add records the blob holding a chunk
+func (s *ChunkStore) Add(sum string, chunk StoredChunk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Chunks[sum] = chunk
+}
+
+// RemoveBlob forgets every chunk held by blobID, once the blob is deleted
+func (s *ChunkStore) RemoveBlob(blobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sum, chunk := range s.Chunks {
+		if chunk.BlobID == blobID {
+			delete(s.Chunks, sum)
+		}
+	}
+}
+
+// StoredBytes returns the total size of the chunks in the store
+func (s *ChunkStore) StoredBytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total int64
+	for _, chunk := range s.Chunks {
+		total += chunk.Size
+	}
+	return total
+}
+
+// Save writes the store back to disk
+func (s *ChunkStore) Save() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+// DedupChunk is one content-defined chunk of a file
+type DedupChunk struct {
+	Offset int64
+	Size   int64
+	SHA256 string
+	Stored *StoredChunk // Set when the chunk store already holds it
+}
+
+// DedupPlan is how a file splits into chunks, and which of them are
+// already stored
+type DedupPlan struct {
+	Size      int64
+	NewBytes  int64 // Bytes that still have to be uploaded
+	NewChunks int
+	Chunks    []DedupChunk
+}
+
+// SavedBytes returns how many bytes deduplication keeps from being uploaded
+func (p *DedupPlan) SavedBytes() int64 {
+	return p.Size - p.NewBytes
+}
+
+// NewSizes returns the size of every chunk that has to be uploaded
+func (p *DedupPlan) NewSizes() []int64 {
+	var sizes []int64
+	seen := make(map[string]bool)
+	for _, chunk := range p.Chunks {
+		if chunk.Stored != nil || seen[chunk.SHA256] {
+			continue
+		}
+		seen[chunk.SHA256] = true
+		sizes = append(sizes, chunk.Size)
+	}
+	return sizes
+}
+
+func (c *WalrusClient) dedupChunkSize() int {
+	avg := int64(DefaultDedupChunkSize)
+	if c.DedupChunkSize > 0 {
+		avg = c.DedupChunkSize
+	}
+	// The largest chunk has to fit in one blob
+	return int(min(avg, c.maxBlobSize()/4))
+}
+
+// PlanDedup reads r once, splitting it into content-defined chunks and
+// looking each one up in store. A chunk repeated within r is only counted
+// as new once.
+func (c *WalrusClient) PlanDedup(r io.Reader, store *ChunkStore) (*DedupPlan, error) {
+	if c.dedupChunkSize() <= 0 {
+		return nil, fmt.Errorf("max blob size of %d bytes is too small to deduplicate", c.maxBlobSize())
+	}
+
+	plan := &DedupPlan{}
+	seen := make(map[string]bool)
+	chunker := NewChunker(r, c.dedupChunkSize())
+	for {
+		data, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		chunk := DedupChunk{Offset: plan.Size, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+		if stored, ok := store.Lookup(chunk.SHA256); ok {
+			chunk.Stored = &stored
+		} else if !seen[chunk.SHA256] {
+			plan.NewBytes += chunk.Size
+			plan.NewChunks++
+		}
+		seen[chunk.SHA256] = true
+		plan.Chunks = append(plan.Chunks, chunk)
+		plan.Size += chunk.Size
+	}
+	return plan, nil
+}
+
+// StoreDeduplicatedContext stores the file behind r as planned: chunks the
+// store holds are referenced, after checking their blob can still be read,
+// and the rest are uploaded and added to the store. A manifest blob listing
+// every chunk is stored last and stands for the file, so it downloads like
+// any chunked object. Each chunk is re-hashed as it is sent, which catches
+// a file that changed since it was planned. The store is not saved.
+//
+// A referenced chunk that would expire before the manifest is stored again
+// for the requested epochs, before the manifest, so the manifest names its
+// new blob object; same content means the same blob ID. The response's
+// EndEpoch is the earliest epoch any part or the manifest expires, which is
+// when the file stops being readable.
+func (c *WalrusClient) StoreDeduplicatedContext(ctx context.Context, r io.ReaderAt, plan *DedupPlan, store *ChunkStore, opts StoreOptions, progress *progressbar.ProgressBar) (*StoreResponse, *ChunkManifest, error) {
+	manifest := &ChunkManifest{Format: ChunkManifestFormat, Size: plan.Size, Chunking: DedupChunking}
+
+	partOpts := opts
+	partOpts.Attributes = nil
+	upload := ChunkedUpload{
+		Size: plan.Size,
+		Open: func(offset, length int64) (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(r, offset, length)), nil
+		},
+		Progress: progress,
+	}
+	reused := make(map[int]bool) // Places in the manifest of referenced chunks
+	endEpoch := 0                // Earliest expiry of the parts stored so far, 0 if unknown
+	current := -1                // The network's current epoch, -1 until known
+	for i, chunk := range plan.Chunks {
+		if stored, ok := store.Lookup(chunk.SHA256); ok {
+			if _, err := c.GetBlobStatusContext(ctx, stored.BlobID); err == nil {
+				reused[len(manifest.Parts)] = true
+				manifest.Parts = append(manifest.Parts, ChunkPart{
+					BlobID:      stored.BlobID,
+					Size:        chunk.Size,
+					SHA256:      chunk.SHA256,
+					SuiObjectID: stored.SuiObjectID,
+				})
+				if progress != nil {
+					progress.Add64(chunk.Size)
+				}
+				store.mu.Lock()
+				store.SavedBytes += chunk.Size
+				store.mu.Unlock()
+				continue
+			} else if ctx.Err() != nil {
+				return nil, manifest, ctx.Err()
+			}
+			// The blob expired or was deleted; store the chunk again
+		}
+
+		part, resp, err := c.storePart(ctx, upload, chunk.Offset, chunk.Size, partOpts)
+		if err != nil {
+			return nil, manifest, fmt.Errorf("storing chunk %d of %d: %w", i+1, len(plan.Chunks), err)
+		}
+		if part.SHA256 != chunk.SHA256 {
+			return nil, manifest, errors.New("file changed while it was being uploaded")
+		}
+		manifest.Parts = append(manifest.Parts, *part)
+
+		stored := StoredChunk{BlobID: part.BlobID, Size: part.Size, SuiObjectID: part.SuiObjectID}
+		if resp.EndEpoch != nil {
+			stored.ExpiryEpoch = int(*resp.EndEpoch)
+			endEpoch = earliestEpoch(endEpoch, stored.ExpiryEpoch)
+			if !resp.AlreadyCertified {
+				// A new blob lasts the requested epochs from now
+				current = stored.ExpiryEpoch - opts.Epochs
+			}
+		}
+		store.Add(chunk.SHA256, stored)
+	}
+
+	// Referenced chunks have to last as long as the manifest, which is
+	// stored for the requested epochs from now; an unknown expiry counts as
+	// too short. Without the current epoch, from a chunk stored above or the
+	// walrus binary, every referenced chunk is sent again and the publisher
+	// only stores the ones that expire too soon.
+	if len(reused) > 0 && current < 0 {
+		if epoch, err := c.CurrentEpoch(ctx); err == nil {
+			current = epoch
+		}
+	}
+	refresh := upload
+	refresh.Progress = nil
+	checked := make(map[string]bool)
+	for i, chunk := range plan.Chunks {
+		if !reused[i] {
+			continue
+		}
+		// A chunk repeated in the file is only stored again once
+		stored, _ := store.Lookup(chunk.SHA256)
+		if !checked[chunk.SHA256] && (current < 0 || stored.ExpiryEpoch < current+opts.Epochs) {
+			checked[chunk.SHA256] = true
+			part, partResp, partErr := c.storePart(ctx, refresh, chunk.Offset, chunk.Size, partOpts)
+			if partErr != nil {
+				return nil, manifest, fmt.Errorf("storing chunk %d of %d again, as it expires at epoch %d: %w", i+1, len(plan.Chunks), stored.ExpiryEpoch, partErr)
+			}
+			if part.SHA256 != chunk.SHA256 || part.BlobID != stored.BlobID {
+				return nil, manifest, errors.New("file changed while it was being uploaded")
+			}
+			if part.SuiObjectID != "" {
+				// Already certified for long enough answers without an object
+				stored.SuiObjectID = part.SuiObjectID
+			}
+			stored.ExpiryEpoch = 0
+			if partResp.EndEpoch != nil {
+				stored.ExpiryEpoch = int(*partResp.EndEpoch)
+			}
+			store.Add(chunk.SHA256, stored)
+			if !partResp.AlreadyCertified {
+				store.mu.Lock()
+				store.SavedBytes -= chunk.Size
+				store.mu.Unlock()
+			}
+		}
+		// The new blob object is the one to extend or delete from now on
+		manifest.Parts[i].SuiObjectID = stored.SuiObjectID
+		endEpoch = earliestEpoch(endEpoch, stored.ExpiryEpoch)
+	}
+
+	resp, manifest, err := c.storeManifest(ctx, manifest, opts)
+	if resp == nil || resp.EndEpoch == nil {
+		return resp, manifest, err
+	}
+	endEpoch = earliestEpoch(endEpoch, int(*resp.EndEpoch))
+	end := int64(endEpoch)
+	resp.EndEpoch = &end
+	return resp, manifest, err
+}
+
+// earliestEpoch returns the earlier of two epochs, where 0 means unknown
+func earliestEpoch(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// EstimateDedupCost estimates the cost of storing plan for epochs: each new
+// chunk is a blob of its own, and so is the manifest. Stored chunks that
+// expire too soon and have to be stored again are not counted.
+func (c *WalrusClient) EstimateDedupCost(plan *DedupPlan, epochs int) (int64, error) {
+	// A manifest entry takes about 200 bytes
+	total, err := c.EstimateStorageCost(int64(len(plan.Chunks))*200, epochs)
+	if err != nil {
+		return 0, err
+	}
+	for _, size := range plan.NewSizes() {
+		cost, err := c.EstimateStorageCost(size, epochs)
+		if err != nil {
+			return 0, err
+		}
+		total += cost
+	}
+	return total, nil
+}
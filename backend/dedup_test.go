@@ -0,0 +1,202 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStoreDeduplicatedRestoresExpiringChunks(t *testing.T) {
+	fw, client := newFakeWalrus(t)
+	client.DedupChunkSize = 16 << 10
+	store, err := LoadChunkStore(filepath.Join(t.TempDir(), "chunks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 256<<10)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	upload := func(epochs int) (*StoreResponse, *ChunkManifest, int) {
+		t.Helper()
+		plan, err := client.PlanDedup(bytes.NewReader(data), store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := fw.stores
+		resp, manifest, err := client.StoreDeduplicatedContext(context.Background(), bytes.NewReader(data), plan, store, StoreOptions{Epochs: epochs}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, manifest, fw.stores - before
+	}
+
+	resp, manifest, stores := upload(5)
+	if stores != len(manifest.Parts)+1 {
+		t.Fatalf("first upload stored %d blobs, want %d", stores, len(manifest.Parts)+1)
+	}
+	if *resp.EndEpoch != 5 {
+		t.Fatalf("EndEpoch = %d, want 5", *resp.EndEpoch)
+	}
+
+	// Every chunk is stored until epoch 5, which is too soon for a file
+	// stored until epoch 13
+	fw.epoch = 3
+	resp, manifest, stores = upload(10)
+	if stores != len(manifest.Parts)+1 {
+		t.Fatalf("second upload stored %d blobs, want every chunk again and the manifest (%d)", stores, len(manifest.Parts)+1)
+	}
+	if *resp.EndEpoch != 13 {
+		t.Fatalf("EndEpoch = %d, want 13", *resp.EndEpoch)
+	}
+	var onWalrus ChunkManifest
+	if err := json.Unmarshal(fw.blobs[resp.BlobID], &onWalrus); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(onWalrus.Parts, manifest.Parts) {
+		t.Fatal("the stored manifest does not name the chunks' new blob objects")
+	}
+	for i, part := range manifest.Parts {
+		if end := fw.end(part.BlobID); end != 13 {
+			t.Errorf("part %d expires at epoch %d, want 13", i, end)
+		}
+		if stored, _ := store.Lookup(part.SHA256); stored.ExpiryEpoch != 13 {
+			t.Errorf("chunk store has part %d expiring at epoch %d, want 13", i, stored.ExpiryEpoch)
+		}
+	}
+
+	// A shorter upload reuses the chunks as they are, and the manifest
+	// naming them is already stored too
+	resp, _, stores = upload(2)
+	if stores != 0 {
+		t.Fatalf("third upload stored %d blobs, want none", stores)
+	}
+	if *resp.EndEpoch != 13 {
+		t.Fatalf("EndEpoch = %d, want 13", *resp.EndEpoch)
+	}
+}
+
+func TestStoreDeduplicatedStoresNoManifestWhenARefreshFails(t *testing.T) {
+	fw, client := newFakeWalrus(t)
+	client.DedupChunkSize = 16 << 10
+	store, err := LoadChunkStore(filepath.Join(t.TempDir(), "chunks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 64<<10)
+	rand.New(rand.NewSource(5)).Read(data)
+
+	// Every chunk is stored until epoch 4, and one of them cannot be stored
+	// again
+	plan, err := client.PlanDedup(bytes.NewReader(data), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range plan.Chunks {
+		id := fw.put(data[chunk.Offset:chunk.Offset+chunk.Size], 4)
+		store.Add(chunk.SHA256, StoredChunk{BlobID: id, Size: chunk.Size, ExpiryEpoch: 4})
+	}
+	last := plan.Chunks[len(plan.Chunks)-1]
+	fw.refuse[fakeBlobID(data[last.Offset:last.Offset+last.Size])] = true
+	plan, _ = client.PlanDedup(bytes.NewReader(data), store)
+
+	resp, _, err := client.StoreDeduplicatedContext(context.Background(), bytes.NewReader(data), plan, store, StoreOptions{Epochs: 10}, nil)
+	if err == nil || resp != nil {
+		t.Fatalf("upload returned %+v, %v; want an error", resp, err)
+	}
+	if fw.objects != len(plan.Chunks)-1 {
+		t.Fatalf("%d blobs stored, want the refreshed chunks and no manifest", fw.objects)
+	}
+}
+
+func TestStoreDeduplicatedReportsEarliestExpiry(t *testing.T) {
+	fw, client := newFakeWalrus(t)
+	client.DedupChunkSize = 16 << 10
+	store, err := LoadChunkStore(filepath.Join(t.TempDir(), "chunks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 64<<10)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	plan, err := client.PlanDedup(bytes.NewReader(data), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One chunk is already stored until epoch 20, while the store's record
+	// of it is out of date and still says epoch 4
+	chunk := plan.Chunks[0]
+	id := fw.put(data[chunk.Offset:chunk.Offset+chunk.Size], 20)
+	store.Add(chunk.SHA256, StoredChunk{BlobID: id, Size: chunk.Size, SuiObjectID: "0xold", ExpiryEpoch: 4})
+	plan, _ = client.PlanDedup(bytes.NewReader(data), store)
+
+	resp, _, err := client.StoreDeduplicatedContext(context.Background(), bytes.NewReader(data), plan, store, StoreOptions{Epochs: 8}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *resp.EndEpoch != 8 {
+		t.Fatalf("EndEpoch = %d, want 8", *resp.EndEpoch)
+	}
+	if stored, _ := store.Lookup(chunk.SHA256); stored.ExpiryEpoch != 20 || stored.SuiObjectID != "0xold" {
+		t.Fatalf("chunk store has %+v, want the old object expiring at epoch 20", stored)
+	}
+}
+
+// chunks splits data with a Chunker and returns copies of the chunks
+func chunks(t *testing.T, data []byte, avg int) [][]byte {
+	t.Helper()
+	var out [][]byte
+	c := NewChunker(bytes.NewReader(data), avg)
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, append([]byte(nil), chunk...))
+	}
+}
+
+func TestChunkerBoundariesAreStable(t *testing.T) {
+	const avg = 4 << 10
+	data := make([]byte, 512<<10)
+	rand.New(rand.NewSource(3)).Read(data)
+
+	before := chunks(t, data, avg)
+	if !bytes.Equal(bytes.Join(before, nil), data) {
+		t.Fatal("chunks do not add up to the input")
+	}
+	for i, chunk := range before {
+		if len(chunk) > 4*avg || (len(chunk) < avg/4 && i < len(before)-1) {
+			t.Fatalf("chunk %d is %d bytes, outside [%d, %d]", i, len(chunk), avg/4, 4*avg)
+		}
+	}
+	if again := chunks(t, data, avg); !reflect.DeepEqual(again, before) {
+		t.Fatal("the same input chunked differently")
+	}
+
+	// Insert a few bytes in the middle: only the chunks around the
+	// insertion may change
+	edited := append(append(append([]byte(nil), data[:200<<10]...), "inserted"...), data[200<<10:]...)
+	after := chunks(t, edited, avg)
+
+	seen := make(map[string]bool)
+	for _, chunk := range before {
+		seen[string(chunk)] = true
+	}
+	changed := 0
+	for _, chunk := range after {
+		if !seen[string(chunk)] {
+			changed++
+		}
+	}
+	if changed == 0 || changed > 2 {
+		t.Fatalf("%d of %d chunks changed after an insertion, want 1 or 2", changed, len(after))
+	}
+}
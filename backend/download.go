@@ -0,0 +1,304 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errRangeNotSatisfiable is returned when the aggregator rejects a Range
+// request, which happens when resuming a download that is already complete
+var errRangeNotSatisfiable = errors.New("requested range not satisfiable")
+
+// ByteRange selects an inclusive range of bytes within a blob.
+// An End of -1 means "through the end of the blob".
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+// ParseByteRange parses "start-end" or "start-" into a ByteRange
+func ParseByteRange(spec string) (*ByteRange, error) {
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok || startStr == "" {
+		return nil, fmt.Errorf("invalid range %q (expected start-end or start-)", spec)
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return nil, fmt.Errorf("invalid range start %q", startStr)
+	}
+
+	rng := &ByteRange{Start: start, End: -1}
+	if endStr != "" {
+		end, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid range end %q", endStr)
+		}
+		rng.End = end
+	}
+
+	return rng, nil
+}
+
+// String formats the range the same way ParseByteRange accepts it
+func (r ByteRange) String() string {
+	if r.End < 0 {
+		return fmt.Sprintf("%d-", r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// DownloadOptions controls DownloadBlobToFile
+type DownloadOptions struct {
+	Resume     bool           // Continue from an existing .part file
+	Range      *ByteRange     // Fetch only this part of the blob
+	Progress   io.Writer      // Receives every newly downloaded byte (e.g. a progress bar)
+	Decrypt    *EncryptionKey // Decrypt the blob with this key; cannot be combined with Resume or Range
+	Decompress string         // Decompress the blob with this codec, after decrypting; cannot be combined with Resume or Range
+}
+
+// decodes reports whether the download is transformed on the way to disk,
+// which rules out resuming it or fetching a range
+func (o DownloadOptions) decodes() bool {
+	return o.Decrypt != nil || o.Decompress != ""
+}
+
+// PartialPath returns the staging file used while downloading to path
+func PartialPath(path string) string {
+	return path + ".part"
+}
+
+// RetrieveBlobTo streams a blob into w and returns the number of bytes written.
+// Dropped connections are resumed with a Range request from the last byte received.
+func (c *WalrusClient) RetrieveBlobTo(blobID string, w io.Writer) (int64, error) {
+	return c.RetrieveBlobToContext(context.Background(), blobID, w)
+}
+
+// RetrieveBlobToContext is RetrieveBlobTo bound to ctx
+func (c *WalrusClient) RetrieveBlobToContext(ctx context.Context, blobID string, w io.Writer) (int64, error) {
+	return c.streamBlob(ctx, blobResource(blobID), 0, -1, w)
+}
+
+// RetrieveBlobRange streams the requested byte range of a blob into w
+func (c *WalrusClient) RetrieveBlobRange(blobID string, rng ByteRange, w io.Writer) (int64, error) {
+	return c.RetrieveBlobRangeContext(context.Background(), blobID, rng, w)
+}
+
+// RetrieveBlobRangeContext is RetrieveBlobRange bound to ctx
+func (c *WalrusClient) RetrieveBlobRangeContext(ctx context.Context, blobID string, rng ByteRange, w io.Writer) (int64, error) {
+	return c.streamBlob(ctx, blobResource(blobID), rng.Start, rng.End, w)
+}
+
+// DownloadBlobToFile streams a blob into path. Data is staged in a .part file
+// that is renamed into place once complete, so an interrupted download can be
+// resumed later with opts.Resume.
+func (c *WalrusClient) DownloadBlobToFile(blobID, path string, opts DownloadOptions) (int64, error) {
+	return c.DownloadBlobToFileContext(context.Background(), blobID, path, opts)
+}
+
+// DownloadBlobToFileContext is DownloadBlobToFile bound to ctx. A cancelled
+// download leaves its .part file behind so it can be resumed.
+func (c *WalrusClient) DownloadBlobToFileContext(ctx context.Context, blobID, path string, opts DownloadOptions) (int64, error) {
+	return c.downloadToFile(ctx, blobResource(blobID), path, opts)
+}
+
+// downloadToFile streams the aggregator resource into path via a .part file
+func (c *WalrusClient) downloadToFile(ctx context.Context, resource, path string, opts DownloadOptions) (int64, error) {
+	if opts.decodes() && (opts.Resume || opts.Range != nil) {
+		return 0, errors.New("an encrypted or compressed download can only be fetched whole, without resume or a range")
+	}
+	partPath := PartialPath(path)
+
+	var offset int64
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if opts.Resume {
+		if stat, err := os.Stat(partPath); err == nil {
+			offset = stat.Size()
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("opening %s: %w", partPath, err)
+	}
+
+	start, end := offset, int64(-1)
+	if opts.Range != nil {
+		start += opts.Range.Start
+		end = opts.Range.End
+	}
+
+	// Decrypted downloads only ever hold authenticated plaintext, and
+	// decoded downloads are not kept when they fail since they cannot be
+	// resumed
+	var w io.Writer = file
+	var finish func() (int64, error)
+	if opts.decodes() {
+		if w, finish, err = decodingWriter(file, opts.Decrypt, opts.Decompress); err != nil {
+			file.Close()
+			return 0, err
+		}
+	}
+	if opts.Progress != nil {
+		w = io.MultiWriter(w, opts.Progress)
+	}
+
+	var n int64
+	if end < 0 || start <= end {
+		n, err = c.streamBlob(ctx, resource, start, end, w)
+		if errors.Is(err, errRangeNotSatisfiable) && offset > 0 {
+			// The .part file already holds everything the aggregator has
+			err = nil
+		}
+	}
+	if finish != nil {
+		var finishErr error
+		n, finishErr = finish()
+		if err == nil {
+			err = finishErr
+		}
+	}
+	if err != nil {
+		file.Close()
+		if finish != nil {
+			os.Remove(partPath)
+		}
+		return offset + n, err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return offset + n, fmt.Errorf("syncing %s: %w", partPath, err)
+	}
+	if err := file.Close(); err != nil {
+		return offset + n, fmt.Errorf("closing %s: %w", partPath, err)
+	}
+
+	if err := os.Rename(partPath, path); err != nil {
+		return offset + n, fmt.Errorf("moving %s into place: %w", partPath, err)
+	}
+
+	return offset + n, nil
+}
+
+// blobResource is the aggregator path serving a whole blob
+func blobResource(blobID string) string {
+	return "/v1/blobs/" + url.PathEscape(blobID)
+}
+
+// streamBlob copies bytes [start, end] of an aggregator resource into w,
+// retrying transient failures and resuming from the last byte written instead
+// of starting over. Each retry moves to the next aggregator in the pool.
+func (c *WalrusClient) streamBlob(ctx context.Context, resource string, start, end int64, w io.Writer) (int64, error) {
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	var written int64
+	err := c.withEndpoints(ctx, c.aggregatorPool(), func(ctx context.Context, attempt int, baseURL string) error {
+		n, err := c.fetchBlobOnce(ctx, baseURL, resource, start+written, end, w)
+		written += n
+		return err
+	})
+	return written, err
+}
+
+// fetchBlobOnce performs a single GET for bytes [start, end] of a resource.
+// Failures after a partial write are marked retryable so the next attempt
+// picks up from the new offset.
+func (c *WalrusClient) fetchBlobOnce(ctx context.Context, baseURL, resource string, start, end int64, w io.Writer) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+resource, nil)
+	if err != nil {
+		return 0, Permanent(fmt.Errorf("creating request: %w", err))
+	}
+
+	ranged := start > 0 || end >= 0
+	if ranged {
+		req.Header.Set("Range", "bytes="+ByteRange{Start: start, End: end}.String())
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("retrieving blob: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if got, ok := contentRangeStart(resp.Header.Get("Content-Range")); ok && got != start {
+			return 0, Permanent(fmt.Errorf("aggregator returned range starting at %d, expected %d", got, start))
+		}
+	case http.StatusOK:
+		if ranged {
+			// The aggregator ignored the Range header; skip to the offset ourselves
+			if _, err := io.CopyN(io.Discard, resp.Body, start); err != nil {
+				return 0, Retryable(fmt.Errorf("skipping to offset %d: %w", start, err))
+			}
+			if end >= 0 {
+				body = io.LimitReader(resp.Body, end-start+1)
+			}
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, Permanent(errRangeNotSatisfiable)
+	default:
+		return 0, newHTTPStatusError("retrieval", resp)
+	}
+
+	dst := &trackingWriter{w: w}
+	n, err := io.Copy(dst, body)
+	if err != nil {
+		if dst.err != nil {
+			return n, Permanent(fmt.Errorf("writing blob data: %w", err))
+		}
+		// The connection dropped mid-body; resume from what we have
+		return n, Retryable(fmt.Errorf("reading blob data: %w", err))
+	}
+
+	if resp.StatusCode == http.StatusPartialContent || !ranged {
+		if resp.ContentLength >= 0 && n < resp.ContentLength {
+			return n, Retryable(fmt.Errorf("reading blob data: %w", io.ErrUnexpectedEOF))
+		}
+	}
+
+	return n, nil
+}
+
+// contentRangeStart extracts the first byte offset from a Content-Range header
+func contentRangeStart(header string) (int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	startStr, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
+
+// trackingWriter remembers write errors so they can be told apart from read
+// errors after io.Copy returns
+type trackingWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if err != nil {
+		t.err = err
+	}
+	return n, err
+}
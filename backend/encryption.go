@@ -0,0 +1,408 @@
+package backend
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// EncryptionScheme names the format written by the encrypting reader: a
+// header followed by AES-256-GCM sealed chunks
+const EncryptionScheme = "aes-256-gcm-stream-v1"
+
+// Key sources recorded with encrypted blobs
+const (
+	KeySourceFile       = "key-file"
+	KeySourcePassphrase = "passphrase"
+)
+
+// An encrypted blob starts with a header that is also the additional data of
+// every chunk, so it cannot be altered either:
+//
+//	magic       8 bytes  "WALRUSE1"
+//	kdf         1 byte   kdfNone for key files, kdfPBKDF2 for passphrases
+//	reserved    3 bytes
+//	iterations  4 bytes  PBKDF2 iterations
+//	kdf salt   16 bytes  PBKDF2 salt
+//	key ID      8 bytes  Fingerprint of the master key
+//	file salt  32 bytes  Derives this blob's own key from the master key
+//	chunk size  4 bytes  Plaintext bytes per chunk
+//
+// The plaintext follows in chunks of chunk size bytes, each sealed with a
+// nonce made of its index and a flag marking the last one. The last chunk is
+// always shorter than chunk size, and may be empty, so a blob cut off at a
+// chunk boundary fails to decrypt instead of looking complete.
+const (
+	encryptionMagic      = "WALRUSE1"
+	encryptionHeaderSize = 8 + 1 + 3 + 4 + 16 + 8 + 32 + 4
+	encryptionChunkSize  = 64 * 1024
+	encryptionMaxChunk   = 16 * 1024 * 1024
+	encryptionTagSize    = 16
+
+	kdfNone   = 0
+	kdfPBKDF2 = 1
+
+	// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256
+	pbkdf2Iterations = 600000
+)
+
+// EncryptionKey is the master key blobs are encrypted with. Every blob gets
+// its own key, derived from the master key and a random salt in its header.
+type EncryptionKey struct {
+	source string
+
+	// Key files
+	master []byte
+
+	// Passphrases: salt is used when encrypting, derived caches the master
+	// key for every salt seen so far
+	passphrase []byte
+	salt       []byte
+	mu         sync.Mutex
+	derived    map[string][]byte
+}
+
+// EncryptionInfo records how a blob was encrypted, so it can be decrypted later
+type EncryptionInfo struct {
+	Scheme    string `json:"scheme"`
+	KeyID     string `json:"key_id"`
+	KeySource string `json:"key_source"` // "key-file" or "passphrase"
+}
+
+// LoadKeyFile reads a 256-bit key stored as 32 raw bytes, 64 hex characters
+// or base64
+func LoadKeyFile(path string) (*EncryptionKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+	key, err := parseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	return &EncryptionKey{source: KeySourceFile, master: key}, nil
+}
+
+func parseKey(data []byte) ([]byte, error) {
+	if len(data) == 32 {
+		return data, nil
+	}
+	text := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("must hold a 256-bit key as 32 raw bytes, 64 hex characters or base64")
+}
+
+// NewPassphraseKey stretches passphrase into a master key with PBKDF2. The
+// work is done on first use, since it takes a noticeable moment.
+func NewPassphraseKey(passphrase string) (*EncryptionKey, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generating salt: %w", err)
+	}
+	return &EncryptionKey{
+		source:     KeySourcePassphrase,
+		passphrase: []byte(passphrase),
+		salt:       salt,
+		derived:    make(map[string][]byte),
+	}, nil
+}
+
+// Source returns KeySourceFile or KeySourcePassphrase
+func (k *EncryptionKey) Source() string {
+	return k.source
+}
+
+// ID returns the fingerprint of the key new blobs are encrypted with. Blobs
+// encrypted with the same passphrase in different runs have different IDs,
+// because each run picks a new salt.
+func (k *EncryptionKey) ID() string {
+	_, _, master := k.encryptionMaster()
+	return keyID(master)
+}
+
+// Info describes blobs encrypted with this key, for the index
+func (k *EncryptionKey) Info() *EncryptionInfo {
+	return &EncryptionInfo{Scheme: EncryptionScheme, KeyID: k.ID(), KeySource: k.source}
+}
+
+// encryptionMaster returns the KDF, its salt and the master key to encrypt with
+func (k *EncryptionKey) encryptionMaster() (byte, []byte, []byte) {
+	if k.source == KeySourceFile {
+		return kdfNone, make([]byte, 16), k.master
+	}
+	return kdfPBKDF2, k.salt, k.passphraseMaster(k.salt, pbkdf2Iterations)
+}
+
+func (k *EncryptionKey) passphraseMaster(salt []byte, iterations int) []byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	id := fmt.Sprintf("%x/%d", salt, iterations)
+	if master, ok := k.derived[id]; ok {
+		return master
+	}
+	master := pbkdf2.Key(k.passphrase, salt, iterations, 32, sha256.New)
+	k.derived[id] = master
+	return master
+}
+
+// EncryptedSize returns how many bytes encrypting size bytes of plaintext produces
+func EncryptedSize(size int64) int64 {
+	chunks := size/encryptionChunkSize + 1
+	return encryptionHeaderSize + size + chunks*encryptionTagSize
+}
+
+// NewEncryptingReader returns a reader of r's content encrypted with key.
+// It reads r one chunk at a time, so only a chunk is held in memory.
+func NewEncryptingReader(r io.Reader, key *EncryptionKey) (io.Reader, error) {
+	kdf, kdfSalt, master := key.encryptionMaster()
+
+	header := make([]byte, 0, encryptionHeaderSize)
+	header = append(header, encryptionMagic...)
+	header = append(header, kdf, 0, 0, 0)
+	iterations := 0
+	if kdf == kdfPBKDF2 {
+		iterations = pbkdf2Iterations
+	}
+	header = binary.BigEndian.AppendUint32(header, uint32(iterations))
+	header = append(header, kdfSalt...)
+	id, _ := hex.DecodeString(keyID(master))
+	header = append(header, id...)
+	fileSalt := make([]byte, 32)
+	if _, err := rand.Read(fileSalt); err != nil {
+		return nil, fmt.Errorf("generating salt: %w", err)
+	}
+	header = append(header, fileSalt...)
+	header = binary.BigEndian.AppendUint32(header, encryptionChunkSize)
+
+	aead, err := fileCipher(master, fileSalt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptingReader{
+		src:    r,
+		aead:   aead,
+		header: header,
+		plain:  make([]byte, encryptionChunkSize),
+		sealed: make([]byte, 0, encryptionChunkSize+encryptionTagSize),
+		out:    header,
+	}, nil
+}
+
+type encryptingReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	header  []byte
+	plain   []byte
+	sealed  []byte
+	out     []byte // Encrypted bytes not yet read
+	counter uint64
+	done    bool
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.seal(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// seal encrypts the next chunk. A chunk that could not be filled is the last.
+func (r *encryptingReader) seal() error {
+	n, err := io.ReadFull(r.src, r.plain)
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		r.done = true
+	default:
+		return err
+	}
+	r.out = r.aead.Seal(r.sealed[:0], chunkNonce(r.counter, r.done), r.plain[:n], r.header)
+	r.counter++
+	return nil
+}
+
+// DecryptingWriter decrypts what is written to it into another writer. Close
+// must be called at the end of the stream: it checks the last chunk, so a
+// truncated blob is an error rather than a short file.
+type DecryptingWriter struct {
+	w       io.Writer
+	key     *EncryptionKey
+	header  []byte
+	aead    cipher.AEAD
+	chunk   int // Length of a full sealed chunk
+	buf     []byte
+	counter uint64
+	n       int64
+}
+
+// NewDecryptingWriter returns a writer that decrypts into w with key
+func NewDecryptingWriter(w io.Writer, key *EncryptionKey) *DecryptingWriter {
+	return &DecryptingWriter{w: w, key: key}
+}
+
+// Written returns the number of plaintext bytes written to the underlying writer
+func (d *DecryptingWriter) Written() int64 {
+	return d.n
+}
+
+func (d *DecryptingWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	if d.aead == nil {
+		need := encryptionHeaderSize - len(d.header)
+		if len(p) < need {
+			d.header = append(d.header, p...)
+			return total, nil
+		}
+		d.header = append(d.header, p[:need]...)
+		p = p[need:]
+		if err := d.start(); err != nil {
+			return 0, err
+		}
+	}
+
+	// A full chunk is never the last one, so it can be opened right away
+	for len(p) > 0 {
+		n := copy(d.buf[len(d.buf):d.chunk], p)
+		d.buf = d.buf[:len(d.buf)+n]
+		p = p[n:]
+		if len(d.buf) == d.chunk {
+			if err := d.open(false); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// Close opens the last chunk. It does not close the underlying writer.
+func (d *DecryptingWriter) Close() error {
+	if d.aead == nil {
+		return errors.New("encrypted blob is truncated: incomplete header")
+	}
+	if len(d.buf) < encryptionTagSize {
+		return errors.New("encrypted blob is truncated: last chunk missing")
+	}
+	return d.open(true)
+}
+
+// start parses the header and sets up the blob's key
+func (d *DecryptingWriter) start() error {
+	h := d.header
+	if string(h[:8]) != encryptionMagic {
+		return errors.New("not an encrypted blob (unknown header)")
+	}
+	kdf := h[8]
+	iterations := int(binary.BigEndian.Uint32(h[12:16]))
+	kdfSalt := h[16:32]
+	id := hex.EncodeToString(h[32:40])
+	fileSalt := h[40:72]
+	chunkSize := int(binary.BigEndian.Uint32(h[72:76]))
+	if chunkSize <= 0 || chunkSize > encryptionMaxChunk {
+		return fmt.Errorf("encrypted blob has an invalid chunk size %d", chunkSize)
+	}
+
+	var master []byte
+	switch {
+	case kdf == kdfNone && d.key.source == KeySourceFile:
+		master = d.key.master
+		if keyID(master) != id {
+			return fmt.Errorf("blob was encrypted with key %s, not with this key file (%s)", id, keyID(master))
+		}
+	case kdf == kdfPBKDF2 && d.key.source == KeySourcePassphrase:
+		if iterations <= 0 {
+			return fmt.Errorf("encrypted blob has invalid KDF iterations %d", iterations)
+		}
+		master = d.key.passphraseMaster(kdfSalt, iterations)
+		if keyID(master) != id {
+			return errors.New("wrong passphrase for this blob")
+		}
+	case kdf == kdfNone:
+		return errors.New("blob was encrypted with a key file, not a passphrase")
+	case kdf == kdfPBKDF2:
+		return errors.New("blob was encrypted with a passphrase, not a key file")
+	default:
+		return fmt.Errorf("encrypted blob uses unknown key derivation %d", kdf)
+	}
+
+	aead, err := fileCipher(master, fileSalt)
+	if err != nil {
+		return err
+	}
+	d.aead = aead
+	d.chunk = chunkSize + encryptionTagSize
+	d.buf = make([]byte, 0, d.chunk)
+	return nil
+}
+
+// open decrypts the buffered chunk and passes it on
+func (d *DecryptingWriter) open(last bool) error {
+	plain, err := d.aead.Open(d.buf[:0], chunkNonce(d.counter, last), d.buf, d.header)
+	if err != nil {
+		return fmt.Errorf("chunk %d failed authentication: the blob is corrupt or truncated", d.counter)
+	}
+	n, err := d.w.Write(plain)
+	d.n += int64(n)
+	if err != nil {
+		return err
+	}
+	d.buf = d.buf[:0]
+	d.counter++
+	return nil
+}
+
+// fileCipher derives a blob's own key from the master key and its salt
+func fileCipher(master, fileSalt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte("walrus-cli file key"))
+	mac.Write(fileSalt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce is the GCM nonce of chunk i: its index, and a flag on the last
+func chunkNonce(i uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, i)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// keyID is a short fingerprint of a master key that does not reveal it
+func keyID(master []byte) string {
+	sum := sha256.Sum256(append([]byte("walrus-cli key id"), master...))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,179 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKey loads a key file holding 32 bytes of seed, hex encoded
+func testKey(t *testing.T, seed byte) *EncryptionKey {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(bytes.Repeat([]byte{seed}, 32))+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encrypt(t *testing.T, data []byte, key *EncryptionKey) []byte {
+	t.Helper()
+	r, err := NewEncryptingReader(bytes.NewReader(data), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+// decrypt writes blob in small pieces, so the header and chunks are split
+// across writes
+func decrypt(blob []byte, key *EncryptionKey) ([]byte, error) {
+	var out bytes.Buffer
+	d := NewDecryptingWriter(&out, key)
+	for len(blob) > 0 {
+		n := min(len(blob), 1000)
+		if _, err := d.Write(blob[:n]); err != nil {
+			return nil, err
+		}
+		blob = blob[n:]
+	}
+	if err := d.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func TestPassphraseMasterMatchesRFC7914(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 test vectors from RFC 7914, section 11
+	tests := []struct {
+		passphrase, salt string
+		iterations       int
+		want             string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, tt := range tests {
+		key, err := NewPassphraseKey(tt.passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The master key is the first 32 bytes of the 64 in the vector
+		if got := hex.EncodeToString(key.passphraseMaster([]byte(tt.salt), tt.iterations)); got != tt.want[:64] {
+			t.Errorf("%q/%q: master key %s, want %s", tt.passphrase, tt.salt, got, tt.want[:64])
+		}
+	}
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	key := testKey(t, 1)
+	for _, size := range []int{0, 1, encryptionChunkSize - 1, encryptionChunkSize, encryptionChunkSize + 1, 3*encryptionChunkSize + 5} {
+		data := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(data)
+
+		blob := encrypt(t, data, key)
+		if int64(len(blob)) != EncryptedSize(int64(size)) {
+			t.Errorf("size %d: encrypted to %d bytes, EncryptedSize says %d", size, len(blob), EncryptedSize(int64(size)))
+		}
+		got, err := decrypt(blob, key)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("size %d: decrypted content differs", size)
+		}
+	}
+}
+
+func TestPassphraseRoundTrip(t *testing.T) {
+	key, err := NewPassphraseKey("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("secret")
+	blob := encrypt(t, data, key)
+
+	// Another run with the same passphrase picks a new salt, and reads the
+	// one in the header to decrypt
+	again, _ := NewPassphraseKey("correct horse battery staple")
+	got, err := decrypt(blob, again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("decrypted %q, want %q", got, data)
+	}
+
+	wrong, _ := NewPassphraseKey("incorrect horse")
+	if _, err := decrypt(blob, wrong); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("wrong passphrase: err = %v", err)
+	}
+}
+
+func TestDecryptRejectsTruncatedBlob(t *testing.T) {
+	key := testKey(t, 1)
+	data := make([]byte, 2*encryptionChunkSize+100)
+	rand.New(rand.NewSource(4)).Read(data)
+	blob := encrypt(t, data, key)
+
+	sealed := encryptionChunkSize + encryptionTagSize
+	for _, tt := range []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"inside the header", encryptionHeaderSize - 1},
+		{"after the header", encryptionHeaderSize},
+		{"at a chunk boundary", encryptionHeaderSize + 2*sealed},
+		{"inside the final chunk", len(blob) - 1},
+	} {
+		if _, err := decrypt(blob[:tt.size], key); err == nil {
+			t.Errorf("blob cut %s (%d bytes) decrypted", tt.name, tt.size)
+		}
+	}
+}
+
+func TestDecryptRejectsWrongKey(t *testing.T) {
+	blob := encrypt(t, []byte("secret"), testKey(t, 1))
+
+	if _, err := decrypt(blob, testKey(t, 2)); err == nil || !strings.Contains(err.Error(), "not with this key file") {
+		t.Errorf("other key file: err = %v", err)
+	}
+	passphrase, _ := NewPassphraseKey("secret")
+	if _, err := decrypt(blob, passphrase); err == nil || !strings.Contains(err.Error(), "encrypted with a key file") {
+		t.Errorf("passphrase for a key file blob: err = %v", err)
+	}
+}
+
+func TestDecryptRejectsTamperedHeader(t *testing.T) {
+	key := testKey(t, 1)
+	blob := encrypt(t, []byte("secret"), key)
+
+	// Every field of the header is covered, either by a check of its own or
+	// by being the additional data of each chunk
+	for _, offset := range []int{0, 8, 9, 12, 16, 32, 40, 72, 75} {
+		tampered := bytes.Clone(blob)
+		tampered[offset] ^= 1
+		if _, err := decrypt(tampered, key); err == nil {
+			t.Errorf("blob with header byte %d changed decrypted", offset)
+		}
+	}
+	tampered := bytes.Clone(blob)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := decrypt(tampered, key); err == nil {
+		t.Error("blob with a changed tag decrypted")
+	}
+}
@@ -0,0 +1,389 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultFailureThreshold is how many consecutive failures open an endpoint's circuit
+	defaultFailureThreshold = 3
+	// defaultBreakerCooldown is how long an open circuit waits before a trial request
+	defaultBreakerCooldown = 30 * time.Second
+	// latencyDecay weights the newest sample in the moving latency average
+	latencyDecay = 0.3
+	// unknownLatency stands in for endpoints that have not been measured yet
+	unknownLatency = 500 * time.Millisecond
+)
+
+// CircuitState describes whether an endpoint is currently used for requests
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // Healthy, receives traffic
+	CircuitOpen                         // Failing, skipped until the cooldown ends
+	CircuitHalfOpen                     // Cooldown over, next request is a trial
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "healthy"
+	case CircuitOpen:
+		return "down"
+	case CircuitHalfOpen:
+		return "recovering"
+	default:
+		return "unknown"
+	}
+}
+
+// endpoint tracks the health of a single aggregator or publisher
+type endpoint struct {
+	url         string
+	state       CircuitState
+	failures    int
+	openedAt    time.Time
+	latency     time.Duration // Moving average, zero until measured
+	lastError   string
+	lastChecked time.Time
+}
+
+// EndpointStatus is a snapshot of one endpoint's health for display
+type EndpointStatus struct {
+	URL         string        `json:"url"`
+	State       CircuitState  `json:"-"`
+	Health      string        `json:"health"`
+	Latency     time.Duration `json:"latency"`
+	Failures    int           `json:"failures"`
+	LastError   string        `json:"lastError,omitempty"`
+	LastChecked time.Time     `json:"lastChecked,omitempty"`
+}
+
+// EndpointPool spreads requests over several equivalent endpoints, preferring
+// fast ones and taking failing ones out of rotation with a circuit breaker
+type EndpointPool struct {
+	FailureThreshold int           // Consecutive failures before an endpoint is taken out
+	Cooldown         time.Duration // Time an endpoint stays out before it is retried
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	now       func() time.Time
+}
+
+// NewEndpointPool creates a pool from urls, ignoring blanks and duplicates
+func NewEndpointPool(urls ...string) *EndpointPool {
+	pool := &EndpointPool{
+		FailureThreshold: defaultFailureThreshold,
+		Cooldown:         defaultBreakerCooldown,
+		now:              time.Now,
+	}
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		u = strings.TrimRight(strings.TrimSpace(u), "/")
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		pool.endpoints = append(pool.endpoints, &endpoint{url: u})
+	}
+	return pool
+}
+
+// Len returns the number of endpoints in the pool
+func (p *EndpointPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.endpoints)
+}
+
+// URLs returns the endpoint URLs in configuration order
+func (p *EndpointPool) URLs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	urls := make([]string, len(p.endpoints))
+	for i, ep := range p.endpoints {
+		urls[i] = ep.url
+	}
+	return urls
+}
+
+// Candidates returns endpoint URLs in the order they should be tried.
+// Healthy endpoints come first, picked at random weighted by inverse latency;
+// endpoints whose cooldown has passed follow, and open circuits come last so
+// a request is still attempted when everything looks down.
+func (p *EndpointPool) Candidates() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var healthy, trial, open []*endpoint
+	for _, ep := range p.endpoints {
+		switch p.stateLocked(ep, now) {
+		case CircuitClosed:
+			healthy = append(healthy, ep)
+		case CircuitHalfOpen:
+			trial = append(trial, ep)
+		default:
+			open = append(open, ep)
+		}
+	}
+
+	// Among open circuits, the one that failed longest ago recovers first
+	sort.Slice(open, func(i, j int) bool { return open[i].openedAt.Before(open[j].openedAt) })
+
+	ordered := weightedOrder(healthy)
+	ordered = append(ordered, trial...)
+	ordered = append(ordered, open...)
+
+	urls := make([]string, len(ordered))
+	for i, ep := range ordered {
+		urls[i] = ep.url
+	}
+	return urls
+}
+
+// Report records the outcome of a request against url. Only errors that say
+// something about the endpoint itself (network failures and 5xx/429 replies)
+// count towards opening its circuit; cancellations and client errors do not.
+func (p *EndpointPool) Report(url string, err error) {
+	if err != nil && !isEndpointFailure(err) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ep := p.findLocked(url)
+	if ep == nil {
+		return
+	}
+
+	now := p.now()
+	ep.lastChecked = now
+	if err == nil {
+		ep.state = CircuitClosed
+		ep.failures = 0
+		ep.lastError = ""
+		return
+	}
+
+	ep.failures++
+	ep.lastError = err.Error()
+	if p.stateLocked(ep, now) == CircuitHalfOpen || ep.failures >= p.threshold() {
+		ep.state = CircuitOpen
+		ep.openedAt = now
+	}
+}
+
+// ObserveLatency folds a round-trip time for url into its moving average
+func (p *EndpointPool) ObserveLatency(url string, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ep := p.findLocked(url)
+	if ep == nil || latency <= 0 {
+		return
+	}
+	if ep.latency == 0 {
+		ep.latency = latency
+		return
+	}
+	ep.latency = time.Duration(latencyDecay*float64(latency) + (1-latencyDecay)*float64(ep.latency))
+}
+
+// responseTimer measures how long an endpoint takes to start answering once
+// a request has been sent. Streaming the request and response bodies is left
+// out, so large transfers do not make an endpoint look slow.
+type responseTimer struct {
+	mu    sync.Mutex
+	sent  time.Time
+	taken time.Duration
+}
+
+// trace returns ctx with hooks that time the requests made with it
+func (t *responseTimer) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			t.sent = time.Now()
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			if !t.sent.IsZero() {
+				t.taken = time.Since(t.sent)
+			}
+			t.mu.Unlock()
+		},
+	})
+}
+
+// latency returns the last response time measured, or zero if no response
+// arrived
+func (t *responseTimer) latency() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.taken
+}
+
+// Probe checks every endpoint concurrently with a lightweight GET and updates
+// health and latency from the results
+func (p *EndpointPool) Probe(ctx context.Context, client *http.Client) {
+	var wg sync.WaitGroup
+	for _, url := range p.URLs() {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			start := time.Now()
+			err := probeEndpoint(ctx, client, url)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				p.ObserveLatency(url, time.Since(start))
+			}
+			p.Report(url, err)
+		}(url)
+	}
+	wg.Wait()
+}
+
+// Status returns a health snapshot of every endpoint in configuration order.
+// An endpoint with recent failures that has not been taken out yet is
+// reported as degraded.
+func (p *EndpointPool) Status() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	statuses := make([]EndpointStatus, len(p.endpoints))
+	for i, ep := range p.endpoints {
+		state := p.stateLocked(ep, now)
+		health := state.String()
+		if state == CircuitClosed && ep.failures > 0 {
+			// Still in rotation, but its last requests failed
+			health = "degraded"
+		}
+		statuses[i] = EndpointStatus{
+			URL:         ep.url,
+			State:       state,
+			Health:      health,
+			Latency:     ep.latency,
+			Failures:    ep.failures,
+			LastError:   ep.lastError,
+			LastChecked: ep.lastChecked,
+		}
+	}
+	return statuses
+}
+
+// stateLocked reports the effective circuit state, turning an open circuit
+// half-open once its cooldown has passed
+func (p *EndpointPool) stateLocked(ep *endpoint, now time.Time) CircuitState {
+	if ep.state == CircuitOpen && now.Sub(ep.openedAt) >= p.cooldown() {
+		return CircuitHalfOpen
+	}
+	return ep.state
+}
+
+func (p *EndpointPool) findLocked(url string) *endpoint {
+	url = strings.TrimRight(url, "/")
+	for _, ep := range p.endpoints {
+		if ep.url == url {
+			return ep
+		}
+	}
+	return nil
+}
+
+func (p *EndpointPool) threshold() int {
+	if p.FailureThreshold <= 0 {
+		return defaultFailureThreshold
+	}
+	return p.FailureThreshold
+}
+
+func (p *EndpointPool) cooldown() time.Duration {
+	if p.Cooldown <= 0 {
+		return defaultBreakerCooldown
+	}
+	return p.Cooldown
+}
+
+// weightedOrder shuffles endpoints so that each position is drawn with
+// probability proportional to 1/latency among those not yet placed
+func weightedOrder(endpoints []*endpoint) []*endpoint {
+	remaining := append([]*endpoint(nil), endpoints...)
+	ordered := make([]*endpoint, 0, len(remaining))
+	for len(remaining) > 0 {
+		weights := make([]float64, len(remaining))
+		var total float64
+		for i, ep := range remaining {
+			latency := ep.latency
+			if latency <= 0 {
+				latency = unknownLatency
+			}
+			weights[i] = 1 / latency.Seconds()
+			total += weights[i]
+		}
+
+		pick := len(remaining) - 1
+		r := rand.Float64() * total
+		for i, w := range weights {
+			if r < w {
+				pick = i
+				break
+			}
+			r -= w
+		}
+
+		ordered = append(ordered, remaining[pick])
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+	}
+	return ordered
+}
+
+// isEndpointFailure reports whether err reflects on the endpoint's health
+func isEndpointFailure(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+	var decision *retryDecision
+	if errors.As(err, &decision) && !decision.retry {
+		return false
+	}
+	return IsRetryable(err)
+}
+
+// probeEndpoint issues a GET for the endpoint's API description. Any reply
+// below 500 means the service is up and answering.
+func probeEndpoint(ctx context.Context, client *http.Client, baseURL string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/v1/api", nil)
+	if err != nil {
+		return Permanent(fmt.Errorf("creating request: %w", err))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("probing endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return newHTTPStatusError("health probe", resp)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestsMeasureEndpointLatency(t *testing.T) {
+	// The aggregator takes 50ms to answer, then another 200ms to send the
+	// body of a read
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Length", "4")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("data"))
+	}))
+	defer srv.Close()
+
+	client := NewWalrusClient(srv.URL, srv.URL)
+	if _, err := client.GetBlobStatusContext(context.Background(), "blob"); err != nil {
+		t.Fatal(err)
+	}
+	latency := client.Aggregators.Status()[0].Latency
+	if latency < 50*time.Millisecond || latency > 200*time.Millisecond {
+		t.Fatalf("latency after a status check = %s, want about 50ms", latency)
+	}
+
+	client.Aggregators = NewEndpointPool(srv.URL)
+	if _, err := client.RetrieveBlobToContext(context.Background(), "blob", io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	latency = client.Aggregators.Status()[0].Latency
+	if latency < 50*time.Millisecond || latency >= 200*time.Millisecond {
+		t.Fatalf("latency after a read = %s, want the time to the response without the body", latency)
+	}
+}
+
+func TestStatusReportsDegradedEndpoints(t *testing.T) {
+	pool := NewEndpointPool("https://a.example", "https://b.example")
+	failure := &HTTPStatusError{Op: "retrieval", StatusCode: http.StatusBadGateway}
+	pool.Report("https://a.example", failure)
+
+	health := map[string]string{}
+	for _, status := range pool.Status() {
+		health[status.URL] = status.Health
+	}
+	if health["https://a.example"] != "degraded" || health["https://b.example"] != "healthy" {
+		t.Fatalf("health after one failure = %v", health)
+	}
+
+	for i := 1; i < defaultFailureThreshold; i++ {
+		pool.Report("https://a.example", failure)
+	}
+	if status := pool.Status()[0]; status.Health != "down" {
+		t.Fatalf("health after %d failures = %s, want down", defaultFailureThreshold, status.Health)
+	}
+
+	pool = NewEndpointPool("https://a.example")
+	pool.Report("https://a.example", failure)
+	pool.Report("https://a.example", nil)
+	if status := pool.Status()[0]; status.Health != "healthy" {
+		t.Fatalf("health after recovering = %s, want healthy", status.Health)
+	}
+}
@@ -0,0 +1,167 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/schollz/progressbar/v3"
+)
+
+// ExportJob is one blob, or one file of a quilt, to copy from Walrus into S3
+type ExportJob struct {
+	Name         string // Object key, below the export prefix
+	BlobID       string
+	QuiltPatchID string // Read this quilt patch instead of the whole blob
+	Chunked      bool   // BlobID is the manifest of a chunked object
+	Compression  string // Codec the blob is compressed with; it is exported decompressed
+	Size         int64  // Expected size for progress; 0 when unknown
+	ContentType  string // Guessed from the name's extension when empty
+	Tags         map[string]string
+	Metadata     map[string]string // User metadata of the S3 object the blob was copied from
+}
+
+// Export copies blobs from the aggregator into bucket under prefix, on the
+// same worker pool as S3 transfers. Each blob is streamed straight into an
+// S3 upload, so at most one part per worker is held in memory. The Walrus
+// blob ID, the job's tags and any metadata the blob's source object had are
+// stored as object metadata.
+func (tm *TransferManager) Export(ctx context.Context, bucket, prefix string, jobs []ExportJob) (*TransferProgress, error) {
+	var totalSize int64
+	for _, job := range jobs {
+		totalSize += job.Size
+	}
+
+	if tm.dryRun {
+		fmt.Println(color.YellowString("\n=== DRY RUN MODE ==="))
+		fmt.Printf("Would export %d files (%.2f MB total)\n", len(jobs), float64(totalSize)/(1024*1024))
+		for _, job := range jobs {
+			fmt.Printf("  • %s → s3://%s/%s (%.2f MB)\n",
+				job.Name,
+				bucket,
+				prefix+job.Name,
+				float64(job.Size)/(1024*1024))
+		}
+		fmt.Println(color.YellowString("=== DRY RUN COMPLETE ===\n"))
+
+		return &TransferProgress{
+			TotalFiles:     len(jobs),
+			TotalBytes:     totalSize,
+			ProcessedFiles: int32(len(jobs)),
+			ProcessedBytes: totalSize,
+			StartTime:      time.Now(),
+		}, nil
+	}
+
+	progress := &TransferProgress{
+		TotalFiles: len(jobs),
+		TotalBytes: totalSize,
+		StartTime:  time.Now(),
+		Results:    make([]TransferResult, 0, len(jobs)),
+	}
+
+	barSize := totalSize
+	for _, job := range jobs {
+		if job.Size == 0 {
+			barSize = -1 // Some sizes are unknown; show a spinner instead
+			break
+		}
+	}
+	bar := newTransferBar(barSize, "Exporting files")
+	tm.runJobs(ctx, progress, len(jobs), func(i int) TransferResult {
+		return tm.exportSingleFile(ctx, bucket, prefix, jobs[i], bar)
+	})
+	bar.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return progress, fmt.Errorf("export interrupted: %w", err)
+	}
+	return progress, nil
+}
+
+func (tm *TransferManager) exportSingleFile(ctx context.Context, bucket, prefix string, job ExportJob, bar *progressbar.ProgressBar) TransferResult {
+	result := TransferResult{
+		SourceKey:  job.Name,
+		TargetName: prefix + job.Name,
+		BlobID:     job.BlobID,
+		Size:       job.Size,
+		UploadTime: time.Now(),
+	}
+
+	contentType := job.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(job.Name))
+	}
+	metadata := make(map[string]string, len(job.Metadata)+len(job.Tags)+2)
+	for k, v := range job.Metadata {
+		metadata[k] = v
+	}
+	metadata["walrus-blob-id"] = job.BlobID
+	if job.QuiltPatchID != "" {
+		metadata["walrus-quilt-patch-id"] = job.QuiltPatchID
+	}
+	for k, v := range job.Tags {
+		metadata[k] = v
+	}
+
+	// The aggregator writes into a pipe that the S3 upload reads from. A
+	// failed upload closes the reader, which stops the download.
+	pr, pw := io.Pipe()
+	go func() {
+		var w io.Writer = &progressWriter{w: pw, bar: bar}
+		var finish func() (int64, error)
+		if job.Compression != "" {
+			var err error
+			if w, finish, err = decodingWriter(w, nil, job.Compression); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+
+		var err error
+		switch {
+		case job.QuiltPatchID != "":
+			_, err = tm.walrusClient.RetrieveQuiltPatchToContext(ctx, job.QuiltPatchID, w)
+		case job.Chunked:
+			_, err = tm.walrusClient.RetrieveChunkedToContext(ctx, job.BlobID, w, nil)
+		default:
+			_, err = tm.walrusClient.RetrieveBlobToContext(ctx, job.BlobID, w)
+		}
+		if finish != nil {
+			if _, finishErr := finish(); err == nil {
+				err = finishErr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+
+	written, err := tm.s3Client.UploadObject(ctx, bucket, result.TargetName, pr, S3PutOptions{
+		ContentType: contentType,
+		Metadata:    metadata,
+	})
+	pr.CloseWithError(err)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	result.Size = written
+	result.Success = true
+	return result
+}
+
+// progressWriter feeds bytes written to a progress bar
+type progressWriter struct {
+	w   io.Writer
+	bar *progressbar.ProgressBar
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.bar.Add(n)
+	return n, err
+}
@@ -0,0 +1,45 @@
+package backend
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExportKeepsMetadata(t *testing.T) {
+	s3fake, s3Client := newFakeS3(t)
+	fw, walrus := newFakeWalrus(t)
+	id := fw.put([]byte("exported content"), 10)
+
+	tm := NewTransferManager(s3Client, walrus, nil, 1)
+	jobs := []ExportJob{{
+		Name:        "docs/report.bin",
+		BlobID:      id,
+		ContentType: "application/pdf",
+		Tags:        map[string]string{"team": "storage"},
+		Metadata:    map[string]string{"origin": "camera", "team": "source"},
+	}}
+	progress, err := tm.Export(context.Background(), "bucket", "backup/", jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.FailedFiles != 0 {
+		t.Fatalf("export failed: %v", progress.Results[0].Error)
+	}
+
+	obj := s3fake.object("bucket", "backup/docs/report.bin")
+	if obj == nil {
+		t.Fatal("nothing exported")
+	}
+	if string(obj.data) != "exported content" {
+		t.Fatalf("exported %q", obj.data)
+	}
+	if obj.contentType != "application/pdf" {
+		t.Fatalf("content type %q, want application/pdf", obj.contentType)
+	}
+	want := map[string]string{"origin": "camera", "team": "storage", "walrus-blob-id": id}
+	for k, v := range want {
+		if obj.metadata[k] != v {
+			t.Errorf("metadata %s = %q, want %q", k, obj.metadata[k], v)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory stand-in for an S3-compatible store such as MinIO,
+// reached with path-style addressing. It serves what S3Client uses: listing,
+// HEAD and ranged GET, tagging, PutObject and multipart uploads.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string]*fakeS3Object // By "bucket/key"
+	uploads  map[string]*fakeS3Upload // Multipart uploads in progress, by ID
+	aborted  int
+	nextID   int
+	failPart int // Part number that is rejected, if any
+}
+
+type fakeS3Object struct {
+	data         []byte
+	etag         string
+	contentType  string
+	cacheControl string
+	metadata     map[string]string
+	tags         map[string]string
+	modified     time.Time
+	partSizes    []int // Set for objects completed from a multipart upload
+}
+
+type fakeS3Upload struct {
+	key   string
+	obj   *fakeS3Object
+	parts map[int][]byte
+}
+
+// newFakeS3 starts a fake store and returns it with a client pointed at it
+func newFakeS3(t *testing.T) (*fakeS3, *S3Client) {
+	t.Helper()
+	fs := &fakeS3{objects: make(map[string]*fakeS3Object), uploads: make(map[string]*fakeS3Upload)}
+	srv := httptest.NewServer(fs)
+	t.Cleanup(srv.Close)
+
+	client, err := NewS3Client(S3Credentials{
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+		Region:          "us-east-1",
+		Endpoint:        srv.URL,
+		UsePathStyle:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs, client
+}
+
+// put stores an object directly
+func (f *fakeS3) put(bucket, key string, data []byte, contentType string) *fakeS3Object {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sum := md5.Sum(data)
+	obj := &fakeS3Object{
+		data:        data,
+		etag:        `"` + hex.EncodeToString(sum[:]) + `"`,
+		contentType: contentType,
+		modified:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	f.objects[bucket+"/"+key] = obj
+	return obj
+}
+
+// object returns the stored object at bucket/key, or nil
+func (f *fakeS3) object(bucket, key string) *fakeS3Object {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.objects[bucket+"/"+key]
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, bucket, query.Get("prefix"))
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.uploadID(w, bucket, key, r)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		f.uploadPart(w, r, query)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.complete(w, bucket, query.Get("uploadId"))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		f.aborted++
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		obj := newFakeS3Object(data, r)
+		f.objects[bucket+"/"+key] = obj
+		w.Header().Set("ETag", obj.etag)
+	case r.Method == http.MethodGet && query.Has("tagging"):
+		obj := f.objects[bucket+"/"+key]
+		if obj == nil {
+			f.notFound(w)
+			return
+		}
+		var tagging struct {
+			XMLName xml.Name `xml:"Tagging"`
+			Tags    []struct {
+				Key   string `xml:"Key"`
+				Value string `xml:"Value"`
+			} `xml:"TagSet>Tag"`
+		}
+		for _, k := range sortedTagKeys(obj.tags) {
+			tagging.Tags = append(tagging.Tags, struct {
+				Key   string `xml:"Key"`
+				Value string `xml:"Value"`
+			}{k, obj.tags[k]})
+		}
+		writeXML(w, tagging)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj := f.objects[bucket+"/"+key]
+		if obj == nil {
+			f.notFound(w)
+			return
+		}
+		h := w.Header()
+		h.Set("ETag", obj.etag)
+		if obj.contentType != "" {
+			h.Set("Content-Type", obj.contentType)
+		}
+		if obj.cacheControl != "" {
+			h.Set("Cache-Control", obj.cacheControl)
+		}
+		for k, v := range obj.metadata {
+			h.Set("X-Amz-Meta-"+k, v)
+		}
+		if len(obj.tags) > 0 {
+			h.Set("X-Amz-Tagging-Count", strconv.Itoa(len(obj.tags)))
+		}
+		http.ServeContent(w, r, "", obj.modified, bytes.NewReader(obj.data))
+	default:
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+	}
+}
+
+func newFakeS3Object(data []byte, r *http.Request) *fakeS3Object {
+	sum := md5.Sum(data)
+	obj := &fakeS3Object{
+		data:         data,
+		etag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		contentType:  r.Header.Get("Content-Type"),
+		cacheControl: r.Header.Get("Cache-Control"),
+		metadata:     make(map[string]string),
+		modified:     time.Now().UTC().Truncate(time.Second),
+	}
+	for name, values := range r.Header {
+		if meta, ok := strings.CutPrefix(strings.ToLower(name), "x-amz-meta-"); ok {
+			obj.metadata[meta] = values[0]
+		}
+	}
+	return obj
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, bucket, prefix string) {
+	type content struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int    `xml:"Size"`
+		StorageClass string `xml:"StorageClass"`
+	}
+	var result struct {
+		XMLName     xml.Name  `xml:"ListBucketResult"`
+		Name        string    `xml:"Name"`
+		Prefix      string    `xml:"Prefix"`
+		KeyCount    int       `xml:"KeyCount"`
+		MaxKeys     int       `xml:"MaxKeys"`
+		IsTruncated bool      `xml:"IsTruncated"`
+		Contents    []content `xml:"Contents"`
+	}
+	result.Name, result.Prefix, result.MaxKeys = bucket, prefix, 1000
+
+	var keys []string
+	for name := range f.objects {
+		if key, ok := strings.CutPrefix(name, bucket+"/"); ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		obj := f.objects[bucket+"/"+key]
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: obj.modified.Format("2006-01-02T15:04:05.000Z"),
+			ETag:         obj.etag,
+			Size:         len(obj.data),
+			StorageClass: "STANDARD",
+		})
+	}
+	result.KeyCount = len(keys)
+	writeXML(w, result)
+}
+
+func (f *fakeS3) uploadID(w http.ResponseWriter, bucket, key string, r *http.Request) {
+	f.nextID++
+	id := fmt.Sprintf("upload-%d", f.nextID)
+	f.uploads[id] = &fakeS3Upload{key: key, obj: newFakeS3Object(nil, r), parts: make(map[int][]byte)}
+	writeXML(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}{Bucket: bucket, Key: key, UploadID: id})
+}
+
+func (f *fakeS3) uploadPart(w http.ResponseWriter, r *http.Request, query map[string][]string) {
+	upload := f.uploads[query["uploadId"][0]]
+	number, _ := strconv.Atoi(query["partNumber"][0])
+	if upload == nil || number < 1 {
+		f.notFound(w)
+		return
+	}
+	if number == f.failPart {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `<Error><Code>InvalidArgument</Code><Message>Part rejected.</Message></Error>`)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	upload.parts[number] = data
+	sum := md5.Sum(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+}
+
+func (f *fakeS3) complete(w http.ResponseWriter, bucket, id string) {
+	upload := f.uploads[id]
+	if upload == nil {
+		f.notFound(w)
+		return
+	}
+	delete(f.uploads, id)
+
+	obj := upload.obj
+	for i := 1; i <= len(upload.parts); i++ {
+		obj.data = append(obj.data, upload.parts[i]...)
+		obj.partSizes = append(obj.partSizes, len(upload.parts[i]))
+	}
+	obj.etag = fmt.Sprintf(`"multipart-%d"`, len(upload.parts))
+	f.objects[bucket+"/"+upload.key] = obj
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string   `xml:"Bucket"`
+		Key     string   `xml:"Key"`
+		ETag    string   `xml:"ETag"`
+	}{Bucket: bucket, Key: upload.key, ETag: obj.etag})
+}
+
+func (f *fakeS3) notFound(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
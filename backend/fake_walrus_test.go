@@ -0,0 +1,109 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWalrus is an in-memory publisher and aggregator. Like the real
+// network, blob IDs are derived from the content, and a blob stored for n
+// epochs expires n epochs after the current one. Storing a blob that is
+// already stored for long enough answers alreadyCertified.
+type fakeWalrus struct {
+	mu      sync.Mutex
+	epoch   int
+	blobs   map[string][]byte
+	ends    map[string]int
+	objects int
+	stores  int             // PUTs that created a blob object
+	tamper  map[string]bool // Blobs served with their first byte flipped
+	refuse  map[string]bool // Blobs whose store is refused
+}
+
+func newFakeWalrus(t *testing.T) (*fakeWalrus, *WalrusClient) {
+	t.Helper()
+	fw := &fakeWalrus{blobs: make(map[string][]byte), ends: make(map[string]int), tamper: make(map[string]bool), refuse: make(map[string]bool)}
+	srv := httptest.NewServer(fw)
+	t.Cleanup(srv.Close)
+	client := NewWalrusClient(srv.URL, srv.URL)
+	client.Retry = RetryPolicy{MaxAttempts: 1}
+	// No walrus binary, even if one is installed: epochs come from the fake
+	client.WalrusCLI = NewWalrusCLI(filepath.Join(t.TempDir(), "walrus"))
+	return fw, client
+}
+
+func fakeBlobID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "blob-" + hex.EncodeToString(sum[:12])
+}
+
+func (f *fakeWalrus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method == http.MethodPut && r.URL.Path == "/v1/blobs" {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		epochs, _ := strconv.Atoi(r.URL.Query().Get("epochs"))
+		id := fakeBlobID(data)
+		if f.refuse[id] {
+			http.Error(w, "insufficient funds", http.StatusInternalServerError)
+			return
+		}
+		end := f.epoch + epochs
+		if f.ends[id] >= end {
+			fmt.Fprintf(w, `{"alreadyCertified":{"blobId":%q,"endEpoch":%d}}`, id, f.ends[id])
+			return
+		}
+		f.blobs[id] = data
+		f.ends[id] = end
+		f.objects++
+		f.stores++
+		fmt.Fprintf(w, `{"newlyCreated":{"blobObject":{"id":"0x%x","blobId":%q,"deletable":%t,"storage":{"endEpoch":%d},"size":%d},"cost":100}}`,
+			f.objects, id, r.URL.Query().Get("deletable") == "true", end, len(data))
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/v1/blobs/")
+	data, ok := f.blobs[id]
+	if !ok || f.ends[id] <= f.epoch {
+		http.NotFound(w, r)
+		return
+	}
+	if f.tamper[id] {
+		data = append([]byte{data[0] ^ 0xff}, data[1:]...)
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// put stores data directly, as if it had been uploaded earlier
+func (f *fakeWalrus) put(data []byte, end int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := fakeBlobID(data)
+	f.blobs[id] = data
+	f.ends[id] = end
+	return id
+}
+
+// end returns the epoch the blob with id expires at
+func (f *fakeWalrus) end(id string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ends[id]
+}
@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalStatus is the outcome recorded for an object
+type JournalStatus string
+
+const (
+	JournalDone   JournalStatus = "done"
+	JournalFailed JournalStatus = "failed"
+)
+
+// JournalEntry is the last recorded outcome for one S3 object
+type JournalEntry struct {
+	Bucket     string        `json:"bucket"`
+	Key        string        `json:"key"`
+	ETag       string        `json:"etag"`
+	Size       int64         `json:"size"`
+	Status     JournalStatus `json:"status"`
+	TargetName string        `json:"target_name,omitempty"`
+	BlobID     string        `json:"blob_id,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// TransferJournal is a checkpoint log of an S3 bucket migration. Every
+// finished object is appended as one JSON line, so a crash loses at most the
+// object in flight and a rerun can tell what is already on Walrus.
+type TransferJournal struct {
+	path    string
+	file    *os.File
+	mu      sync.Mutex
+	entries map[string]JournalEntry
+}
+
+// TransferJournalPath returns where the journal for bucket is kept
+func TransferJournalPath(bucket string) string {
+	home, _ := os.UserHomeDir()
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(bucket)
+	return filepath.Join(home, ".walrus-transfers", name+".journal")
+}
+
+// OpenTransferJournal loads the journal for bucket, creating it if needed
+func OpenTransferJournal(bucket string) (*TransferJournal, error) {
+	path := TransferJournalPath(bucket)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating journal directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening transfer journal: %w", err)
+	}
+
+	j := &TransferJournal{path: path, file: file, entries: make(map[string]JournalEntry)}
+
+	// Later lines supersede earlier ones; a torn last line from a crash is skipped
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		j.entries[journalKey(entry.Bucket, entry.Key)] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading transfer journal: %w", err)
+	}
+
+	// Terminate a torn line so the next record starts on a line of its own
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		tail := make([]byte, 1)
+		if _, err := file.ReadAt(tail, info.Size()-1); err == nil && tail[0] != '\n' {
+			file.Write([]byte("\n"))
+		}
+	}
+
+	return j, nil
+}
+
+func journalKey(bucket, key string) string {
+	return bucket + "\x00" + key
+}
+
+// Path returns the journal's file path
+func (j *TransferJournal) Path() string {
+	return j.path
+}
+
+// Lookup returns the last recorded outcome for an object
+func (j *TransferJournal) Lookup(bucket, key string) (JournalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry, ok := j.entries[journalKey(bucket, key)]
+	return entry, ok
+}
+
+// Completed reports whether obj was already transferred and has not changed
+// since, judged by its ETag and size
+func (j *TransferJournal) Completed(bucket string, obj S3Object) bool {
+	entry, ok := j.Lookup(bucket, obj.Key)
+	return ok && entry.Status == JournalDone && entry.ETag == obj.ETag && entry.Size == obj.Size
+}
+
+// Failed returns the objects whose last attempt failed, ordered by key
+func (j *TransferJournal) Failed(bucket string) []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var failed []JournalEntry
+	for _, entry := range j.entries {
+		if entry.Bucket == bucket && entry.Status == JournalFailed {
+			failed = append(failed, entry)
+		}
+	}
+	sort.Slice(failed, func(a, b int) bool { return failed[a].Key < failed[b].Key })
+	return failed
+}
+
+// Record appends an outcome to the journal
+func (j *TransferJournal) Record(entry JournalEntry) error {
+	if entry.UpdatedAt.IsZero() {
+		entry.UpdatedAt = time.Now()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing transfer journal: %w", err)
+	}
+	j.entries[journalKey(entry.Bucket, entry.Key)] = entry
+	return nil
+}
+
+// Close closes the journal file
+func (j *TransferJournal) Close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransferJournalRecoversTornLine(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	j, err := OpenTransferJournal("bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []JournalEntry{
+		{Bucket: "bucket", Key: "a.txt", ETag: `"1"`, Size: 10, Status: JournalDone, BlobID: "blob-a"},
+		{Bucket: "bucket", Key: "b.txt", ETag: `"2"`, Size: 20, Status: JournalFailed, Error: "timeout"},
+		{Bucket: "bucket", Key: "b.txt", ETag: `"2"`, Size: 20, Status: JournalDone, BlobID: "blob-b"},
+	}
+	for _, entry := range records {
+		if err := j.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash halfway through writing a record leaves a partial line
+	path := TransferJournalPath("bucket")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"bucket":"bucket","key":"c.txt","status":"do`)
+	f.Close()
+
+	j, err = OpenTransferJournal("bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !j.Completed("bucket", S3Object{Key: "a.txt", ETag: `"1"`, Size: 10}) {
+		t.Error("a.txt is not completed after reopening")
+	}
+	if entry, _ := j.Lookup("bucket", "b.txt"); entry.Status != JournalDone || entry.BlobID != "blob-b" {
+		t.Errorf("b.txt = %+v, want the later done record", entry)
+	}
+	if _, ok := j.Lookup("bucket", "c.txt"); ok {
+		t.Error("the torn record for c.txt was loaded")
+	}
+	if failed := j.Failed("bucket"); len(failed) != 0 {
+		t.Errorf("Failed = %+v, want none", failed)
+	}
+
+	// The next record must not be glued to the torn line
+	if err := j.Record(JournalEntry{Bucket: "bucket", Key: "c.txt", ETag: `"3"`, Size: 30, Status: JournalDone}); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	j, err = OpenTransferJournal("bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if !j.Completed("bucket", S3Object{Key: "c.txt", ETag: `"3"`, Size: 30}) {
+		t.Error("the record written after the torn line was lost")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"); len(lines) != 5 {
+		t.Errorf("journal has %d lines, want 5:\n%s", len(lines), data)
+	}
+}
+
+func TestTransferJournalCompletedNeedsSameObject(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	j, err := OpenTransferJournal("photos/2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if dir := filepath.Base(filepath.Dir(j.Path())); dir != ".walrus-transfers" {
+		t.Fatalf("journal is in %s", j.Path())
+	}
+	if name := filepath.Base(j.Path()); name != "photos_2024.journal" {
+		t.Fatalf("journal is named %s", name)
+	}
+
+	j.Record(JournalEntry{Bucket: "photos/2024", Key: "a.jpg", ETag: `"1"`, Size: 10, Status: JournalDone})
+	tests := []struct {
+		obj  S3Object
+		want bool
+	}{
+		{S3Object{Key: "a.jpg", ETag: `"1"`, Size: 10}, true},
+		{S3Object{Key: "a.jpg", ETag: `"2"`, Size: 10}, false},
+		{S3Object{Key: "a.jpg", ETag: `"1"`, Size: 11}, false},
+		{S3Object{Key: "b.jpg", ETag: `"1"`, Size: 10}, false},
+	}
+	for _, tt := range tests {
+		if got := j.Completed("photos/2024", tt.obj); got != tt.want {
+			t.Errorf("Completed(%+v) = %t, want %t", tt.obj, got, tt.want)
+		}
+	}
+}
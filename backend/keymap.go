@@ -0,0 +1,98 @@
+package backend
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// KeyMapping turns S3 object keys into names in the transfer index. The zero
+// value keeps each key's full path, so objects with the same base name in
+// different "directories" stay distinct.
+type KeyMapping struct {
+	StripPrefix string // Removed from the start of keys that have it
+	AddPrefix   string // Prepended after stripping
+	Flatten     bool   // Keep only the last path element of each key
+}
+
+// TargetName returns the index name for key
+func (m KeyMapping) TargetName(key string) string {
+	name := key
+	if m.Flatten {
+		name = path.Base(name)
+	} else if m.StripPrefix != "" {
+		name = strings.TrimPrefix(name, m.StripPrefix)
+	}
+	name = strings.TrimLeft(name, "/")
+	if name == "" {
+		return ""
+	}
+	return m.AddPrefix + name
+}
+
+// KeyCollision is a target name that more than one source key maps to
+type KeyCollision struct {
+	TargetName string
+	Keys       []string
+}
+
+// KeyMappingError reports keys that cannot be transferred under a mapping
+// without losing data: keys mapping to the same name, and keys that map to
+// an empty name
+type KeyMappingError struct {
+	Collisions []KeyCollision
+	Empty      []string
+}
+
+func (e *KeyMappingError) Error() string {
+	var b strings.Builder
+	if len(e.Collisions) > 0 {
+		fmt.Fprintf(&b, "%d target name(s) would be overwritten:", len(e.Collisions))
+		for _, c := range e.Collisions {
+			fmt.Fprintf(&b, "\n  %s <- %s", c.TargetName, strings.Join(c.Keys, ", "))
+		}
+	}
+	if len(e.Empty) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%d key(s) map to an empty name: %s", len(e.Empty), strings.Join(e.Empty, ", "))
+	}
+	return b.String()
+}
+
+// MapKeys applies the mapping to every key and returns the target name for
+// each one. It fails with a *KeyMappingError if any two keys would end up
+// under the same name or a key maps to nothing.
+func (m KeyMapping) MapKeys(keys []string) (map[string]string, error) {
+	names := make(map[string]string, len(keys))
+	sources := make(map[string][]string, len(keys))
+	var empty []string
+	for _, key := range keys {
+		name := m.TargetName(key)
+		if name == "" {
+			empty = append(empty, key)
+			continue
+		}
+		names[key] = name
+		sources[name] = append(sources[name], key)
+	}
+
+	var collisions []KeyCollision
+	for name, keys := range sources {
+		if len(keys) > 1 {
+			sort.Strings(keys)
+			collisions = append(collisions, KeyCollision{TargetName: name, Keys: keys})
+		}
+	}
+	if len(collisions) == 0 && len(empty) == 0 {
+		return names, nil
+	}
+
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].TargetName < collisions[j].TargetName
+	})
+	sort.Strings(empty)
+	return nil, &KeyMappingError{Collisions: collisions, Empty: empty}
+}
@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestKeyMappingTargetName(t *testing.T) {
+	tests := []struct {
+		mapping KeyMapping
+		key     string
+		want    string
+	}{
+		{KeyMapping{}, "photos/2024/a.jpg", "photos/2024/a.jpg"},
+		{KeyMapping{}, "/leading.txt", "leading.txt"},
+		{KeyMapping{StripPrefix: "photos/"}, "photos/2024/a.jpg", "2024/a.jpg"},
+		{KeyMapping{StripPrefix: "photos/"}, "videos/b.mp4", "videos/b.mp4"},
+		{KeyMapping{StripPrefix: "photos"}, "photos/a.jpg", "a.jpg"},
+		{KeyMapping{StripPrefix: "photos/", AddPrefix: "backup/"}, "photos/a.jpg", "backup/a.jpg"},
+		{KeyMapping{Flatten: true}, "photos/2024/a.jpg", "a.jpg"},
+		{KeyMapping{Flatten: true, AddPrefix: "flat/"}, "photos/2024/a.jpg", "flat/a.jpg"},
+		{KeyMapping{StripPrefix: "photos/"}, "photos/", ""},
+		{KeyMapping{StripPrefix: "photos/", AddPrefix: "x/"}, "photos/", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.mapping.TargetName(tt.key); got != tt.want {
+			t.Errorf("%+v.TargetName(%q) = %q, want %q", tt.mapping, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMapKeysCollisions(t *testing.T) {
+	keys := []string{"b/report.pdf", "a/report.pdf", "a/notes.txt", "c/notes.txt", "a/unique.txt", "z/report.pdf"}
+	_, err := KeyMapping{Flatten: true}.MapKeys(keys)
+
+	var mapErr *KeyMappingError
+	if !errors.As(err, &mapErr) {
+		t.Fatalf("MapKeys error = %v, want a *KeyMappingError", err)
+	}
+	want := []KeyCollision{
+		{TargetName: "notes.txt", Keys: []string{"a/notes.txt", "c/notes.txt"}},
+		{TargetName: "report.pdf", Keys: []string{"a/report.pdf", "b/report.pdf", "z/report.pdf"}},
+	}
+	if !reflect.DeepEqual(mapErr.Collisions, want) {
+		t.Fatalf("Collisions = %+v, want %+v", mapErr.Collisions, want)
+	}
+	if len(mapErr.Empty) != 0 {
+		t.Fatalf("Empty = %v, want none", mapErr.Empty)
+	}
+}
+
+func TestMapKeysEmptyNames(t *testing.T) {
+	_, err := KeyMapping{StripPrefix: "data/"}.MapKeys([]string{"data/", "data/a.txt", "data//"})
+
+	var mapErr *KeyMappingError
+	if !errors.As(err, &mapErr) {
+		t.Fatalf("MapKeys error = %v, want a *KeyMappingError", err)
+	}
+	if want := []string{"data/", "data//"}; !reflect.DeepEqual(mapErr.Empty, want) {
+		t.Fatalf("Empty = %v, want %v", mapErr.Empty, want)
+	}
+	if len(mapErr.Collisions) != 0 {
+		t.Fatalf("Collisions = %+v, want none", mapErr.Collisions)
+	}
+}
+
+func TestMapKeysDefaultKeepsPaths(t *testing.T) {
+	keys := []string{"a/report.pdf", "b/report.pdf"}
+	names, err := KeyMapping{}.MapKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a/report.pdf": "a/report.pdf", "b/report.pdf": "b/report.pdf"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("MapKeys = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeleteBlob deletes a deletable blob owned by the configured wallet and
+// reclaims its storage. With an empty objectID, every owned blob object
+// holding blobID is deleted.
+func (c *WalrusClient) DeleteBlob(blobID, objectID string) error {
+	return c.DeleteBlobContext(context.Background(), blobID, objectID)
+}
+
+// DeleteBlobContext is DeleteBlob bound to ctx
+func (c *WalrusClient) DeleteBlobContext(ctx context.Context, blobID, objectID string) error {
+	if blobID == "" && objectID == "" {
+		return fmt.Errorf("a blob ID or object ID is required")
+	}
+	if err := c.walrusCLI().Delete(ctx, blobID, objectID); err != nil {
+		return fmt.Errorf("deleting blob: %w", err)
+	}
+	return nil
+}
+
+// ExtendBlob pushes the expiry of the blob object objectID out by epochs.
+// Extending is paid for by the configured wallet, which must own the object.
+func (c *WalrusClient) ExtendBlob(objectID string, epochs int) error {
+	return c.ExtendBlobContext(context.Background(), objectID, epochs)
+}
+
+// ExtendBlobContext is ExtendBlob bound to ctx
+func (c *WalrusClient) ExtendBlobContext(ctx context.Context, objectID string, epochs int) error {
+	if objectID == "" {
+		return fmt.Errorf("a blob object ID is required to extend a blob")
+	}
+	if epochs <= 0 {
+		return fmt.Errorf("epochs to extend by must be positive, got %d", epochs)
+	}
+	if err := c.walrusCLI().Extend(ctx, objectID, epochs); err != nil {
+		return fmt.Errorf("extending blob: %w", err)
+	}
+	return nil
+}
+
+// OwnedBlobs lists the blob objects held by the configured wallet
+func (c *WalrusClient) OwnedBlobs(ctx context.Context) ([]OwnedBlob, error) {
+	blobs, err := c.walrusCLI().ListBlobs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing owned blobs: %w", err)
+	}
+	return blobs, nil
+}
+
+// CurrentEpoch returns the epoch the Walrus network is currently in
+func (c *WalrusClient) CurrentEpoch(ctx context.Context) (int, error) {
+	epoch, err := c.walrusCLI().CurrentEpoch(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("fetching current epoch: %w", err)
+	}
+	return epoch, nil
+}
@@ -0,0 +1,311 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// quiltMetadataField is the multipart field carrying per-patch tags
+const quiltMetadataField = "_metadata"
+
+// QuiltPatch is one file stored inside a quilt
+type QuiltPatch struct {
+	Identifier string            // Unique name of the file within the quilt
+	Open       BodyOpener        // Returns the file contents; called once per attempt
+	Tags       map[string]string // Optional tags stored alongside the patch
+}
+
+// StoredQuiltPatch maps a patch identifier to the ID it can be fetched by
+type StoredQuiltPatch struct {
+	Identifier   string `json:"identifier"`
+	QuiltPatchID string `json:"quiltPatchId"`
+}
+
+// QuiltStoreResponse is the result of storing a quilt: the underlying blob
+// plus one entry per patch
+type QuiltStoreResponse struct {
+	Blob    *StoreResponse
+	Patches []StoredQuiltPatch
+}
+
+// PatchID returns the quilt patch ID stored for identifier
+func (r *QuiltStoreResponse) PatchID(identifier string) (string, bool) {
+	for _, patch := range r.Patches {
+		if patch.Identifier == identifier {
+			return patch.QuiltPatchID, true
+		}
+	}
+	return "", false
+}
+
+type quiltStoreEnvelope struct {
+	BlobStoreResult  json.RawMessage    `json:"blobStoreResult"`
+	StoredQuiltBlobs []StoredQuiltPatch `json:"storedQuiltBlobs"`
+}
+
+type quiltPatchMetadata struct {
+	Identifier string            `json:"identifier"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// FileQuiltPatch builds a patch that reads the file at path, identified by its base name
+func FileQuiltPatch(path string) QuiltPatch {
+	return QuiltPatch{
+		Identifier: filepath.Base(path),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}
+}
+
+// StoreQuilt stores many small files as a single blob, paying the per-blob
+// metadata overhead once instead of once per file
+func (c *WalrusClient) StoreQuilt(patches []QuiltPatch, epochs int) (*QuiltStoreResponse, error) {
+	return c.StoreQuiltContext(context.Background(), patches, epochs)
+}
+
+// StoreQuiltContext is StoreQuilt bound to ctx
+func (c *WalrusClient) StoreQuiltContext(ctx context.Context, patches []QuiltPatch, epochs int) (*QuiltStoreResponse, error) {
+	return c.StoreQuiltWithOptionsContext(ctx, patches, StoreOptions{Epochs: epochs})
+}
+
+// StoreQuiltWithOptionsContext is StoreQuiltContext with extra store options.
+// Per-patch tags take the place of blob attributes, so opts.Attributes is unused.
+func (c *WalrusClient) StoreQuiltWithOptionsContext(ctx context.Context, patches []QuiltPatch, opts StoreOptions) (*QuiltStoreResponse, error) {
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	if err := validateQuiltPatches(patches); err != nil {
+		return nil, err
+	}
+
+	if c.UseUploadRelay && c.UploadRelayURL != "" {
+		return c.storeQuiltViaRelay(ctx, patches, opts)
+	}
+
+	var quiltResp *QuiltStoreResponse
+	err := c.withEndpoints(ctx, c.publisherPool(), func(ctx context.Context, attempt int, baseURL string) error {
+		var err error
+		quiltResp, err = c.storeQuiltOnce(ctx, baseURL, patches, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quiltResp, nil
+}
+
+func validateQuiltPatches(patches []QuiltPatch) error {
+	if len(patches) == 0 {
+		return fmt.Errorf("a quilt needs at least one file")
+	}
+	seen := make(map[string]bool, len(patches))
+	for _, patch := range patches {
+		switch {
+		case patch.Identifier == "":
+			return fmt.Errorf("quilt patch identifier cannot be empty")
+		case strings.HasPrefix(patch.Identifier, "_"):
+			return fmt.Errorf("quilt patch identifier %q cannot start with '_'", patch.Identifier)
+		case seen[patch.Identifier]:
+			return fmt.Errorf("duplicate quilt patch identifier %q", patch.Identifier)
+		case patch.Open == nil:
+			return fmt.Errorf("quilt patch %q has no content", patch.Identifier)
+		}
+		seen[patch.Identifier] = true
+	}
+	return nil
+}
+
+// storeQuiltOnce streams patches as a multipart form to one publisher
+func (c *WalrusClient) storeQuiltOnce(ctx context.Context, baseURL string, patches []QuiltPatch, opts StoreOptions) (*QuiltStoreResponse, error) {
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+
+	go func() {
+		pw.CloseWithError(writeQuiltForm(form, patches))
+	}()
+
+	reqURL := fmt.Sprintf("%s/v1/quilts?%s", baseURL, c.storeQuery(opts))
+	req, err := http.NewRequestWithContext(ctx, "PUT", reqURL, pr)
+	if err != nil {
+		pr.Close()
+		return nil, Permanent(fmt.Errorf("creating request: %w", err))
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := c.HTTPClient.Do(req)
+	pr.Close()
+	if err != nil {
+		return nil, fmt.Errorf("uploading quilt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, newHTTPStatusError("quilt upload", resp)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	quiltResp, err := decodeQuiltStoreResponse(respBody)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	return quiltResp, nil
+}
+
+// writeQuiltForm writes one file part per patch, named by its identifier,
+// followed by the tag metadata if any patch has tags
+func writeQuiltForm(form *multipart.Writer, patches []QuiltPatch) error {
+	var metadata []quiltPatchMetadata
+	for _, patch := range patches {
+		if err := writeQuiltPart(form, patch); err != nil {
+			return err
+		}
+		if len(patch.Tags) > 0 {
+			metadata = append(metadata, quiltPatchMetadata{Identifier: patch.Identifier, Tags: patch.Tags})
+		}
+	}
+
+	if len(metadata) > 0 {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return Permanent(fmt.Errorf("encoding quilt metadata: %w", err))
+		}
+		if err := form.WriteField(quiltMetadataField, string(data)); err != nil {
+			return err
+		}
+	}
+	return form.Close()
+}
+
+func writeQuiltPart(form *multipart.Writer, patch QuiltPatch) error {
+	body, err := patch.Open()
+	if err != nil {
+		return Permanent(fmt.Errorf("opening %s: %w", patch.Identifier, err))
+	}
+	defer body.Close()
+
+	part, err := form.CreateFormFile(patch.Identifier, patch.Identifier)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, body); err != nil {
+		return fmt.Errorf("streaming %s: %w", patch.Identifier, err)
+	}
+	return nil
+}
+
+func decodeQuiltStoreResponse(payload []byte) (*QuiltStoreResponse, error) {
+	var envelope quiltStoreEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("decoding quilt response: %w (body: %s)", err, string(payload))
+	}
+	if len(envelope.BlobStoreResult) == 0 {
+		return nil, fmt.Errorf("unexpected quilt response format: %s", string(payload))
+	}
+
+	blob, err := decodeStoreResponse(envelope.BlobStoreResult, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &QuiltStoreResponse{Blob: blob, Patches: envelope.StoredQuiltBlobs}, nil
+}
+
+// storeQuiltViaRelay stores the quilt through the walrus binary, which handles
+// the relay's registration and tip. Patches are spooled to temporary files.
+func (c *WalrusClient) storeQuiltViaRelay(ctx context.Context, patches []QuiltPatch, opts StoreOptions) (*QuiltStoreResponse, error) {
+	if err := c.RelayReady(); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "walrus-quilt-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := make([]QuiltFile, len(patches))
+	for i, patch := range patches {
+		path := filepath.Join(dir, fmt.Sprintf("patch-%d", i))
+		if err := spoolPatch(patch, path); err != nil {
+			return nil, err
+		}
+		files[i] = QuiltFile{Path: path, Identifier: patch.Identifier, Tags: patch.Tags}
+	}
+
+	opts.UploadRelayURL = c.UploadRelayURL
+	resp, err := c.walrusCLI().StoreQuilt(ctx, files, opts)
+	if err != nil {
+		return nil, fmt.Errorf("uploading quilt through relay: %w", err)
+	}
+	return resp, nil
+}
+
+func spoolPatch(patch QuiltPatch, path string) error {
+	body, err := patch.Open()
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", patch.Identifier, err)
+	}
+	defer body.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return fmt.Errorf("spooling %s: %w", patch.Identifier, err)
+	}
+	return file.Close()
+}
+
+// quiltPatchResource is the aggregator path serving a single quilt patch
+func quiltPatchResource(patchID string) string {
+	return "/v1/blobs/by-quilt-patch-id/" + url.PathEscape(patchID)
+}
+
+// quiltFileResource is the aggregator path serving a quilt patch by identifier
+func quiltFileResource(quiltID, identifier string) string {
+	return "/v1/blobs/by-quilt-id/" + url.PathEscape(quiltID) + "/" + url.PathEscape(identifier)
+}
+
+// RetrieveQuiltPatchTo streams a single quilt patch into w
+func (c *WalrusClient) RetrieveQuiltPatchTo(patchID string, w io.Writer) (int64, error) {
+	return c.RetrieveQuiltPatchToContext(context.Background(), patchID, w)
+}
+
+// RetrieveQuiltPatchToContext is RetrieveQuiltPatchTo bound to ctx
+func (c *WalrusClient) RetrieveQuiltPatchToContext(ctx context.Context, patchID string, w io.Writer) (int64, error) {
+	return c.streamBlob(ctx, quiltPatchResource(patchID), 0, -1, w)
+}
+
+// RetrieveQuiltFileTo streams the patch named identifier from quilt quiltID into w
+func (c *WalrusClient) RetrieveQuiltFileTo(quiltID, identifier string, w io.Writer) (int64, error) {
+	return c.RetrieveQuiltFileToContext(context.Background(), quiltID, identifier, w)
+}
+
+// RetrieveQuiltFileToContext is RetrieveQuiltFileTo bound to ctx
+func (c *WalrusClient) RetrieveQuiltFileToContext(ctx context.Context, quiltID, identifier string, w io.Writer) (int64, error) {
+	return c.streamBlob(ctx, quiltFileResource(quiltID, identifier), 0, -1, w)
+}
+
+// DownloadQuiltPatchToFile streams a quilt patch into path with the same
+// .part staging and resume behaviour as DownloadBlobToFile
+func (c *WalrusClient) DownloadQuiltPatchToFile(patchID, path string, opts DownloadOptions) (int64, error) {
+	return c.DownloadQuiltPatchToFileContext(context.Background(), patchID, path, opts)
+}
+
+// DownloadQuiltPatchToFileContext is DownloadQuiltPatchToFile bound to ctx
+func (c *WalrusClient) DownloadQuiltPatchToFileContext(ctx context.Context, patchID, path string, opts DownloadOptions) (int64, error) {
+	return c.downloadToFile(ctx, quiltPatchResource(patchID), path, opts)
+}
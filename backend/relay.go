@@ -0,0 +1,228 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// DefaultUploadRelayURL is the public testnet upload relay
+const DefaultUploadRelayURL = "https://upload-relay.testnet.walrus.space"
+
+// TipConfig is what an upload relay charges for encoding and distributing a
+// blob. Relays that charge expect the tip to be paid in the same Sui
+// transaction that registers the blob.
+type TipConfig struct {
+	NoTip   bool
+	Address string // Sui address the tip is sent to
+	Kind    TipKind
+}
+
+// TipKind describes how the tip amount is computed, in MIST
+type TipKind struct {
+	Const  *uint64    `json:"const,omitempty"`
+	Linear *LinearTip `json:"linear,omitempty"`
+}
+
+// LinearTip charges a base amount plus a rate per KiB of encoded blob
+type LinearTip struct {
+	Base                 uint64 `json:"base"`
+	EncodedSizeMulPerKiB uint64 `json:"encoded_size_mul_per_kib"`
+}
+
+// UnmarshalJSON decodes the relay's representation, which is either the
+// string "no_tip" or {"send_tip": {"address": ..., "kind": ...}}
+func (t *TipConfig) UnmarshalJSON(data []byte) error {
+	var tag string
+	if err := json.Unmarshal(data, &tag); err == nil {
+		if tag != "no_tip" {
+			return fmt.Errorf("unknown tip config %q", tag)
+		}
+		*t = TipConfig{NoTip: true}
+		return nil
+	}
+
+	var payload struct {
+		SendTip *struct {
+			Address string  `json:"address"`
+			Kind    TipKind `json:"kind"`
+		} `json:"send_tip"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	if payload.SendTip == nil {
+		return fmt.Errorf("unknown tip config: %s", string(data))
+	}
+	*t = TipConfig{Address: payload.SendTip.Address, Kind: payload.SendTip.Kind}
+	return nil
+}
+
+// DefaultShardCount is the number of shards on Walrus mainnet and testnet
+const DefaultShardCount = 1000
+
+// EncodedBlobSize returns the size of a blob of sizeBytes once it is encoded
+// for a network of nShards shards (DefaultShardCount if nShards is 0), as
+// Walrus computes it: every shard holds a primary and a secondary sliver
+// plus a copy of the blob's metadata, which has two hashes per shard.
+func EncodedBlobSize(sizeBytes int64, nShards int) int64 {
+	if nShards <= 0 {
+		nShards = DefaultShardCount
+	}
+	n := int64(nShards)
+	faulty := (n - 1) / 3
+	primary, secondary := n-2*faulty, n-faulty
+
+	// Symbols hold an even number of bytes, and at least two
+	symbolSize := max((sizeBytes+primary*secondary-1)/(primary*secondary), 1)
+	symbolSize += symbolSize % 2
+
+	const digestLen, blobIDLen = 32, 32
+	metadata := n*digestLen*2 + blobIDLen
+	return n * ((primary+secondary)*symbolSize + metadata)
+}
+
+// TipFor returns the tip in MIST for a blob of sizeBytes unencoded bytes on a
+// network of nShards shards (0 for DefaultShardCount)
+func (t TipConfig) TipFor(sizeBytes int64, nShards int) uint64 {
+	switch {
+	case t.NoTip:
+		return 0
+	case t.Kind.Const != nil:
+		return *t.Kind.Const
+	case t.Kind.Linear != nil:
+		encodedKiB := uint64((EncodedBlobSize(sizeBytes, nShards) + 1023) / 1024)
+		return t.Kind.Linear.Base + encodedKiB*t.Kind.Linear.EncodedSizeMulPerKiB
+	default:
+		return 0
+	}
+}
+
+// RelayTipConfig asks the upload relay what it charges
+func (c *WalrusClient) RelayTipConfig(ctx context.Context) (*TipConfig, error) {
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	var tip *TipConfig
+	err := c.Retry.Do(ctx, func(attempt int) error {
+		var err error
+		tip, err = c.relayTipConfigOnce(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tip, nil
+}
+
+func (c *WalrusClient) relayTipConfigOnce(ctx context.Context) (*TipConfig, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.UploadRelayURL+"/v1/tip-config", nil)
+	if err != nil {
+		return nil, Permanent(fmt.Errorf("creating request: %w", err))
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching tip config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPStatusError("tip config", resp)
+	}
+
+	var tip TipConfig
+	if err := json.NewDecoder(resp.Body).Decode(&tip); err != nil {
+		return nil, Permanent(fmt.Errorf("decoding tip config: %w", err))
+	}
+	return &tip, nil
+}
+
+// RelayReady reports why uploads through the relay cannot run. The relay
+// protocol needs the blob encoded locally, registered on Sui together with
+// the tip payment, and certified with the relay's confirmation certificate
+// afterwards. All of that is signed with the user's wallet, so this client
+// drives it through the official walrus binary, which must be installed.
+func (c *WalrusClient) RelayReady() error {
+	if c.UploadRelayURL == "" {
+		return fmt.Errorf("no upload relay configured")
+	}
+	if err := c.walrusCLI().Check(); err != nil {
+		return fmt.Errorf("uploading through a relay needs the walrus binary: %w", err)
+	}
+	return nil
+}
+
+// storeViaRelay uploads through the upload relay with the walrus binary, once
+// RelayReady says it can. The call is not retried: a failure after
+// registration would pay twice.
+func (c *WalrusClient) storeViaRelay(ctx context.Context, open BodyOpener, size int64, opts StoreOptions) (*StoreResponse, error) {
+	if err := c.RelayReady(); err != nil {
+		return nil, err
+	}
+	tip, err := c.RelayTipConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching upload relay tip config: %w", err)
+	}
+	amount := tip.TipFor(size, c.ShardCount)
+	if c.MaxRelayTip > 0 && amount > c.MaxRelayTip {
+		return nil, fmt.Errorf("upload relay tip of %d MIST exceeds the configured maximum of %d MIST", amount, c.MaxRelayTip)
+	}
+
+	// The binary reads from a path, so spool the body to a temporary file
+	path, err := spoolBody(open, size)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	opts.UploadRelayURL = c.UploadRelayURL
+	resp, err := c.walrusCLI().Store(ctx, path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("uploading through relay: %w", err)
+	}
+	resp.RelayTip = amount
+	if resp.Size == 0 {
+		resp.Size = size
+	}
+	return resp, nil
+}
+
+// walrusCLI returns the configured walrus binary wrapper or the default one
+func (c *WalrusClient) walrusCLI() *WalrusCLI {
+	if c.WalrusCLI == nil {
+		return NewWalrusCLI(DefaultWalrusBinary)
+	}
+	return c.WalrusCLI
+}
+
+// spoolBody copies size bytes from a fresh body into a temporary file and
+// returns its path
+func spoolBody(open BodyOpener, size int64) (string, error) {
+	body, err := open()
+	if err != nil {
+		return "", fmt.Errorf("opening upload body: %w", err)
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp("", "walrus-upload-*")
+	if err != nil {
+		return "", fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	n, err := io.Copy(tmp, io.LimitReader(body, size))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n != size {
+		err = fmt.Errorf("upload body ended after %d of %d bytes", n, size)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("spooling upload body: %w", err)
+	}
+	return tmp.Name(), nil
+}
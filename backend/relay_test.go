@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEncodedBlobSize(t *testing.T) {
+	tests := []struct {
+		size    int64
+		nShards int
+		want    int64
+	}{
+		// 1000 shards: 334 primary and 667 secondary source symbols, and
+		// 64,032 bytes of metadata per shard
+		{0, 0, 1000 * (1001*2 + 64032)},
+		{1, 1000, 1000 * (1001*2 + 64032)},
+		{334 * 667 * 2, 1000, 1000 * (1001*2 + 64032)},
+		{334*667*2 + 1, 1000, 1000 * (1001*4 + 64032)},
+		{1 << 20, 1000, 1000 * (1001*6 + 64032)},
+		// 10 shards: 4 primary and 7 secondary symbols
+		{100, 10, 10 * (11*4 + 672)},
+		{29, 10, 10 * (11*2 + 672)},
+	}
+	for _, tt := range tests {
+		if got := EncodedBlobSize(tt.size, tt.nShards); got != tt.want {
+			t.Errorf("EncodedBlobSize(%d, %d) = %d, want %d", tt.size, tt.nShards, got, tt.want)
+		}
+	}
+}
+
+func TestTipFor(t *testing.T) {
+	decode := func(s string) TipConfig {
+		t.Helper()
+		var tip TipConfig
+		if err := json.Unmarshal([]byte(s), &tip); err != nil {
+			t.Fatal(err)
+		}
+		return tip
+	}
+
+	if tip := decode(`"no_tip"`).TipFor(1<<20, 0); tip != 0 {
+		t.Errorf("no_tip charges %d", tip)
+	}
+	if tip := decode(`{"send_tip":{"address":"0x1","kind":{"const":500}}}`).TipFor(1<<20, 0); tip != 500 {
+		t.Errorf("const tip = %d, want 500", tip)
+	}
+	linear := decode(`{"send_tip":{"address":"0x1","kind":{"linear":{"base":100,"encoded_size_mul_per_kib":2}}}}`)
+	if linear.Address != "0x1" {
+		t.Errorf("tip address = %q", linear.Address)
+	}
+	// 66,034,000 encoded bytes are 64,487 KiB
+	if tip := linear.TipFor(0, 1000); tip != 100+64487*2 {
+		t.Errorf("linear tip = %d, want %d", tip, 100+64487*2)
+	}
+}
+
+func TestStoreViaRelayNeedsWalrusBinary(t *testing.T) {
+	var requests atomic.Int32
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.WriteString(w, `"no_tip"`)
+	}))
+	defer relay.Close()
+
+	client := NewWalrusClient(relay.URL, relay.URL)
+	client.UseUploadRelay = true
+	client.UploadRelayURL = relay.URL
+	client.WalrusCLI = &WalrusCLI{Binary: "/nonexistent/walrus"}
+
+	_, err := client.StoreBlobWithOptionsContext(context.Background(), readerOpener(strings.NewReader("data")), 4, StoreOptions{Epochs: 1})
+	if err == nil || !strings.Contains(err.Error(), "needs the walrus binary") {
+		t.Fatalf("error = %v, want one saying the walrus binary is needed", err)
+	}
+	if requests.Load() != 0 {
+		t.Fatalf("relay got %d requests before the missing binary was noticed", requests.Load())
+	}
+}
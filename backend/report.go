@@ -0,0 +1,219 @@
+package backend
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TransferReport is a machine-readable record of an S3 transfer: where every
+// object ended up on Walrus, what failed, and the totals of the run
+type TransferReport struct {
+	Bucket  string                `json:"bucket"`
+	Prefix  string                `json:"prefix,omitempty"`
+	Epochs  int                   `json:"epochs,omitempty"` // Zero for exports
+	Summary TransferReportSummary `json:"summary"`
+	Objects []TransferReportEntry `json:"objects"`
+}
+
+// TransferReportSummary holds the totals of a transfer
+type TransferReportSummary struct {
+	StartedAt        time.Time `json:"started_at"`
+	FinishedAt       time.Time `json:"finished_at"`
+	DurationSeconds  float64   `json:"duration_seconds"`
+	TotalFiles       int       `json:"total_files"`
+	Succeeded        int       `json:"succeeded"`
+	Failed           int       `json:"failed"`
+	Skipped          int       `json:"skipped"`
+	NotStarted       int       `json:"not_started"` // Queued but never run, e.g. after an interrupt
+	TotalBytes       int64     `json:"total_bytes"`
+	TransferredBytes int64     `json:"transferred_bytes"`
+	EstimatedCostWAL float64   `json:"estimated_cost_wal"` // For the objects that were stored
+	Verified         int       `json:"verified"`
+}
+
+// TransferReportEntry is the outcome for one S3 object
+type TransferReportEntry struct {
+	Key              string    `json:"key"`
+	TargetName       string    `json:"target_name"`
+	Status           string    `json:"status"` // "stored" or "failed"
+	Size             int64     `json:"size"`
+	BlobID           string    `json:"blob_id,omitempty"`
+	SuiObjectID      string    `json:"sui_object_id,omitempty"`
+	RegisteredEpoch  *int64    `json:"registered_epoch,omitempty"`
+	ExpiryEpoch      *int64    `json:"expiry_epoch,omitempty"`
+	EstimatedCostWAL float64   `json:"estimated_cost_wal"`
+	MD5              string    `json:"md5,omitempty"`
+	SHA256           string    `json:"sha256,omitempty"`
+	Verified         bool      `json:"verified"`
+	Error            string    `json:"error,omitempty"`
+	StartedAt        time.Time `json:"started_at"`
+}
+
+// NewTransferReport builds a report from the progress of a finished or
+// interrupted transfer. Objects are ordered by key.
+func NewTransferReport(bucket, prefix string, epochs int, progress *TransferProgress) *TransferReport {
+	finished := time.Now()
+	report := &TransferReport{
+		Bucket: bucket,
+		Prefix: prefix,
+		Epochs: epochs,
+		Summary: TransferReportSummary{
+			StartedAt:        progress.StartTime,
+			FinishedAt:       finished,
+			DurationSeconds:  finished.Sub(progress.StartTime).Seconds(),
+			TotalFiles:       progress.TotalFiles,
+			Skipped:          progress.SkippedFiles,
+			TotalBytes:       progress.TotalBytes,
+			TransferredBytes: progress.ProcessedBytes,
+		},
+		Objects: make([]TransferReportEntry, 0, len(progress.Results)),
+	}
+
+	for _, result := range progress.Results {
+		entry := TransferReportEntry{
+			Key:         result.SourceKey,
+			TargetName:  result.TargetName,
+			Status:      "stored",
+			Size:        result.Size,
+			BlobID:      result.BlobID,
+			SuiObjectID: result.SuiObjectID,
+			StartedAt:   result.UploadTime,
+			Verified:    result.Verified,
+		}
+		if result.Checksums != nil {
+			entry.MD5 = result.Checksums.MD5
+			entry.SHA256 = result.Checksums.SHA256
+		}
+		if result.Verified {
+			report.Summary.Verified++
+		}
+		if result.Success {
+			entry.RegisteredEpoch = result.RegisteredEpoch
+			entry.ExpiryEpoch = result.ExpiryEpoch
+			entry.EstimatedCostWAL = result.EstimatedCost
+			report.Summary.Succeeded++
+			report.Summary.EstimatedCostWAL += result.EstimatedCost
+		} else {
+			entry.Status = "failed"
+			report.Summary.Failed++
+		}
+		// Stored objects can carry a warning, such as attributes that failed
+		if result.Error != nil {
+			entry.Error = result.Error.Error()
+		}
+		report.Objects = append(report.Objects, entry)
+	}
+	report.Summary.NotStarted = report.Summary.TotalFiles - len(report.Objects)
+
+	sort.Slice(report.Objects, func(i, j int) bool {
+		return report.Objects[i].Key < report.Objects[j].Key
+	})
+	return report
+}
+
+// ReportFormat returns "json" or "csv" for a report path, judged by its extension
+func ReportFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json", nil
+	case ".csv":
+		return "csv", nil
+	default:
+		return "", fmt.Errorf("report file must end in .json or .csv: %s", path)
+	}
+}
+
+// Write saves the report to path as JSON or CSV, depending on its extension
+func (r *TransferReport) Write(path string) error {
+	format, err := ReportFormat(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating report: %w", err)
+	}
+	defer file.Close()
+
+	if format == "csv" {
+		err = r.writeCSV(file)
+	} else {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(r)
+	}
+	if err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return file.Close()
+}
+
+// writeCSV writes one row per object, then a blank row and the summary as
+// field,value rows
+func (r *TransferReport) writeCSV(file *os.File) error {
+	w := csv.NewWriter(file)
+
+	w.Write([]string{
+		"key", "target_name", "status", "size", "blob_id", "sui_object_id",
+		"registered_epoch", "expiry_epoch", "estimated_cost_wal", "md5", "sha256", "verified",
+		"error", "started_at",
+	})
+	for _, e := range r.Objects {
+		w.Write([]string{
+			e.Key,
+			e.TargetName,
+			e.Status,
+			strconv.FormatInt(e.Size, 10),
+			e.BlobID,
+			e.SuiObjectID,
+			formatEpoch(e.RegisteredEpoch),
+			formatEpoch(e.ExpiryEpoch),
+			strconv.FormatFloat(e.EstimatedCostWAL, 'f', 6, 64),
+			e.MD5,
+			e.SHA256,
+			strconv.FormatBool(e.Verified),
+			e.Error,
+			e.StartedAt.Format(time.RFC3339),
+		})
+	}
+
+	s := r.Summary
+	w.Write(nil)
+	for _, row := range [][2]string{
+		{"bucket", r.Bucket},
+		{"prefix", r.Prefix},
+		{"epochs", strconv.Itoa(r.Epochs)},
+		{"started_at", s.StartedAt.Format(time.RFC3339)},
+		{"finished_at", s.FinishedAt.Format(time.RFC3339)},
+		{"duration_seconds", strconv.FormatFloat(s.DurationSeconds, 'f', 1, 64)},
+		{"total_files", strconv.Itoa(s.TotalFiles)},
+		{"succeeded", strconv.Itoa(s.Succeeded)},
+		{"failed", strconv.Itoa(s.Failed)},
+		{"skipped", strconv.Itoa(s.Skipped)},
+		{"not_started", strconv.Itoa(s.NotStarted)},
+		{"total_bytes", strconv.FormatInt(s.TotalBytes, 10)},
+		{"transferred_bytes", strconv.FormatInt(s.TransferredBytes, 10)},
+		{"estimated_cost_wal", strconv.FormatFloat(s.EstimatedCostWAL, 'f', 6, 64)},
+		{"verified", strconv.Itoa(s.Verified)},
+	} {
+		w.Write(row[:])
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func formatEpoch(epoch *int64) string {
+	if epoch == nil {
+		return ""
+	}
+	return strconv.FormatInt(*epoch, 10)
+}
@@ -0,0 +1,255 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// maxRetryAfter caps how long a server-provided Retry-After can stall a call
+const maxRetryAfter = 5 * time.Minute
+
+// RetryPolicy controls how network calls are retried. Zero fields fall back
+// to the values from DefaultRetryPolicy.
+type RetryPolicy struct {
+	MaxAttempts    int           `yaml:"max_attempts,omitempty"`    // Total tries including the first one
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty"` // Delay before the first retry
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty"`     // Upper bound for any single delay
+	Multiplier     float64       `yaml:"multiplier,omitempty"`      // Growth factor between retries
+	Jitter         float64       `yaml:"jitter,omitempty"`          // Random spread as a fraction of the delay (0-1)
+}
+
+// DefaultRetryPolicy returns the retry policy used when nothing is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// WithDefaults fills any unset fields from DefaultRetryPolicy
+func (p RetryPolicy) WithDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = def.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = def.MaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = def.Multiplier
+	}
+	if p.Jitter <= 0 || p.Jitter > 1 {
+		p.Jitter = def.Jitter
+	}
+	return p
+}
+
+// Backoff returns how long to wait before retry number attempt (1-based).
+// A Retry-After carried by err takes precedence over the computed delay.
+func (p RetryPolicy) Backoff(attempt int, err error) time.Duration {
+	p = p.WithDefaults()
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return min(statusErr.RetryAfter, maxRetryAfter)
+	}
+
+	delay := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable,
+// runs out of attempts, or ctx is done. fn receives the 1-based attempt number.
+func (p RetryPolicy) Do(ctx context.Context, fn func(attempt int) error) error {
+	p = p.WithDefaults()
+
+	var err error
+	for attempt := 1; attempt <= p.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+			case <-time.After(p.Backoff(attempt-1, err)):
+			}
+		}
+
+		err = fn(attempt)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || !IsRetryable(err) {
+			return unwrapDecision(err)
+		}
+	}
+
+	if p.MaxAttempts > 1 {
+		return fmt.Errorf("failed after %d attempts: %w", p.MaxAttempts, unwrapDecision(err))
+	}
+	return unwrapDecision(err)
+}
+
+// HTTPStatusError reports an unexpected HTTP status from a Walrus or Sui endpoint
+type HTTPStatusError struct {
+	Op         string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s failed with status %d", e.Op, e.StatusCode)
+	}
+	return fmt.Sprintf("%s failed with status %d: %s", e.Op, e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the status is worth retrying
+func (e *HTTPStatusError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// newHTTPStatusError builds an HTTPStatusError from resp, consuming a bounded
+// amount of the body for the message
+func newHTTPStatusError(op string, resp *http.Response) *HTTPStatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &HTTPStatusError{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter understands both delay-seconds and HTTP-date forms
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := when.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// retryDecision overrides the type-based classification of an error
+type retryDecision struct {
+	err   error
+	retry bool
+}
+
+func (e *retryDecision) Error() string { return e.err.Error() }
+func (e *retryDecision) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying regardless of its type
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryDecision{err: err, retry: false}
+}
+
+// Retryable marks err as transient regardless of its type
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryDecision{err: err, retry: true}
+}
+
+func unwrapDecision(err error) error {
+	var decision *retryDecision
+	if errors.As(err, &decision) && decision == err {
+		return decision.err
+	}
+	return err
+}
+
+// IsRetryable classifies an error from a network call as transient or not
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var decision *retryDecision
+	if errors.As(err, &decision) {
+		return decision.retry
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	for _, errno := range []syscall.Errno{
+		syscall.ECONNREFUSED,
+		syscall.ECONNRESET,
+		syscall.ECONNABORTED,
+		syscall.EPIPE,
+		syscall.ENETUNREACH,
+		syscall.EHOSTUNREACH,
+		syscall.ETIMEDOUT,
+	} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary || dnsErr.IsNotFound
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
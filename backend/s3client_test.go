@@ -0,0 +1,104 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestUploadObjectPartBoundaries(t *testing.T) {
+	tests := []struct {
+		size  int
+		parts []int // Part sizes of a multipart upload, nil for PutObject
+	}{
+		{0, nil},
+		{1, nil},
+		{s3PartSize - 1, nil},
+		{s3PartSize, []int{s3PartSize}},
+		{s3PartSize + 1, []int{s3PartSize, 1}},
+		{2*s3PartSize + 1, []int{s3PartSize, s3PartSize, 1}},
+	}
+	fs, client := newFakeS3(t)
+	for _, tt := range tests {
+		data := make([]byte, tt.size)
+		rand.New(rand.NewSource(int64(tt.size))).Read(data)
+		opts := S3PutOptions{ContentType: "text/plain", Metadata: map[string]string{"origin": "walrus"}}
+
+		// A plain reader, so nothing can find the size up front
+		written, err := client.UploadObject(context.Background(), "bucket", "object", io.MultiReader(bytes.NewReader(data)), opts)
+		if err != nil {
+			t.Fatalf("size %d: %v", tt.size, err)
+		}
+		if written != int64(tt.size) {
+			t.Errorf("size %d: wrote %d bytes", tt.size, written)
+		}
+		obj := fs.object("bucket", "object")
+		if obj == nil {
+			t.Fatalf("size %d: nothing stored", tt.size)
+		}
+		if !bytes.Equal(obj.data, data) {
+			t.Errorf("size %d: stored content differs", tt.size)
+		}
+		if !reflect.DeepEqual(obj.partSizes, tt.parts) {
+			t.Errorf("size %d: parts %v, want %v", tt.size, obj.partSizes, tt.parts)
+		}
+		if obj.contentType != "text/plain" || obj.metadata["origin"] != "walrus" {
+			t.Errorf("size %d: content type %q and metadata %v were not kept", tt.size, obj.contentType, obj.metadata)
+		}
+	}
+	if len(fs.uploads) != 0 || fs.aborted != 0 {
+		t.Fatalf("%d uploads left open and %d aborted", len(fs.uploads), fs.aborted)
+	}
+}
+
+func TestUploadObjectAbortsFailedMultipart(t *testing.T) {
+	fs, client := newFakeS3(t)
+	fs.failPart = 2
+
+	data := make([]byte, 2*s3PartSize)
+	if _, err := client.UploadObject(context.Background(), "bucket", "object", bytes.NewReader(data), S3PutOptions{}); err == nil {
+		t.Fatal("upload succeeded with a rejected part")
+	}
+	if fs.aborted != 1 || len(fs.uploads) != 0 {
+		t.Fatalf("%d uploads aborted and %d left open, want the upload aborted", fs.aborted, len(fs.uploads))
+	}
+	if fs.object("bucket", "object") != nil {
+		t.Fatal("an object was created")
+	}
+}
+
+func TestS3ClientListAndRead(t *testing.T) {
+	fs, client := newFakeS3(t)
+	fs.put("bucket", "docs/a.txt", []byte("hello, walrus"), "text/plain")
+	fs.put("bucket", "docs/b.log", []byte("log"), "text/plain")
+	fs.put("bucket", "other/c.txt", []byte("other"), "text/plain").tags = map[string]string{"team": "storage"}
+
+	objects, err := client.ListObjects(context.Background(), "bucket", &S3TransferFilter{Prefix: "docs/", Include: []string{"*.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Key != "docs/a.txt" || objects[0].Size != 13 {
+		t.Fatalf("ListObjects = %+v, want only docs/a.txt", objects)
+	}
+
+	body, err := client.OpenObjectRange(context.Background(), "bucket", "docs/a.txt", "", 7, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if string(got) != "walrus" {
+		t.Fatalf("OpenObjectRange = %q, want %q", got, "walrus")
+	}
+
+	tags, err := client.ObjectTags(context.Background(), "bucket", "other/c.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, map[string]string{"team": "storage"}) {
+		t.Fatalf("ObjectTags = %v", tags)
+	}
+}
@@ -3,10 +3,13 @@ package backend
 // This file provides a simple interface for the CLI without Rclone dependencies
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
@@ -25,10 +28,32 @@ type SimpleFileIndex struct {
 
 // SimpleFileEntry represents a file in the index
 type SimpleFileEntry struct {
-	BlobID      string    `json:"blob_id"`
-	Size        int64     `json:"size"`
-	ModTime     time.Time `json:"mod_time"`
-	ExpiryEpoch int       `json:"expiry_epoch"`
+	BlobID      string            `json:"blob_id"`
+	Size        int64             `json:"size"`
+	ModTime     time.Time         `json:"mod_time"`
+	ExpiryEpoch int               `json:"expiry_epoch"`
+	SuiObjectID string            `json:"sui_object_id,omitempty"`
+	Deletable   bool              `json:"deletable,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Source      *SourceObject     `json:"source,omitempty"`
+	Checksums   *Checksums        `json:"checksums,omitempty"`   // Recorded by verified transfers
+	Encryption  *EncryptionInfo   `json:"encryption,omitempty"`  // Set when the blob holds encrypted content
+	Parts       []ChunkPart       `json:"parts,omitempty"`       // Set when BlobID is the manifest of a chunked file
+	Compression *CompressionInfo  `json:"compression,omitempty"` // Set when the content was compressed before it was stored
+}
+
+// SourceObject identifies the S3 object an index entry was copied from, as it
+// was when copied. Sync compares it against the bucket to find changes.
+type SourceObject struct {
+	Bucket       string            `json:"bucket"`
+	Key          string            `json:"key"`
+	VersionID    string            `json:"version_id,omitempty"`
+	ETag         string            `json:"etag,omitempty"`
+	LastModified time.Time         `json:"last_modified"`
+	CacheControl string            `json:"cache_control,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`   // User metadata (x-amz-meta-*)
+	DeletedAt    *time.Time        `json:"deleted_at,omitempty"` // Set once the object is gone upstream
 }
 
 // NewSimpleFs creates a new simple filesystem
@@ -41,24 +66,18 @@ func NewSimpleFs(aggregatorURL, publisherURL string) *SimpleFs {
 
 // Upload stores a file in Walrus
 func (fs *SimpleFs) Upload(name string, data []byte, epochs int) (*StoreResponse, error) {
-	resp, err := fs.client.StoreBlob(data, epochs)
+	return fs.UploadFromReader(name, bytes.NewReader(data), int64(len(data)), epochs)
+}
+
+// UploadFromReader streams size bytes from r into Walrus and records the
+// resulting blob under name
+func (fs *SimpleFs) UploadFromReader(name string, r io.Reader, size int64, epochs int) (*StoreResponse, error) {
+	resp, err := fs.client.StoreBlobFromReader(r, size, epochs)
 	if err != nil {
 		return nil, err
 	}
 
-	// Update index
-	expiryEpoch := 0
-	if resp.EndEpoch != nil {
-		expiryEpoch = int(*resp.EndEpoch)
-	}
-	fs.indexMu.Lock()
-	fs.index.Files[name] = &SimpleFileEntry{
-		BlobID:      resp.BlobID,
-		Size:        int64(len(data)),
-		ModTime:     time.Now(),
-		ExpiryEpoch: expiryEpoch,
-	}
-	fs.indexMu.Unlock()
+	fs.recordUpload(name, resp, SimpleFileEntry{Size: size})
 
 	// Save index
 	if err := fs.SaveIndex(); err != nil {
@@ -69,6 +88,102 @@ func (fs *SimpleFs) Upload(name string, data []byte, epochs int) (*StoreResponse
 	return resp, nil
 }
 
+// recordUpload updates the in-memory index with a freshly stored blob. The
+// caller fills in what it knows about the file; the blob fields come from resp.
+func (fs *SimpleFs) recordUpload(name string, resp *StoreResponse, entry SimpleFileEntry) {
+	entry.BlobID = resp.BlobID
+	entry.ModTime = time.Now()
+	entry.ExpiryEpoch = 0
+	if resp.EndEpoch != nil {
+		entry.ExpiryEpoch = int(*resp.EndEpoch)
+	}
+	entry.SuiObjectID = resp.SuiObjectID
+	entry.Deletable = resp.Deletable
+
+	fs.indexMu.Lock()
+	fs.index.Files[name] = &entry
+	fs.indexMu.Unlock()
+}
+
+// sourceEntries returns the index entries copied from objects in bucket,
+// keyed by object key
+func (fs *SimpleFs) sourceEntries(bucket string) map[string]SimpleFileEntry {
+	fs.indexMu.RLock()
+	defer fs.indexMu.RUnlock()
+
+	entries := make(map[string]SimpleFileEntry)
+	for _, entry := range fs.index.Files {
+		if entry.Source != nil && entry.Source.Bucket == bucket {
+			entries[entry.Source.Key] = *entry
+		}
+	}
+	return entries
+}
+
+// markSourceDeleted tombstones the entries copied from the given keys of
+// bucket. The blobs stay on Walrus; the entries just record that their
+// source is gone. Returns the names of the entries marked.
+func (fs *SimpleFs) markSourceDeleted(bucket string, keys []string) []string {
+	gone := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		gone[key] = true
+	}
+	now := time.Now()
+
+	fs.indexMu.Lock()
+	defer fs.indexMu.Unlock()
+
+	var marked []string
+	for name, entry := range fs.index.Files {
+		if entry.Source == nil || entry.Source.Bucket != bucket || !gone[entry.Source.Key] || entry.Source.DeletedAt != nil {
+			continue
+		}
+		updated := *entry
+		source := *entry.Source
+		source.DeletedAt = &now
+		updated.Source = &source
+		fs.index.Files[name] = &updated
+		marked = append(marked, name)
+	}
+	sort.Strings(marked)
+	return marked
+}
+
+// RemoveBlob drops every index entry pointing at blobID and returns their names
+func (fs *SimpleFs) RemoveBlob(blobID string) []string {
+	fs.indexMu.Lock()
+	defer fs.indexMu.Unlock()
+
+	var removed []string
+	for name, entry := range fs.index.Files {
+		if entry.BlobID == blobID {
+			delete(fs.index.Files, name)
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
+
+// SetBlobExpiry records a new expiry epoch on every index entry pointing at
+// blobID, filling in the blob object ID when it was not known yet, and
+// returns the names of the updated entries
+func (fs *SimpleFs) SetBlobExpiry(blobID, objectID string, expiryEpoch int) []string {
+	fs.indexMu.Lock()
+	defer fs.indexMu.Unlock()
+
+	var updated []string
+	for name, entry := range fs.index.Files {
+		if entry.BlobID == blobID {
+			entry.ExpiryEpoch = expiryEpoch
+			if entry.SuiObjectID == "" {
+				entry.SuiObjectID = objectID
+			}
+			updated = append(updated, name)
+		}
+	}
+	return updated
+}
+
 // Download retrieves a file from Walrus
 func (fs *SimpleFs) Download(name string) ([]byte, error) {
 	fs.indexMu.RLock()
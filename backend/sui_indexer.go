@@ -1,10 +1,11 @@
 package backend
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -12,6 +13,7 @@ import (
 type SuiIndexerClient struct {
 	RPCURL     string
 	HTTPClient *http.Client
+	Retry      RetryPolicy
 }
 
 // SuiObject represents a Sui blockchain object
@@ -64,11 +66,17 @@ func NewSuiIndexerClient(rpcURL string) *SuiIndexerClient {
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		Retry: DefaultRetryPolicy(),
 	}
 }
 
 // GetOwnedObjects fetches objects owned by a specific address
 func (c *SuiIndexerClient) GetOwnedObjects(address string, objectType string) ([]SuiObject, error) {
+	return c.GetOwnedObjectsContext(context.Background(), address, objectType)
+}
+
+// GetOwnedObjectsContext is GetOwnedObjects bound to ctx
+func (c *SuiIndexerClient) GetOwnedObjectsContext(ctx context.Context, address string, objectType string) ([]SuiObject, error) {
 	filter := map[string]interface{}{
 		"MatchAll": []map[string]interface{}{
 			{
@@ -90,16 +98,21 @@ func (c *SuiIndexerClient) GetOwnedObjects(address string, objectType string) ([
 		Params:  []interface{}{address, filter, nil, nil, options},
 	}
 
-	return c.executeRPCRequest(request)
+	return c.executeRPCRequest(ctx, request)
 }
 
 // GetWalrusBlobsForAddress fetches Walrus blob objects for a specific address
 func (c *SuiIndexerClient) GetWalrusBlobsForAddress(address string) ([]WalrusBlobObject, error) {
+	return c.GetWalrusBlobsForAddressContext(context.Background(), address)
+}
+
+// GetWalrusBlobsForAddressContext is GetWalrusBlobsForAddress bound to ctx
+func (c *SuiIndexerClient) GetWalrusBlobsForAddressContext(ctx context.Context, address string) ([]WalrusBlobObject, error) {
 	// Query for Walrus blob objects
 	// The exact type may vary, but typically something like "0x...::blob::Blob" or similar
 	walrusBlobType := "0x*::walrus::Blob" // This is a placeholder - we'll need the actual type
 
-	objects, err := c.GetOwnedObjects(address, walrusBlobType)
+	objects, err := c.GetOwnedObjectsContext(ctx, address, walrusBlobType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get owned objects: %w", err)
 	}
@@ -154,25 +167,10 @@ func (c *SuiIndexerClient) parseWalrusBlobObject(obj SuiObject) (WalrusBlobObjec
 }
 
 // executeRPCRequest executes a JSON-RPC request to Sui
-func (c *SuiIndexerClient) executeRPCRequest(request SuiRPCRequest) ([]SuiObject, error) {
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	resp, err := c.HTTPClient.Post(c.RPCURL, "application/json", strings.NewReader(string(jsonData)))
+func (c *SuiIndexerClient) executeRPCRequest(ctx context.Context, request SuiRPCRequest) ([]SuiObject, error) {
+	rawResult, err := c.callRPC(ctx, request)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	var rpcResp SuiRPCResponse
-	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if rpcResp.Error != nil {
-		return nil, fmt.Errorf("RPC error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		return nil, err
 	}
 
 	var result struct {
@@ -180,7 +178,7 @@ func (c *SuiIndexerClient) executeRPCRequest(request SuiRPCRequest) ([]SuiObject
 		HasNextPage bool                `json:"hasNextPage"`
 	}
 
-	if err := json.Unmarshal(rpcResp.Result, &result); err != nil {
+	if err := json.Unmarshal(rawResult, &result); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
 	}
 
@@ -202,6 +200,51 @@ func (c *SuiIndexerClient) executeRPCRequest(request SuiRPCRequest) ([]SuiObject
 	return objects, nil
 }
 
+// callRPC sends a JSON-RPC request, retrying transport failures and
+// throttling responses according to the client's retry policy
+func (c *SuiIndexerClient) callRPC(ctx context.Context, request SuiRPCRequest) (json.RawMessage, error) {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var result json.RawMessage
+	err = c.Retry.Do(ctx, func(attempt int) error {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.RPCURL, bytes.NewReader(jsonData))
+		if err != nil {
+			return Permanent(fmt.Errorf("failed to create request: %w", err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return newHTTPStatusError("Sui RPC "+request.Method, resp)
+		}
+
+		var rpcResp SuiRPCResponse
+		if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		if rpcResp.Error != nil {
+			return Permanent(fmt.Errorf("RPC error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message))
+		}
+
+		result = rpcResp.Result
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 // Helper functions
 func getString(m map[string]interface{}, key string) string {
 	if val, ok := m[key].(string); ok {
@@ -1,11 +1,12 @@
 package backend
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
-	"path"
+	"os"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -29,27 +30,46 @@ func EstimateWalrusCost(sizeBytes int64, epochs int) float64 {
 }
 
 type TransferManager struct {
-	s3Client      *S3Client
-	walrusClient  *WalrusClient
-	simpleFS      *SimpleFs
-	concurrency   int
-	dryRun        bool
-	enableEncrypt bool
+	s3Client     *S3Client
+	walrusClient *WalrusClient
+	simpleFS     *SimpleFs
+	concurrency  int
+	dryRun       bool
+	deletable    bool
+	keyMapping   KeyMapping
+	journal      *TransferJournal
+	selection    TransferSelection
+	verify       bool
+	attributes   bool
+	compression  string
 }
 
+// TransferSelection picks which listed objects a batch transfer uploads,
+// based on what earlier runs recorded
+type TransferSelection int
+
+const (
+	SelectAll     TransferSelection = iota // Upload every listed object
+	SelectPending                          // Skip objects the journal has as transferred unchanged
+	SelectFailed                           // Only objects whose last journaled attempt failed
+	SelectChanged                          // Only objects new or changed since the index recorded them
+)
+
 type TransferJob struct {
 	Bucket       string
 	Key          string
+	ETag         string
+	LastModified time.Time
 	Size         int64
 	TargetName   string
 	Epochs       int
 	EncryptionConfig *EncryptionSettings
 }
 
+// EncryptionSettings turns on client-side encryption: objects are encrypted
+// with Key before they leave this machine
 type EncryptionSettings struct {
-	Enabled   bool
-	Threshold int
-	PolicyID  string
+	Key *EncryptionKey
 }
 
 type TransferResult struct {
@@ -64,6 +84,8 @@ type TransferResult struct {
 	ExpiryEpoch   *int64
 	RegisteredEpoch *int64
 	SuiObjectID   string
+	Checksums     *Checksums // Set when the transfer was verified
+	Verified      bool
 }
 
 type TransferProgress struct {
@@ -72,6 +94,7 @@ type TransferProgress struct {
 	TotalBytes      int64
 	ProcessedBytes  int64
 	FailedFiles     int32
+	SkippedFiles    int
 	StartTime       time.Time
 	Results         []TransferResult
 	mu              sync.Mutex
@@ -97,8 +120,43 @@ func (tm *TransferManager) SetDryRun(dryRun bool) {
 	tm.dryRun = dryRun
 }
 
-func (tm *TransferManager) SetEncryption(enable bool) {
-	tm.enableEncrypt = enable
+// SetDeletable makes transferred blobs deletable by their owner
+func (tm *TransferManager) SetDeletable(deletable bool) {
+	tm.deletable = deletable
+}
+
+// SetKeyMapping controls how object keys become index names
+func (tm *TransferManager) SetKeyMapping(mapping KeyMapping) {
+	tm.keyMapping = mapping
+}
+
+// SetCompression compresses objects with codec ("zstd" or "gzip") before
+// they are stored; objects of already-compressed content types are skipped
+func (tm *TransferManager) SetCompression(codec string) {
+	tm.compression = codec
+}
+
+// SetJournal records the outcome of every object in journal
+func (tm *TransferManager) SetJournal(journal *TransferJournal) {
+	tm.journal = journal
+}
+
+// SetSelection decides which listed objects a batch uploads
+func (tm *TransferManager) SetSelection(selection TransferSelection) {
+	tm.selection = selection
+}
+
+// SetAttributes also stores each object's content type, metadata, tags and
+// source as blob attributes, at the cost of a wallet transaction per object.
+// They are kept in the index either way.
+func (tm *TransferManager) SetAttributes(attributes bool) {
+	tm.attributes = attributes
+}
+
+// SetVerify hashes every object while it is uploaded and reads the blob back
+// to check it; objects that do not match are reported as failed
+func (tm *TransferManager) SetVerify(verify bool) {
+	tm.verify = verify
 }
 
 func (tm *TransferManager) EstimateTransferCost(ctx context.Context, bucket string, filter *S3TransferFilter, epochs int) (float64, int, error) {
@@ -116,54 +174,243 @@ func (tm *TransferManager) EstimateTransferCost(ctx context.Context, bucket stri
 	return totalCost, len(objects), nil
 }
 
-func (tm *TransferManager) TransferBatch(ctx context.Context, bucket string, filter *S3TransferFilter, epochs int, encryptionConfig *EncryptionSettings) (*TransferProgress, error) {
+// TransferPlan is the work a batch transfer will do: the objects to upload
+// with the names they get, and how many matching objects were skipped
+type TransferPlan struct {
+	Bucket     string
+	Jobs       []TransferJob
+	TotalBytes int64
+	Skipped    int
+}
+
+// EstimatedCost returns the estimated WAL cost of storing every planned object
+func (p *TransferPlan) EstimatedCost(epochs int) float64 {
+	var total float64
+	for _, job := range p.Jobs {
+		total += EstimateWalrusCost(job.Size, epochs)
+	}
+	return total
+}
+
+// PlanTransfer lists the objects matching filter, maps their keys to index
+// names and drops those the journal selection excludes. Name collisions are
+// checked across every matching object, so a resumed run maps keys exactly
+// like the first one did.
+func (tm *TransferManager) PlanTransfer(ctx context.Context, bucket string, filter *S3TransferFilter) (*TransferPlan, error) {
 	objects, err := tm.s3Client.ListObjects(ctx, bucket, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list objects: %w", err)
 	}
+	objects = withoutDirectoryMarkers(objects)
 
-	if len(objects) == 0 {
-		return &TransferProgress{
-			TotalFiles: 0,
-			StartTime:  time.Now(),
-		}, nil
+	targetNames, err := tm.keyMapping.MapKeys(objectKeys(objects))
+	if err != nil {
+		return nil, err
+	}
+
+	var synced map[string]SimpleFileEntry
+	if tm.selection == SelectChanged && tm.simpleFS != nil {
+		synced = tm.simpleFS.sourceEntries(bucket)
 	}
 
-	var totalSize int64
-	jobs := make([]TransferJob, 0, len(objects))
+	plan := &TransferPlan{Bucket: bucket}
 	for _, obj := range objects {
-		totalSize += obj.Size
+		if !tm.selected(bucket, obj, synced) {
+			plan.Skipped++
+			continue
+		}
+		plan.TotalBytes += obj.Size
+		plan.Jobs = append(plan.Jobs, TransferJob{
+			Bucket:       bucket,
+			Key:          obj.Key,
+			ETag:         obj.ETag,
+			LastModified: obj.LastModified,
+			Size:         obj.Size,
+			TargetName:   targetNames[obj.Key],
+		})
+	}
+	return plan, nil
+}
 
-		targetName := path.Base(obj.Key)
-		if targetName == "" {
-			targetName = obj.Key
+// selected reports whether obj is uploaded under the current selection.
+// synced holds the index entries copied from bucket, keyed by object key.
+func (tm *TransferManager) selected(bucket string, obj S3Object, synced map[string]SimpleFileEntry) bool {
+	switch tm.selection {
+	case SelectPending:
+		return tm.journal == nil || !tm.journal.Completed(bucket, obj)
+	case SelectFailed:
+		if tm.journal == nil {
+			return false
 		}
+		entry, ok := tm.journal.Lookup(bucket, obj.Key)
+		return ok && entry.Status == JournalFailed
+	case SelectChanged:
+		entry, ok := synced[obj.Key]
+		return !ok || sourceChanged(entry, obj)
+	default:
+		return true
+	}
+}
 
-		jobs = append(jobs, TransferJob{
-			Bucket:           bucket,
-			Key:              obj.Key,
-			Size:             obj.Size,
-			TargetName:       targetName,
-			Epochs:           epochs,
-			EncryptionConfig: encryptionConfig,
-		})
+// skipReason says why the objects the current selection leaves out are skipped
+func (tm *TransferManager) skipReason() string {
+	if tm.selection == SelectChanged {
+		return "unchanged since the last sync"
+	}
+	return "according to the transfer journal"
+}
+
+// sourceChanged reports whether obj differs from the copy recorded in entry.
+// ETags decide when both sides have one; otherwise a newer modification time
+// or a different size counts as a change.
+func sourceChanged(entry SimpleFileEntry, obj S3Object) bool {
+	src := entry.Source
+	if src == nil || src.DeletedAt != nil || entry.Size != obj.Size {
+		return true
+	}
+	if src.ETag != "" && obj.ETag != "" {
+		return src.ETag != obj.ETag
+	}
+	return obj.LastModified.After(src.LastModified)
+}
+
+// DeletedUpstream returns the keys of objects in bucket that the index holds
+// copies of but that no longer exist under prefix. A bucket listing with
+// include/exclude filters cannot answer this, so the whole prefix is listed.
+func (tm *TransferManager) DeletedUpstream(ctx context.Context, bucket, prefix string) ([]string, error) {
+	if tm.simpleFS == nil {
+		return nil, nil
+	}
+
+	objects, err := tm.s3Client.ListObjects(ctx, bucket, &S3TransferFilter{Prefix: prefix})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	present := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		present[obj.Key] = true
+	}
+
+	var deleted []string
+	for key, entry := range tm.simpleFS.sourceEntries(bucket) {
+		if strings.HasPrefix(key, prefix) && !present[key] && entry.Source.DeletedAt == nil {
+			deleted = append(deleted, key)
+		}
+	}
+	sort.Strings(deleted)
+	return deleted, nil
+}
+
+// Tombstone marks the index entries copied from keys of bucket as deleted
+// upstream and saves the index. Their blobs are left alone on Walrus.
+func (tm *TransferManager) Tombstone(bucket string, keys []string) ([]string, error) {
+	if tm.simpleFS == nil || len(keys) == 0 {
+		return nil, nil
+	}
+	marked := tm.simpleFS.markSourceDeleted(bucket, keys)
+	if err := tm.simpleFS.SaveIndex(); err != nil {
+		return marked, fmt.Errorf("saving transfer index: %w", err)
+	}
+	return marked, nil
+}
+
+// journalResult records how a job ended. Jobs cut short by cancellation are
+// left out so that a resumed run picks them up again.
+func (tm *TransferManager) journalResult(ctx context.Context, job TransferJob, result TransferResult) {
+	if tm.journal == nil || (!result.Success && ctx.Err() != nil) {
+		return
+	}
+
+	entry := JournalEntry{
+		Bucket:     job.Bucket,
+		Key:        job.Key,
+		ETag:       job.ETag,
+		Size:       job.Size,
+		Status:     JournalDone,
+		TargetName: result.TargetName,
+		BlobID:     result.BlobID,
+	}
+	if !result.Success {
+		entry.Status = JournalFailed
+		if result.Error != nil {
+			entry.Error = result.Error.Error()
+		}
+	}
+	if err := tm.journal.Record(entry); err != nil {
+		// Log but don't fail; the upload itself went through
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// withoutDirectoryMarkers drops the empty "folder/" objects the S3 console
+// creates; they hold no data and have no name of their own once mapped
+func withoutDirectoryMarkers(objects []S3Object) []S3Object {
+	kept := make([]S3Object, 0, len(objects))
+	for _, obj := range objects {
+		if obj.Size == 0 && strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+		kept = append(kept, obj)
+	}
+	return kept
+}
+
+func objectKeys(objects []S3Object) []string {
+	keys := make([]string, len(objects))
+	for i, obj := range objects {
+		keys[i] = obj.Key
+	}
+	return keys
+}
+
+func (tm *TransferManager) TransferBatch(ctx context.Context, bucket string, filter *S3TransferFilter, epochs int, encryptionConfig *EncryptionSettings) (*TransferProgress, error) {
+	plan, err := tm.PlanTransfer(ctx, bucket, filter)
+	if err != nil {
+		return nil, err
+	}
+	return tm.RunPlan(ctx, plan, epochs, encryptionConfig)
+}
+
+// RunPlan uploads the objects of a plan made by PlanTransfer
+func (tm *TransferManager) RunPlan(ctx context.Context, plan *TransferPlan, epochs int, encryptionConfig *EncryptionSettings) (*TransferProgress, error) {
+	if len(plan.Jobs) == 0 {
+		return &TransferProgress{
+			TotalFiles:   0,
+			SkippedFiles: plan.Skipped,
+			StartTime:    time.Now(),
+		}, nil
+	}
+
+	totalSize := plan.TotalBytes
+	jobs := make([]TransferJob, len(plan.Jobs))
+	for i, job := range plan.Jobs {
+		job.Epochs = epochs
+		job.EncryptionConfig = encryptionConfig
+		jobs[i] = job
 	}
 
 	if tm.dryRun {
 		fmt.Println(color.YellowString("\n=== DRY RUN MODE ==="))
 		fmt.Printf("Would transfer %d files (%.2f MB total)\n", len(jobs), float64(totalSize)/(1024*1024))
+		if plan.Skipped > 0 {
+			fmt.Printf("Skipping %d files %s\n", plan.Skipped, tm.skipReason())
+		}
 
 		var totalCost float64
 		for _, job := range jobs {
 			cost := EstimateWalrusCost(job.Size, epochs)
 			totalCost += cost
-			fmt.Printf("  • %s (%.2f MB) → %.6f WAL\n",
+			fmt.Printf("  • %s → %s (%.2f MB) → %.6f WAL\n",
 				job.Key,
+				job.TargetName,
 				float64(job.Size)/(1024*1024),
 				cost)
 		}
 
 		fmt.Printf("\nTotal estimated cost: %.6f WAL\n", totalCost)
+		if tm.compression != "" {
+			fmt.Println("Costs assume uncompressed sizes; an object's compressed size is only known once it is read")
+		}
 		fmt.Println(color.YellowString("=== DRY RUN COMPLETE ===\n"))
 
 		return &TransferProgress{
@@ -171,23 +418,45 @@ func (tm *TransferManager) TransferBatch(ctx context.Context, bucket string, fil
 			TotalBytes:     totalSize,
 			ProcessedFiles: int32(len(jobs)),
 			ProcessedBytes: totalSize,
+			SkippedFiles:   plan.Skipped,
 			StartTime:      time.Now(),
 		}, nil
 	}
 
 	progress := &TransferProgress{
-		TotalFiles: len(jobs),
-		TotalBytes: totalSize,
-		StartTime:  time.Now(),
-		Results:    make([]TransferResult, 0, len(jobs)),
+		TotalFiles:   len(jobs),
+		TotalBytes:   totalSize,
+		SkippedFiles: plan.Skipped,
+		StartTime:    time.Now(),
+		Results:      make([]TransferResult, 0, len(jobs)),
 	}
 
-	bar := progressbar.NewOptions64(
-		totalSize,
+	bar := newTransferBar(totalSize, "Transferring files")
+
+	tm.runJobs(ctx, progress, len(jobs), func(i int) TransferResult {
+		result := tm.transferSingleFile(ctx, jobs[i], bar)
+		tm.journalResult(ctx, jobs[i], result)
+		return result
+	})
+	bar.Finish()
+
+	// Jobs still queued when ctx was cancelled are simply never started;
+	// in-flight uploads are aborted by their request context.
+	if err := ctx.Err(); err != nil {
+		return progress, fmt.Errorf("transfer interrupted: %w", err)
+	}
+
+	return progress, nil
+}
+
+// newTransferBar creates the byte progress bar shown while transferring
+func newTransferBar(total int64, description string) *progressbar.ProgressBar {
+	return progressbar.NewOptions64(
+		total,
 		progressbar.OptionEnableColorCodes(true),
 		progressbar.OptionShowBytes(true),
 		progressbar.OptionSetWidth(50),
-		progressbar.OptionSetDescription("[cyan]Transferring files[reset]"),
+		progressbar.OptionSetDescription("[cyan]"+description+"[reset]"),
 		progressbar.OptionSetTheme(progressbar.Theme{
 			Saucer:        "[green]=[reset]",
 			SaucerHead:    "[green]>[reset]",
@@ -199,30 +468,62 @@ func (tm *TransferManager) TransferBatch(ctx context.Context, bucket string, fil
 			fmt.Println()
 		}),
 	)
+}
+
+// RunTasks runs n tasks that move files without S3, such as uploads from a
+// local directory, with the same workers, progress bar and results as an S3
+// transfer. Each task is handed the shared bar, sized to total bytes, or a
+// spinner if total is not positive. A manager that only runs tasks needs
+// neither an S3 client nor an index.
+func (tm *TransferManager) RunTasks(ctx context.Context, description string, total int64, n int, run func(i int, bar *progressbar.ProgressBar) TransferResult) (*TransferProgress, error) {
+	progress := &TransferProgress{
+		TotalFiles: n,
+		TotalBytes: total,
+		StartTime:  time.Now(),
+		Results:    make([]TransferResult, 0, n),
+	}
+
+	if total <= 0 {
+		total = -1
+	}
+	bar := newTransferBar(total, description)
+	tm.runJobs(ctx, progress, n, func(i int) TransferResult {
+		return run(i, bar)
+	})
+	bar.Finish()
 
-	jobChan := make(chan TransferJob, len(jobs))
-	for _, job := range jobs {
-		jobChan <- job
+	if err := ctx.Err(); err != nil {
+		return progress, fmt.Errorf("interrupted: %w", err)
+	}
+	return progress, nil
+}
+
+// runJobs runs jobs 0..n-1 on tm.concurrency workers and collects their
+// results in progress. Jobs still queued when ctx is cancelled never start.
+func (tm *TransferManager) runJobs(ctx context.Context, progress *TransferProgress, n int, run func(i int) TransferResult) {
+	jobChan := make(chan int, n)
+	for i := 0; i < n; i++ {
+		jobChan <- i
 	}
 	close(jobChan)
 
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, tm.concurrency)
 
-	for i := 0; i < tm.concurrency; i++ {
+	for w := 0; w < tm.concurrency; w++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for job := range jobChan {
+			for i := range jobChan {
 				select {
 				case <-ctx.Done():
 					return
 				case semaphore <- struct{}{}:
-					result := tm.transferSingleFile(ctx, job, bar)
+					result := run(i)
 
 					atomic.AddInt32(&progress.ProcessedFiles, 1)
 					if result.Success {
-						atomic.AddInt64(&progress.ProcessedBytes, job.Size)
+						atomic.AddInt64(&progress.ProcessedBytes, result.Size)
 					} else {
 						atomic.AddInt32(&progress.FailedFiles, 1)
 					}
@@ -238,9 +539,6 @@ func (tm *TransferManager) TransferBatch(ctx context.Context, bucket string, fil
 	}
 
 	wg.Wait()
-	bar.Finish()
-
-	return progress, nil
 }
 
 func (tm *TransferManager) transferSingleFile(ctx context.Context, job TransferJob, bar *progressbar.ProgressBar) TransferResult {
@@ -253,88 +551,279 @@ func (tm *TransferManager) transferSingleFile(ctx context.Context, job TransferJ
 		EstimatedCost: EstimateWalrusCost(job.Size, job.Epochs),
 	}
 
-	reader, size, err := tm.s3Client.DownloadObject(ctx, job.Bucket, job.Key)
+	reader, meta, err := tm.s3Client.OpenObject(ctx, job.Bucket, job.Key, "")
 	if err != nil {
 		result.Error = fmt.Errorf("failed to download from S3: %w", err)
 		return result
 	}
-	defer reader.Close()
+	first := reader
+	defer func() {
+		if first != nil {
+			first.Close()
+		}
+	}()
+	size := meta.Size
+
+	// Tags take an extra request and permission of their own; an object whose
+	// tags cannot be read is still copied, without them
+	var tags map[string]string
+	if meta.TagCount > 0 {
+		tags, _ = tm.s3Client.ObjectTags(ctx, job.Bucket, job.Key, meta.VersionID)
+	}
 
-	var dataReader io.Reader = reader
-	var buffer bytes.Buffer
+	var key *EncryptionKey
+	if job.EncryptionConfig != nil {
+		key = job.EncryptionConfig.Key
+	}
 
-	if job.Size < 100*1024*1024 {
-		if _, err := io.Copy(&buffer, reader); err != nil {
-			result.Error = fmt.Errorf("failed to buffer S3 object: %w", err)
-			return result
+	// Stream the S3 body straight into the publisher; the progress bar is fed
+	// as bytes go out so large objects never sit in memory. A retried upload
+	// re-reads the object from S3 and takes back the progress it reported.
+	var reported int64
+	var sent *objectHasher
+	source := func() (io.ReadCloser, error) {
+		if reported > 0 {
+			bar.Add64(-reported)
+			reported = 0
+		}
+		body := first
+		first = nil
+		if body == nil {
+			var err error
+			// Read the same version again even if the object was overwritten
+			if body, _, err = tm.s3Client.OpenObject(ctx, job.Bucket, job.Key, meta.VersionID); err != nil {
+				return nil, err
+			}
 		}
-		dataReader = &buffer
+		body = &progressBody{ReadCloser: body, bar: bar, reported: &reported}
+		if tm.verify {
+			reader := newChecksumReader(body)
+			sent = reader.hasher
+			body = reader
+		}
+		return body, nil
 	}
 
-	var encryptedData []byte
-	if job.EncryptionConfig != nil && job.EncryptionConfig.Enabled {
-		data, err := io.ReadAll(dataReader)
+	// A compressed object is read from S3 into a local spool first, since a
+	// blob's size has to be known before it is uploaded. Progress and
+	// checksums cover the original object. Objects that do not shrink are
+	// stored as they are.
+	objectSize := size
+	var compression *CompressionInfo
+	var spool *os.File
+	if tm.compression != "" && Compressible(meta.ContentType) {
+		body, err := source()
+		if err != nil {
+			result.Error = fmt.Errorf("failed to download from S3: %w", err)
+			return result
+		}
+		compressed, err := CompressToFile(body, tm.compression)
+		body.Close()
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		defer func() {
+			compressed.Close()
+			os.Remove(compressed.Name())
+		}()
+		stat, err := compressed.Stat()
 		if err != nil {
-			result.Error = fmt.Errorf("failed to read data for encryption: %w", err)
+			result.Error = err
 			return result
 		}
+		if stat.Size() < size {
+			spool = compressed
+			compression = &CompressionInfo{Codec: tm.compression, Size: stat.Size()}
+			objectSize = stat.Size()
+		} else {
+			sent = nil
+		}
+	}
 
-		encryptedData = data
-		dataReader = bytes.NewReader(encryptedData)
-		job.TargetName = job.TargetName + ".sealed"
+	uploadSize := objectSize
+	if key != nil {
+		job.TargetName = job.TargetName + ".enc"
+		result.TargetName = job.TargetName
+		uploadSize = EncryptedSize(objectSize)
+	}
+	if compression != nil {
+		result.EstimatedCost = EstimateWalrusCost(uploadSize, job.Epochs)
 	}
 
-	data, err := io.ReadAll(dataReader)
-	if err != nil {
-		result.Error = fmt.Errorf("failed to read data: %w", err)
-		return result
+	open := func() (io.ReadCloser, error) {
+		var body io.ReadCloser
+		if spool != nil {
+			if _, err := spool.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			body = io.NopCloser(spool)
+		} else {
+			var err error
+			if body, err = source(); err != nil {
+				return nil, err
+			}
+		}
+		if key != nil {
+			encrypted, err := NewEncryptingReader(body, key)
+			if err != nil {
+				body.Close()
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{encrypted, body}, nil
+		}
+		return body, nil
 	}
 
-	uploadResp, err := tm.walrusClient.StoreBlob(data, job.Epochs)
-	if err != nil {
+	entry := SimpleFileEntry{
+		Size:        size,
+		ContentType: meta.ContentType,
+		Tags:        tags,
+		Source: &SourceObject{
+			Bucket:       job.Bucket,
+			Key:          job.Key,
+			VersionID:    meta.VersionID,
+			ETag:         job.ETag,
+			LastModified: job.LastModified,
+			CacheControl: meta.CacheControl,
+			Metadata:     meta.Metadata,
+		},
+	}
+	if key != nil {
+		entry.Encryption = key.Info()
+	}
+	entry.Compression = compression
+	storeOpts := StoreOptions{
+		Epochs:    job.Epochs,
+		Deletable: tm.deletable,
+	}
+	if tm.attributes {
+		storeOpts.Attributes = sourceAttributes(job.TargetName, entry)
+	}
+
+	// Objects too large for one blob are stored in parts, each read from S3
+	// with a ranged request for the same version. An error with a response
+	// means the blob is stored and only its attributes are missing; like a
+	// plain upload, that is only a warning.
+	var uploadResp *StoreResponse
+	var manifest *ChunkManifest
+	if tm.walrusClient.NeedsChunking(uploadSize) {
+		upload := ChunkedUpload{
+			Size: objectSize,
+			Open: func(offset, length int64) (io.ReadCloser, error) {
+				return tm.s3Client.OpenObjectRange(ctx, job.Bucket, job.Key, meta.VersionID, offset, length)
+			},
+			Encrypt:  key,
+			Progress: bar,
+		}
+		if spool != nil {
+			// The bar and the checksums already covered the object while it
+			// was compressed
+			upload.Open = func(offset, length int64) (io.ReadCloser, error) {
+				return io.NopCloser(io.NewSectionReader(spool, offset, length)), nil
+			}
+			upload.Progress = nil
+		} else if tm.verify {
+			// Parts are read in order, so together they hash the whole object
+			sent = newObjectHasher()
+			openRange := upload.Open
+			upload.Open = func(offset, length int64) (io.ReadCloser, error) {
+				body, err := openRange(offset, length)
+				if err != nil {
+					return nil, err
+				}
+				return &checksumReader{ReadCloser: body, hasher: sent, offset: offset}, nil
+			}
+		}
+		uploadResp, manifest, err = tm.walrusClient.StoreChunkedContext(ctx, upload, storeOpts)
+	} else {
+		uploadResp, err = tm.walrusClient.StoreBlobWithOptionsContext(ctx, open, uploadSize, storeOpts)
+	}
+	if err != nil && uploadResp == nil {
 		result.Error = fmt.Errorf("failed to upload to Walrus: %w", err)
 		return result
 	}
+	attrErr := err
 
 	result.BlobID = uploadResp.BlobID
-	result.Success = true
 	result.ExpiryEpoch = uploadResp.EndEpoch
 	result.RegisteredEpoch = uploadResp.RegisteredEpoch
 	result.SuiObjectID = uploadResp.SuiObjectID
 
-	if tm.simpleFS != nil {
-		tm.simpleFS.indexMu.Lock()
-		expiryEpoch := 0
-		if uploadResp.EndEpoch != nil {
-			expiryEpoch = int(*uploadResp.EndEpoch)
+	// A blob that fails verification stays out of the index so the next
+	// sync or --resume uploads the object again
+	if sent != nil {
+		sums := sent.Checksums()
+		result.Checksums = &sums
+		codec := ""
+		if compression != nil {
+			codec = compression.Codec
 		}
-		tm.simpleFS.index.Files[job.TargetName] = &SimpleFileEntry{
-			BlobID:      uploadResp.BlobID,
-			Size:        size,
-			ModTime:     time.Now(),
-			ExpiryEpoch: expiryEpoch,
+		if manifest == nil {
+			err = tm.verifyUpload(ctx, job.ETag, uploadResp.BlobID, sent, key, codec)
+		} else {
+			err = tm.verifyChunked(ctx, job.ETag, uploadResp.BlobID, sent, key, codec)
 		}
-		tm.simpleFS.indexMu.Unlock()
-		tm.simpleFS.SaveIndex()
+		if err != nil {
+			result.Error = fmt.Errorf("verification failed: %w", err)
+			return result
+		}
+		result.Verified = true
+		entry.Checksums = &sums
 	}
+	if manifest != nil {
+		entry.Parts = manifest.Parts
+	}
+	result.Success = true
+	result.Error = attrErr
 
-	bar.Add64(job.Size)
+	if tm.simpleFS != nil {
+		tm.simpleFS.recordUpload(job.TargetName, uploadResp, entry)
+		tm.simpleFS.SaveIndex()
+	}
 
 	return result
 }
 
+// progressBody feeds bytes read to a progress bar and remembers how many it
+// reported so a retried upload can take them back
+type progressBody struct {
+	io.ReadCloser
+	bar      *progressbar.ProgressBar
+	reported *int64
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.bar.Add64(int64(n))
+		*b.reported += int64(n)
+	}
+	return n, err
+}
+
 func (tm *TransferManager) TransferSingle(ctx context.Context, bucket, key string, epochs int) (*TransferResult, error) {
 	obj, err := tm.s3Client.GetObjectMetadata(ctx, bucket, key)
 	if err != nil {
 		return nil, err
 	}
 
+	targetName := tm.keyMapping.TargetName(key)
+	if targetName == "" {
+		return nil, &KeyMappingError{Empty: []string{key}}
+	}
+
 	job := TransferJob{
-		Bucket:     bucket,
-		Key:        key,
-		Size:       obj.Size,
-		TargetName: path.Base(key),
-		Epochs:     epochs,
+		Bucket:       bucket,
+		Key:          key,
+		ETag:         obj.ETag,
+		LastModified: obj.LastModified,
+		Size:         obj.Size,
+		TargetName:   targetName,
+		Epochs:       epochs,
 	}
 
 	if tm.dryRun {
@@ -363,12 +852,14 @@ func (p *TransferProgress) GetSummary() string {
 		"  Total Files: %d\n"+
 		"  Successful: %d\n"+
 		"  Failed: %d\n"+
+		"  Skipped: %d\n"+
 		"  Total Size: %.2f MB\n"+
 		"  Duration: %s\n"+
 		"  Average Speed: %.2f MB/s",
 		p.TotalFiles,
 		successCount,
 		p.FailedFiles,
+		p.SkippedFiles,
 		float64(p.ProcessedBytes)/(1024*1024),
 		duration.Round(time.Second),
 		float64(p.ProcessedBytes)/(1024*1024)/duration.Seconds(),
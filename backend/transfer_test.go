@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSourceChanged(t *testing.T) {
+	then := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := then.Add(time.Hour)
+	gone := then.Add(2 * time.Hour)
+
+	entry := func(src *SourceObject) SimpleFileEntry {
+		return SimpleFileEntry{Size: 100, Source: src}
+	}
+	tests := []struct {
+		name  string
+		entry SimpleFileEntry
+		obj   S3Object
+		want  bool
+	}{
+		{"no source recorded", entry(nil), S3Object{Size: 100, ETag: `"a"`}, true},
+		{"deleted upstream", entry(&SourceObject{ETag: `"a"`, DeletedAt: &gone}), S3Object{Size: 100, ETag: `"a"`}, true},
+		{"size differs", entry(&SourceObject{ETag: `"a"`}), S3Object{Size: 101, ETag: `"a"`}, true},
+		{"same ETag", entry(&SourceObject{ETag: `"a"`, LastModified: then}), S3Object{Size: 100, ETag: `"a"`, LastModified: later}, false},
+		{"different ETag", entry(&SourceObject{ETag: `"a"`, LastModified: later}), S3Object{Size: 100, ETag: `"b"`, LastModified: then}, true},
+		{"no ETag, same time", entry(&SourceObject{LastModified: then}), S3Object{Size: 100, ETag: `"a"`, LastModified: then}, false},
+		{"no ETag, newer", entry(&SourceObject{LastModified: then}), S3Object{Size: 100, LastModified: later}, true},
+		{"no ETag, older", entry(&SourceObject{LastModified: later}), S3Object{Size: 100, LastModified: then}, false},
+	}
+	for _, tt := range tests {
+		if got := sourceChanged(tt.entry, tt.obj); got != tt.want {
+			t.Errorf("%s: sourceChanged = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSkipReasonFollowsSelection(t *testing.T) {
+	tm := NewTransferManager(nil, nil, nil, 1)
+	tm.SetSelection(SelectChanged)
+	if got := tm.skipReason(); got != "unchanged since the last sync" {
+		t.Errorf("SelectChanged: %q", got)
+	}
+	tm.SetSelection(SelectPending)
+	if got := tm.skipReason(); got != "according to the transfer journal" {
+		t.Errorf("SelectPending: %q", got)
+	}
+}
@@ -0,0 +1,145 @@
+package backend
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Checksums are hex-encoded digests of an object's content
+type Checksums struct {
+	MD5    string `json:"md5"`
+	SHA256 string `json:"sha256"`
+}
+
+// objectHasher hashes an object as it is read in order, in one stream or
+// over several ranges such as the parts of a chunked upload. A range read
+// again, as on a retry, only adds the bytes past those already hashed.
+type objectHasher struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+	n      int64 // Bytes hashed so far
+	gap    bool  // A range started past n, so the digests miss some bytes
+}
+
+func newObjectHasher() *objectHasher {
+	return &objectHasher{md5: md5.New(), sha256: sha256.New()}
+}
+
+// add hashes p, which was read at offset in the object
+func (h *objectHasher) add(offset int64, p []byte) {
+	if offset > h.n {
+		h.gap = true
+		return
+	}
+	if skip := h.n - offset; skip < int64(len(p)) {
+		p = p[skip:]
+		h.md5.Write(p)
+		h.sha256.Write(p)
+		h.n += int64(len(p))
+	}
+}
+
+// Checksums returns the digests of what has been hashed so far
+func (h *objectHasher) Checksums() Checksums {
+	return Checksums{
+		MD5:    hex.EncodeToString(h.md5.Sum(nil)),
+		SHA256: hex.EncodeToString(h.sha256.Sum(nil)),
+	}
+}
+
+// checksumReader feeds what is read through it to an objectHasher, as the
+// range of the object starting at offset
+type checksumReader struct {
+	io.ReadCloser
+	hasher *objectHasher
+	offset int64
+}
+
+// newChecksumReader hashes r as a whole object of its own
+func newChecksumReader(r io.ReadCloser) *checksumReader {
+	return &checksumReader{ReadCloser: r, hasher: newObjectHasher()}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.hasher.add(r.offset, p[:n])
+		r.offset += int64(n)
+	}
+	return n, err
+}
+
+// etagMD5 returns the content MD5 an S3 ETag stands for, or "" for ETags that
+// are not one, such as those of multipart uploads ("<hash>-<parts>")
+func etagMD5(etag string) string {
+	etag = strings.ToLower(strings.Trim(etag, `"`))
+	if len(etag) != md5.Size*2 {
+		return ""
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return ""
+	}
+	return etag
+}
+
+// verifyUpload checks a finished upload end to end: the bytes sent must hash
+// to the source object's ETag when that is a plain MD5, and the blob read back
+// from Walrus must have the same length and SHA-256 as the bytes sent. Blobs
+// encrypted with key or compressed with codec are decoded on the way back and
+// compared as the original object.
+func (tm *TransferManager) verifyUpload(ctx context.Context, etag, blobID string, sent *objectHasher, key *EncryptionKey, codec string) error {
+	return verifyReadBack(etag, sent, key, codec, func(w io.Writer) error {
+		_, err := tm.walrusClient.RetrieveBlobToContext(ctx, blobID, w)
+		return err
+	})
+}
+
+// verifyChunked checks a chunked upload the same way, reading it back
+// through its manifest. Every part must also have the size and SHA-256 it
+// was sent with.
+func (tm *TransferManager) verifyChunked(ctx context.Context, etag, manifestID string, sent *objectHasher, key *EncryptionKey, codec string) error {
+	// Parts are decrypted one by one, but the object was compressed whole
+	return verifyReadBack(etag, sent, nil, codec, func(w io.Writer) error {
+		_, err := tm.walrusClient.RetrieveChunkedToContext(ctx, manifestID, w, key)
+		return err
+	})
+}
+
+// verifyReadBack compares what read writes, once decoded, with the source
+// bytes sent
+func verifyReadBack(etag string, sent *objectHasher, key *EncryptionKey, codec string, read func(w io.Writer) error) error {
+	if sent.gap {
+		return fmt.Errorf("the source was not read in order, so it could not be hashed")
+	}
+	sums := sent.Checksums()
+	if want := etagMD5(etag); want != "" && want != sums.MD5 {
+		return fmt.Errorf("MD5 of the source stream %s does not match its ETag %s", sums.MD5, want)
+	}
+
+	readBack := sha256.New()
+	w, finish, err := decodingWriter(readBack, key, codec)
+	if err != nil {
+		return err
+	}
+	err = read(w)
+	n, finishErr := finish()
+	if err != nil {
+		return fmt.Errorf("reading blob back: %w", err)
+	}
+	if finishErr != nil {
+		return fmt.Errorf("decoding blob: %w", finishErr)
+	}
+	if n != sent.n {
+		return fmt.Errorf("blob is %d bytes, source was %d", n, sent.n)
+	}
+	if got := hex.EncodeToString(readBack.Sum(nil)); got != sums.SHA256 {
+		return fmt.Errorf("SHA-256 of the stored blob %s does not match the source %s", got, sums.SHA256)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestObjectHasherRanges(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	sum := sha256.Sum256(data)
+
+	h := newObjectHasher()
+	h.add(0, data[:10])
+	h.add(5, data[5:20]) // A retried range overlapping what was hashed
+	h.add(20, data[20:])
+	h.add(30, data[30:]) // Read again after the end
+	if h.gap || h.n != int64(len(data)) {
+		t.Fatalf("hashed %d bytes with gap %t", h.n, h.gap)
+	}
+	if got := h.Checksums().SHA256; got != hex.EncodeToString(sum[:]) {
+		t.Fatalf("SHA-256 = %s, want the digest of the whole input", got)
+	}
+
+	h = newObjectHasher()
+	h.add(0, data[:10])
+	h.add(15, data[15:])
+	if !h.gap {
+		t.Fatal("a skipped range was not noticed")
+	}
+	if err := verifyReadBack("", h, nil, "", func(w io.Writer) error { return nil }); err == nil {
+		t.Fatal("verified an object hashed with a gap")
+	}
+}
+
+func TestVerifyReadBackComparesContent(t *testing.T) {
+	data := []byte("object content")
+	sent := newObjectHasher()
+	sent.add(0, data)
+	md5sum := md5.Sum(data)
+	etag := `"` + hex.EncodeToString(md5sum[:]) + `"`
+
+	read := func(content string) func(io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := io.WriteString(w, content)
+			return err
+		}
+	}
+	if err := verifyReadBack(etag, sent, nil, "", read(string(data))); err != nil {
+		t.Fatalf("matching content failed: %v", err)
+	}
+	if err := verifyReadBack(etag, sent, nil, "", read("object c0ntent")); err == nil || !strings.Contains(err.Error(), "SHA-256") {
+		t.Fatalf("changed content gave %v, want a SHA-256 mismatch", err)
+	}
+	if err := verifyReadBack(etag, sent, nil, "", read("object")); err == nil {
+		t.Fatal("short content verified")
+	}
+	if err := verifyReadBack(`"00000000000000000000000000000000"`, sent, nil, "", read(string(data))); err == nil || !strings.Contains(err.Error(), "ETag") {
+		t.Fatalf("wrong ETag gave %v, want an ETag mismatch", err)
+	}
+}
+
+func TestTransferVerifiesChunkedObject(t *testing.T) {
+	s3fake, s3Client := newFakeS3(t)
+	fw, walrus := newFakeWalrus(t)
+	walrus.MaxBlobSize = 64 << 10
+
+	data := make([]byte, 200<<10)
+	rand.New(rand.NewSource(4)).Read(data)
+	s3fake.put("bucket", "big.bin", data, "application/octet-stream")
+
+	tm := NewTransferManager(s3Client, walrus, nil, 1)
+	tm.SetVerify(true)
+	result, err := tm.TransferSingle(context.Background(), "bucket", "big.bin", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || !result.Verified {
+		t.Fatalf("transfer was not verified: %v", result.Error)
+	}
+	sha := sha256.Sum256(data)
+	md5sum := md5.Sum(data)
+	if result.Checksums == nil || result.Checksums.SHA256 != hex.EncodeToString(sha[:]) || result.Checksums.MD5 != hex.EncodeToString(md5sum[:]) {
+		t.Fatalf("Checksums = %+v, want the digests of the object", result.Checksums)
+	}
+
+	// A part that reads back different fails the transfer
+	partSize := walrus.partSize(false)
+	fw.tamper[fakeBlobID(data[partSize:2*partSize])] = true
+	result, err = tm.TransferSingle(context.Background(), "bucket", "big.bin", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || result.Verified || result.Error == nil || !strings.Contains(result.Error.Error(), "verification failed") {
+		t.Fatalf("tampered part gave success %t, verified %t, error %v", result.Success, result.Verified, result.Error)
+	}
+	if !bytes.Equal(s3fake.object("bucket", "big.bin").data, data) {
+		t.Fatal("source object changed")
+	}
+}
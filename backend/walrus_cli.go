@@ -0,0 +1,247 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultWalrusBinary is the name of the official Walrus client looked up on PATH
+const DefaultWalrusBinary = "walrus"
+
+// WalrusCLI runs the official walrus binary for operations that need a Sui
+// wallet to sign transactions, which the HTTP publisher API cannot do for us
+type WalrusCLI struct {
+	Binary string // Path to the walrus binary, DefaultWalrusBinary if empty
+	Config string // Optional walrus client config (--config)
+	Wallet string // Optional Sui wallet config (--wallet)
+}
+
+// StoreOptions controls how a blob is stored
+type StoreOptions struct {
+	Epochs         int
+	Deletable      bool              // Create a blob the owner can delete before it expires
+	Attributes     map[string]string // Blob attributes attached after storing
+	UploadRelayURL string            // Upload through this relay instead of directly to storage nodes
+}
+
+// NewWalrusCLI creates a wrapper around the walrus binary at path
+func NewWalrusCLI(path string) *WalrusCLI {
+	return &WalrusCLI{Binary: path}
+}
+
+// Store uploads the file at path and returns the registration result
+func (w *WalrusCLI) Store(ctx context.Context, path string, opts StoreOptions) (*StoreResponse, error) {
+	args := []string{"store", path, "--epochs", strconv.Itoa(opts.Epochs)}
+	if opts.Deletable {
+		args = append(args, "--deletable")
+	}
+	if opts.UploadRelayURL != "" {
+		args = append(args, "--upload-relay", opts.UploadRelayURL)
+	}
+
+	out, err := w.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The binary reports one result per stored file
+	var results []struct {
+		BlobStoreResult json.RawMessage `json:"blobStoreResult"`
+		Path            string          `json:"path"`
+	}
+	if err := json.Unmarshal(out, &results); err != nil {
+		return nil, fmt.Errorf("decoding walrus store output: %w (output: %s)", err, string(out))
+	}
+	if len(results) == 0 || len(results[0].BlobStoreResult) == 0 {
+		return nil, fmt.Errorf("walrus store returned no result (output: %s)", string(out))
+	}
+
+	return decodeStoreResponse(results[0].BlobStoreResult, 0)
+}
+
+// SetBlobAttributes attaches key/value attributes to the blob object objectID,
+// replacing existing values for the same keys
+func (w *WalrusCLI) SetBlobAttributes(ctx context.Context, objectID string, attrs map[string]string) error {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := []string{"set-blob-attribute", objectID}
+	for _, key := range keys {
+		args = append(args, "--attr", key, attrs[key])
+	}
+
+	_, err := w.run(ctx, args...)
+	return err
+}
+
+// Delete removes a deletable blob owned by the wallet. objectID selects one
+// blob object; otherwise every owned object for blobID is deleted.
+func (w *WalrusCLI) Delete(ctx context.Context, blobID, objectID string) error {
+	args := []string{"delete", "--yes"}
+	if objectID != "" {
+		args = append(args, "--object-ids", objectID)
+	} else {
+		args = append(args, "--blob-id", blobID)
+	}
+
+	_, err := w.run(ctx, args...)
+	return err
+}
+
+// Extend adds epochs to the storage period of the blob object objectID
+func (w *WalrusCLI) Extend(ctx context.Context, objectID string, epochs int) error {
+	_, err := w.run(ctx, "extend", "--blob-obj-id", objectID, "--epochs-extended", strconv.Itoa(epochs))
+	return err
+}
+
+// OwnedBlob is a blob object held by the wallet
+type OwnedBlob struct {
+	ObjectID  string
+	BlobID    string
+	Size      int64
+	EndEpoch  int
+	Deletable bool
+}
+
+// ListBlobs returns the blob objects owned by the wallet, including expired ones
+func (w *WalrusCLI) ListBlobs(ctx context.Context) ([]OwnedBlob, error) {
+	out, err := w.run(ctx, "list-blobs", "--include-expired")
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []struct {
+		ID        string               `json:"id"`
+		BlobID    string               `json:"blobId"`
+		Size      int64                `json:"size"`
+		Storage   walrusStoragePayload `json:"storage"`
+		Deletable bool                 `json:"deletable"`
+	}
+	if err := json.Unmarshal(out, &objects); err != nil {
+		return nil, fmt.Errorf("decoding walrus list-blobs output: %w (output: %s)", err, string(out))
+	}
+
+	blobs := make([]OwnedBlob, 0, len(objects))
+	for _, obj := range objects {
+		blobs = append(blobs, OwnedBlob{
+			ObjectID:  obj.ID,
+			BlobID:    obj.BlobID,
+			Size:      obj.Size,
+			EndEpoch:  obj.Storage.endEpoch(),
+			Deletable: obj.Deletable,
+		})
+	}
+	return blobs, nil
+}
+
+// CurrentEpoch returns the current Walrus epoch
+func (w *WalrusCLI) CurrentEpoch(ctx context.Context) (int, error) {
+	out, err := w.run(ctx, "info")
+	if err != nil {
+		return 0, err
+	}
+
+	var info struct {
+		EpochInfo *struct {
+			CurrentEpoch int `json:"currentEpoch"`
+		} `json:"epochInfo"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return 0, fmt.Errorf("decoding walrus info output: %w", err)
+	}
+	if info.EpochInfo == nil {
+		return 0, fmt.Errorf("walrus info did not report the current epoch (output: %s)", string(out))
+	}
+	return info.EpochInfo.CurrentEpoch, nil
+}
+
+// QuiltFile is a file on disk to be stored as a quilt patch
+type QuiltFile struct {
+	Path       string            `json:"path"`
+	Identifier string            `json:"identifier"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// StoreQuilt stores files as a single quilt and returns the patch IDs
+func (w *WalrusCLI) StoreQuilt(ctx context.Context, files []QuiltFile, opts StoreOptions) (*QuiltStoreResponse, error) {
+	args := []string{"store-quilt", "--epochs", strconv.Itoa(opts.Epochs)}
+	if opts.Deletable {
+		args = append(args, "--deletable")
+	}
+	for _, file := range files {
+		spec, err := json.Marshal(file)
+		if err != nil {
+			return nil, fmt.Errorf("encoding quilt file %s: %w", file.Identifier, err)
+		}
+		args = append(args, "--blobs", string(spec))
+	}
+	if opts.UploadRelayURL != "" {
+		args = append(args, "--upload-relay", opts.UploadRelayURL)
+	}
+
+	out, err := w.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeQuiltStoreResponse(out)
+}
+
+// binary returns the path or name of the walrus binary to run
+func (w *WalrusCLI) binary() string {
+	if w.Binary == "" {
+		return DefaultWalrusBinary
+	}
+	return w.Binary
+}
+
+// Check reports an error if the walrus binary cannot be found
+func (w *WalrusCLI) Check() error {
+	if _, err := exec.LookPath(w.binary()); err != nil {
+		return fmt.Errorf("walrus binary %q not found; install it from https://docs.wal.app or set walrus.walrus_binary in the config: %w", w.binary(), err)
+	}
+	return nil
+}
+
+// run executes the binary with JSON output and returns stdout. On failure the
+// error carries the binary's stderr, which is where it explains what went wrong.
+func (w *WalrusCLI) run(ctx context.Context, args ...string) ([]byte, error) {
+	if err := w.Check(); err != nil {
+		return nil, err
+	}
+	binary := w.binary()
+
+	args = append(args, "--json")
+	if w.Config != "" {
+		args = append(args, "--config", w.Config)
+	}
+	if w.Wallet != "" {
+		args = append(args, "--wallet", w.Wallet)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		return nil, fmt.Errorf("walrus %s failed: %w: %s", args[0], err, msg)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/justmert/walrus-cli/backend"
 )
@@ -89,21 +88,10 @@ func handleListUserBlobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determine Sui RPC URL based on aggregator URL (heuristic)
-	suiRPCURL := "https://fullnode.testnet.sui.io:443"
-	if strings.Contains(config.Walrus.AggregatorURL, "mainnet") {
-		suiRPCURL = "https://fullnode.mainnet.sui.io:443"
-	}
-
-	// Create blob indexer service
-	indexer := backend.NewBlobIndexerService(
-		suiRPCURL,
-		config.Walrus.AggregatorURL,
-		config.Walrus.PublisherURL,
-	)
+	indexer := newBlobIndexerService(config)
 
 	// Fetch user blobs
-	blobs, err := indexer.GetUserBlobs(req.UserAddress)
+	blobs, err := indexer.GetUserBlobsContext(r.Context(), req.UserAddress)
 	if err != nil {
 		response := ListBlobsResponse{
 			Success: false,
@@ -158,21 +146,10 @@ func handleSearchUserBlobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determine Sui RPC URL based on aggregator URL (heuristic)
-	suiRPCURL := "https://fullnode.testnet.sui.io:443"
-	if strings.Contains(config.Walrus.AggregatorURL, "mainnet") {
-		suiRPCURL = "https://fullnode.mainnet.sui.io:443"
-	}
-
-	// Create blob indexer service
-	indexer := backend.NewBlobIndexerService(
-		suiRPCURL,
-		config.Walrus.AggregatorURL,
-		config.Walrus.PublisherURL,
-	)
+	indexer := newBlobIndexerService(config)
 
 	// Search blobs
-	blobs, err := indexer.SearchBlobs(req.UserAddress, req.Query)
+	blobs, err := indexer.SearchBlobsContext(r.Context(), req.UserAddress, req.Query)
 	if err != nil {
 		response := ListBlobsResponse{
 			Success: false,
@@ -227,21 +204,10 @@ func handleGetBlobDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determine Sui RPC URL based on aggregator URL (heuristic)
-	suiRPCURL := "https://fullnode.testnet.sui.io:443"
-	if strings.Contains(config.Walrus.AggregatorURL, "mainnet") {
-		suiRPCURL = "https://fullnode.mainnet.sui.io:443"
-	}
-
-	// Create blob indexer service
-	indexer := backend.NewBlobIndexerService(
-		suiRPCURL,
-		config.Walrus.AggregatorURL,
-		config.Walrus.PublisherURL,
-	)
+	indexer := newBlobIndexerService(config)
 
 	// Get blob details
-	blob, err := indexer.GetBlobDetails(req.BlobID)
+	blob, err := indexer.GetBlobDetailsContext(r.Context(), req.BlobID)
 	if err != nil {
 		response := ListBlobsResponse{
 			Success: false,
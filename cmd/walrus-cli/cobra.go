@@ -33,8 +33,37 @@ var (
 	dryRunFlag bool
 	outputFlag string
 	sizeFlag   int64
+	resumeFlag bool
+	rangeFlag  string
+	quiltFlag  bool
+	dedupFlag  bool
+
+	compressFlag string
+
+	recursiveFlag bool
+	includeFlags  []string
+	excludeFlags  []string
+	parallelFlag  int
+	deleteFlag    bool
+
+	decryptFlag bool
+	keyFileFlag string
+
+	tagFlags        []string
+	contentTypeFlag string
+	deletableFlag   bool
+	forceFlag       bool
+	withinFlag      int
+
+	timeoutFlag      time.Duration
+	retriesFlag      int
+	retryBackoffFlag time.Duration
+	uploadRelayFlag  string
 )
 
+// relayFromConfig is the value --upload-relay takes when given without a URL
+const relayFromConfig = "config"
+
 func createRootCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "walrus-cli",
@@ -49,6 +78,11 @@ func createRootCmd() *cobra.Command {
 `) + color.HiBlueString(`            Decentralized Storage CLI`),
 		SilenceUsage: true,
 	}
+	rootCmd.PersistentFlags().DurationVar(&timeoutFlag, "timeout", 0, "Deadline for each network call, e.g. 30s or 10m (0 = no limit)")
+	rootCmd.PersistentFlags().IntVar(&retriesFlag, "retries", 0, "Maximum attempts per network call (default from config)")
+	rootCmd.PersistentFlags().DurationVar(&retryBackoffFlag, "retry-backoff", 0, "Initial delay between retries (default from config)")
+	rootCmd.PersistentFlags().StringVar(&uploadRelayFlag, "upload-relay", "", "Store through an upload relay (the configured one, or --upload-relay=URL)")
+	rootCmd.PersistentFlags().Lookup("upload-relay").NoOptDefVal = relayFromConfig
 
 	// Setup command
 	setupCmd := &cobra.Command{
@@ -64,33 +98,35 @@ func createRootCmd() *cobra.Command {
 	statusCmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show configuration status",
-		Long:  "Display current configuration, wallet status, and storage statistics",
+		Long:  "Display current configuration, endpoint health, wallet status, and storage statistics",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			config, err := backend.LoadConfig("")
 			if err != nil {
 				return fmt.Errorf("loading config: %w", err)
 			}
-			ModernStatusDisplay(config)
+			ModernStatusDisplay(cmd.Context(), config)
 			return nil
 		},
 	}
 
 	// Upload command
 	uploadCmd := &cobra.Command{
-		Use:   "upload <file>",
+		Use:   "upload <file> | --quilt <files...> | -r <directory>",
 		Short: "Upload a file to Walrus",
-		Long:  "Upload a file to Walrus decentralized storage with cost estimation and progress tracking",
-		Args:  cobra.ExactArgs(1),
+		Long:  "Upload a file to Walrus decentralized storage with cost estimation and progress tracking.\nWith --quilt, many small files are stored together in one blob to share its overhead.\nWith --dedup, chunks already uploaded from earlier versions of a file are referenced instead of stored again.\nWith --compress, the file is compressed before it is stored and decompressed again on download.\nWith -r, every file under a directory is uploaded and indexed under its path.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if quiltFlag {
+				return cobra.MinimumNArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			config, err := backend.LoadConfig("")
 			if err != nil {
 				return fmt.Errorf("loading config: %w", err)
 			}
 
-			client := backend.NewWalrusClient(
-				config.Walrus.AggregatorURL,
-				config.Walrus.PublisherURL,
-			)
+			client := newWalrusClient(config)
 
 			index := loadIndex()
 			epochs := epochsFlag
@@ -98,18 +134,67 @@ func createRootCmd() *cobra.Command {
 				epochs = config.Walrus.Epochs
 			}
 
-			handleUpload(client, index, args[0], epochs, dryRunFlag)
+			tags, err := backend.ParseTags(tagFlags)
+			if err != nil {
+				return err
+			}
+			codec, err := backend.ParseCompression(compressFlag)
+			if err != nil {
+				return err
+			}
+			opts := uploadOptions{
+				Epochs:      epochs,
+				DryRun:      dryRunFlag,
+				Deletable:   deletableFlag,
+				ContentType: contentTypeFlag,
+				Tags:        tags,
+				Compress:    codec,
+				Include:     includeFlags,
+				Exclude:     excludeFlags,
+				Parallel:    parallelFlag,
+			}
+
+			switch {
+			case quiltFlag && dedupFlag:
+				return fmt.Errorf("--quilt and --dedup cannot be combined")
+			case codec != "" && (quiltFlag || dedupFlag):
+				return fmt.Errorf("--compress cannot be combined with --quilt or --dedup")
+			case recursiveFlag && (quiltFlag || dedupFlag):
+				return fmt.Errorf("-r cannot be combined with --quilt or --dedup")
+			case deletableFlag && !client.OwnsStoredBlobs():
+				return errDeletableNotOwned
+			case !recursiveFlag && (len(includeFlags) > 0 || len(excludeFlags) > 0):
+				return fmt.Errorf("--include and --exclude are only used with -r")
+			case recursiveFlag:
+				handleRecursiveUpload(cmd.Context(), client, index, args[0], opts)
+			case quiltFlag:
+				handleQuiltUpload(cmd.Context(), client, index, args, opts)
+			case dedupFlag:
+				handleDedupUpload(cmd.Context(), client, index, args[0], opts)
+			default:
+				handleUpload(cmd.Context(), client, index, args[0], opts)
+			}
 			return nil
 		},
 	}
+	uploadCmd.Flags().BoolVar(&quiltFlag, "quilt", false, "Store all given files together as one quilt")
+	uploadCmd.Flags().BoolVar(&dedupFlag, "dedup", false, "Split the file into content-defined chunks and skip chunks already stored")
+	uploadCmd.Flags().StringVar(&compressFlag, "compress", "", "Compress the file before storing it (zstd or gzip)")
+	uploadCmd.Flags().BoolVarP(&recursiveFlag, "recursive", "r", false, "Upload a directory and every file below it")
+	uploadCmd.Flags().StringSliceVar(&includeFlags, "include", nil, "With -r, only upload paths matching these patterns (e.g., *.pdf)")
+	uploadCmd.Flags().StringSliceVar(&excludeFlags, "exclude", nil, "With -r, skip paths matching these patterns (e.g., tmp/*)")
+	uploadCmd.Flags().IntVar(&parallelFlag, "parallel", 3, "With -r, number of files uploaded at once (1-10)")
+	uploadCmd.Flags().BoolVar(&deletableFlag, "deletable", false, "Store a blob that can be deleted before it expires")
+	uploadCmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Attach a key=value tag, stored as a blob attribute (repeatable)")
+	uploadCmd.Flags().StringVar(&contentTypeFlag, "content-type", "", "Content type stored with the blob (detected when omitted)")
 	uploadCmd.Flags().IntVarP(&epochsFlag, "epochs", "e", 0, "Number of epochs to store (default from config)")
 	uploadCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Estimate cost without uploading")
 
 	// Download command
 	downloadCmd := &cobra.Command{
-		Use:   "download <filename>",
+		Use:   "download <filename> | -r <prefix>",
 		Short: "Download a file from Walrus",
-		Long:  "Download a previously uploaded file from Walrus storage",
+		Long:  "Download a previously uploaded file from Walrus storage.\nWith -r, every file whose name starts with the prefix is downloaded, rebuilding the directory tree below it with the original modification times.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			config, err := backend.LoadConfig("")
@@ -117,17 +202,154 @@ func createRootCmd() *cobra.Command {
 				return fmt.Errorf("loading config: %w", err)
 			}
 
-			client := backend.NewWalrusClient(
-				config.Walrus.AggregatorURL,
-				config.Walrus.PublisherURL,
-			)
+			client := newWalrusClient(config)
+
+			opts := backend.DownloadOptions{Resume: resumeFlag}
+			if rangeFlag != "" {
+				rng, err := backend.ParseByteRange(rangeFlag)
+				if err != nil {
+					return err
+				}
+				opts.Range = rng
+			}
+			if recursiveFlag {
+				if opts.Range != nil || decryptFlag {
+					return fmt.Errorf("--range and --decrypt cannot be combined with -r")
+				}
+				handleRecursiveDownload(cmd.Context(), client, loadIndex(), args[0], outputFlag, opts, parallelFlag)
+				return nil
+			}
+			if opts.Decrypt, err = decryptKey(decryptFlag, keyFileFlag, opts); err != nil {
+				return err
+			}
 
 			index := loadIndex()
-			handleDownload(client, index, args[0], outputFlag)
+			handleDownload(cmd.Context(), client, index, args[0], outputFlag, opts)
 			return nil
 		},
 	}
-	downloadCmd.Flags().StringVarP(&outputFlag, "output", "o", "", "Output file path")
+	downloadCmd.Flags().StringVarP(&outputFlag, "output", "o", "", "Output file path (with -r, the directory to rebuild the tree in)")
+	downloadCmd.Flags().BoolVarP(&recursiveFlag, "recursive", "r", false, "Download every file whose name starts with the given prefix")
+	downloadCmd.Flags().IntVar(&parallelFlag, "parallel", 3, "With -r, number of files downloaded at once (1-10)")
+	downloadCmd.Flags().BoolVar(&resumeFlag, "resume", false, "Resume an interrupted download from its .part file")
+	downloadCmd.Flags().StringVar(&rangeFlag, "range", "", "Download only a byte range (start-end or start-)")
+	downloadCmd.Flags().BoolVar(&decryptFlag, "decrypt", false, "Decrypt a blob stored by s3 transfer --encrypt")
+	downloadCmd.Flags().StringVar(&keyFileFlag, "key-file", "", "Key file for --decrypt (default: ask for the passphrase or read $WALRUS_PASSPHRASE)")
+
+	// Sync command
+	syncCmd := &cobra.Command{
+		Use:   "sync <local-dir> <remote-prefix>",
+		Short: "Upload what changed in a directory",
+		Long:  "Make the files indexed under a prefix match a local directory, rsync-style.\nNew files and files whose size, modification time and content changed are uploaded; unchanged files are skipped.\nWith --delete, indexed files that are gone from the directory are deleted too. Replaced versions stay stored until they expire.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := backend.LoadConfig("")
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+
+			client := newWalrusClient(config)
+			epochs := epochsFlag
+			if epochs == 0 {
+				epochs = config.Walrus.Epochs
+			}
+			codec, err := backend.ParseCompression(compressFlag)
+			if err != nil {
+				return err
+			}
+			opts := uploadOptions{
+				Epochs:    epochs,
+				DryRun:    dryRunFlag,
+				Deletable: deletableFlag,
+				Compress:  codec,
+				Include:   includeFlags,
+				Exclude:   excludeFlags,
+				Parallel:  parallelFlag,
+			}
+			handleSync(cmd.Context(), client, loadIndex(), args[0], args[1], opts, deleteFlag, forceFlag)
+			return nil
+		},
+	}
+	syncCmd.Flags().BoolVar(&deleteFlag, "delete", false, "Delete indexed files under the prefix that are gone from the directory (needs a prefix other than /)")
+	syncCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Delete without asking for confirmation")
+	syncCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Show what would be uploaded and deleted without doing it")
+	syncCmd.Flags().IntVarP(&epochsFlag, "epochs", "e", 0, "Number of epochs to store new and changed files (default from config)")
+	syncCmd.Flags().BoolVar(&deletableFlag, "deletable", false, "Store blobs that can be deleted before they expire")
+	syncCmd.Flags().StringVar(&compressFlag, "compress", "", "Compress files before storing them (zstd or gzip)")
+	syncCmd.Flags().StringSliceVar(&includeFlags, "include", nil, "Only sync paths matching these patterns (e.g., *.pdf)")
+	syncCmd.Flags().StringSliceVar(&excludeFlags, "exclude", nil, "Skip paths matching these patterns (e.g., tmp/*)")
+	syncCmd.Flags().IntVar(&parallelFlag, "parallel", 3, "Number of files uploaded at once (1-10)")
+
+	// Delete command
+	deleteCmd := &cobra.Command{
+		Use:   "delete <name|blob-id>",
+		Short: "Delete a blob and its index entry",
+		Long:  "Delete a deletable blob on-chain to reclaim its storage and remove it from the local index.\nBlobs stored without --deletable can only be removed from the index.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := backend.LoadConfig("")
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+
+			client := newWalrusClient(config)
+			index := loadIndex()
+			return handleDelete(cmd.Context(), client, index, args[0], forceFlag)
+		},
+	}
+	deleteCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Delete without asking for confirmation")
+
+	// Extend command
+	extendCmd := &cobra.Command{
+		Use:   "extend <name|blob-id>",
+		Short: "Extend how long a blob is stored",
+		Long:  "Add epochs to a blob's storage period, showing the estimated cost first.\nThe configured wallet pays and must own the blob object.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := backend.LoadConfig("")
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+
+			epochs := epochsFlag
+			if epochs == 0 {
+				epochs = config.Walrus.Epochs
+			}
+
+			client := newWalrusClient(config)
+			index := loadIndex()
+			return handleExtend(cmd.Context(), client, index, args[0], epochs, forceFlag)
+		},
+	}
+	extendCmd.Flags().IntVarP(&epochsFlag, "epochs", "e", 0, "Epochs to extend by (default from config)")
+	extendCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Extend without asking for confirmation")
+
+	// Renew command
+	renewCmd := &cobra.Command{
+		Use:   "renew",
+		Short: "Extend every blob that expires soon",
+		Long:  "Find indexed blobs expiring within the given number of epochs and extend them all, showing the total estimated cost first.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := backend.LoadConfig("")
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+
+			epochs := epochsFlag
+			if epochs == 0 {
+				epochs = config.Walrus.Epochs
+			}
+
+			client := newWalrusClient(config)
+			index := loadIndex()
+			return handleRenew(cmd.Context(), client, index, withinFlag, epochs, forceFlag)
+		},
+	}
+	renewCmd.Flags().IntVar(&withinFlag, "expiring-within", 0, "Renew blobs expiring within this many epochs (required)")
+	renewCmd.Flags().IntVarP(&epochsFlag, "epochs", "e", 0, "Epochs to extend each blob by (default from config)")
+	renewCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "Renew without asking for confirmation")
+	renewCmd.MarkFlagRequired("expiring-within")
 
 	// List command
 	listCmd := &cobra.Command{
@@ -169,10 +391,7 @@ func createRootCmd() *cobra.Command {
 				return fmt.Errorf("loading config: %w", err)
 			}
 
-			client := backend.NewWalrusClient(
-				config.Walrus.AggregatorURL,
-				config.Walrus.PublisherURL,
-			)
+			client := newWalrusClient(config)
 
 			epochs := epochsFlag
 			if epochs == 0 {
@@ -267,11 +486,54 @@ func createRootCmd() *cobra.Command {
 	}
 
 	// Add all commands
-	rootCmd.AddCommand(setupCmd, statusCmd, uploadCmd, downloadCmd, listCmd, infoCmd, costCmd, webCmd, stopCmd, versionCmd, s3Cmd, indexerCmd, apiServerInternalCmd)
+	rootCmd.AddCommand(setupCmd, statusCmd, uploadCmd, downloadCmd, syncCmd, deleteCmd, extendCmd, renewCmd, listCmd, infoCmd, costCmd, webCmd, stopCmd, versionCmd, s3Cmd, indexerCmd, apiServerInternalCmd)
 
 	return rootCmd
 }
 
+// newWalrusClient builds a Walrus client from the loaded config and global flags
+func newWalrusClient(config *backend.Config) *backend.WalrusClient {
+	client := backend.NewWalrusClientWithEndpoints(
+		config.Walrus.AggregatorURLs(),
+		config.Walrus.PublisherURLs(),
+	)
+	client.CallTimeout = timeoutFlag
+	client.Retry = retryPolicy(config)
+
+	if config.Walrus.UploadRelayURL != "" {
+		client.UploadRelayURL = config.Walrus.UploadRelayURL
+	}
+	client.UseUploadRelay = config.Walrus.UseUploadRelay
+	if uploadRelayFlag != "" {
+		client.UseUploadRelay = true
+		if uploadRelayFlag != relayFromConfig {
+			client.UploadRelayURL = uploadRelayFlag
+		}
+	}
+	client.MaxRelayTip = config.Walrus.MaxRelayTip
+	client.MaxBlobSize = config.Walrus.MaxBlobSize
+	client.DedupChunkSize = config.Walrus.DedupChunkSize
+	client.SendObjectTo = config.Walrus.Wallet.Address
+	client.WalrusCLI = &backend.WalrusCLI{
+		Binary: config.Walrus.WalrusBinary,
+		Config: config.Walrus.ClientConfig,
+		Wallet: config.Walrus.Wallet.ConfigPath,
+	}
+	return client
+}
+
+// retryPolicy returns the configured retry policy with CLI overrides applied
+func retryPolicy(config *backend.Config) backend.RetryPolicy {
+	policy := config.Retry
+	if retriesFlag > 0 {
+		policy.MaxAttempts = retriesFlag
+	}
+	if retryBackoffFlag > 0 {
+		policy.InitialBackoff = retryBackoffFlag
+	}
+	return policy.WithDefaults()
+}
+
 // isPortInUse checks if a port is already in use
 func isPortInUse(port string) bool {
 	conn, err := net.Listen("tcp", ":"+port)
@@ -350,6 +612,21 @@ func handleInfoModern(index *FileIndex, nameOrID string) {
 		fmt.Printf("Name:       %s\n", magenta(nameOrID))
 		fmt.Printf("Size:       %s\n", blue(formatBytes(entry.Size)))
 		fmt.Printf("Blob ID:    %s\n", cyan(entry.BlobID))
+		if entry.QuiltPatchID != "" {
+			fmt.Printf("Patch ID:   %s\n", cyan(entry.QuiltPatchID))
+		}
+		if len(entry.Parts) > 0 {
+			fmt.Printf("Parts:      %d (Blob ID is the manifest)\n", len(entry.Parts))
+		}
+		if entry.Compression != nil {
+			fmt.Printf("Compressed: %s\n", compressionSummary(entry.Compression))
+		}
+		if entry.ContentType != "" {
+			fmt.Printf("Type:       %s\n", entry.ContentType)
+		}
+		for _, key := range sortedKeys(entry.Tags) {
+			fmt.Printf("Tag:        %s=%s\n", magenta(key), entry.Tags[key])
+		}
 		fmt.Printf("Uploaded:   %s\n", green(entry.ModTime.Format("2006-01-02 15:04:05")))
 		fmt.Printf("Expires:    %s\n", yellow(fmt.Sprintf("Epoch %d", entry.ExpiryEpoch)))
 
@@ -371,6 +648,12 @@ func handleInfoModern(index *FileIndex, nameOrID string) {
 			fmt.Printf("Blob ID:    %s\n", cyan(entry.BlobID))
 			fmt.Printf("File Name:  %s\n", magenta(name))
 			fmt.Printf("Size:       %s\n", blue(formatBytes(entry.Size)))
+			if entry.ContentType != "" {
+				fmt.Printf("Type:       %s\n", entry.ContentType)
+			}
+			for _, key := range sortedKeys(entry.Tags) {
+				fmt.Printf("Tag:        %s=%s\n", magenta(key), entry.Tags[key])
+			}
 			fmt.Printf("Uploaded:   %s\n", green(entry.ModTime.Format("2006-01-02 15:04:05")))
 			fmt.Printf("Expires:    %s\n", yellow(fmt.Sprintf("Epoch %d", entry.ExpiryEpoch)))
 			fmt.Println()
@@ -381,10 +664,68 @@ func handleInfoModern(index *FileIndex, nameOrID string) {
 		}
 	}
 
+	if name, entry := transferredEntry(nameOrID); entry != nil {
+		handleTransferredInfoModern(name, entry)
+		return
+	}
+
 	fmt.Printf(red("❌ File or blob ID '%s' not found in index\n"), nameOrID)
 	fmt.Println(blue("💡 Use 'walrus-cli list' to see available files"))
 }
 
+// handleTransferredInfoModern shows a file copied from S3, with its provenance
+func handleTransferredInfoModern(name string, entry *backend.SimpleFileEntry) {
+	fmt.Println()
+	fmt.Println(cyanBold("File Information"))
+	fmt.Println(strings.Repeat("=", 20))
+	fmt.Printf("Name:       %s\n", magenta(name))
+	fmt.Printf("Size:       %s\n", blue(formatBytes(entry.Size)))
+	fmt.Printf("Blob ID:    %s\n", cyan(entry.BlobID))
+	if entry.ContentType != "" {
+		fmt.Printf("Type:       %s\n", entry.ContentType)
+	}
+	for _, key := range sortedKeys(entry.Tags) {
+		fmt.Printf("Tag:        %s=%s\n", magenta(key), entry.Tags[key])
+	}
+	if entry.Checksums != nil {
+		fmt.Printf("SHA-256:    %s\n", entry.Checksums.SHA256)
+	}
+	if entry.Compression != nil {
+		fmt.Printf("Compressed: %s\n", compressionSummary(entry.Compression))
+	}
+	fmt.Printf("Copied:     %s\n", green(entry.ModTime.Format("2006-01-02 15:04:05")))
+	fmt.Printf("Expires:    %s\n", yellow(fmt.Sprintf("Epoch %d", entry.ExpiryEpoch)))
+
+	if source := entry.Source; source != nil {
+		fmt.Println()
+		fmt.Println(blueBold("Source"))
+		fmt.Printf("Object:     %s\n", blue("s3://"+source.Bucket+"/"+source.Key))
+		if source.VersionID != "" {
+			fmt.Printf("Version:    %s\n", source.VersionID)
+		}
+		if source.ETag != "" {
+			fmt.Printf("ETag:       %s\n", source.ETag)
+		}
+		if !source.LastModified.IsZero() {
+			fmt.Printf("Modified:   %s\n", source.LastModified.Format("2006-01-02 15:04:05"))
+		}
+		if source.CacheControl != "" {
+			fmt.Printf("Cache:      %s\n", source.CacheControl)
+		}
+		for _, key := range sortedKeys(source.Metadata) {
+			fmt.Printf("Metadata:   %s=%s\n", magenta(key), source.Metadata[key])
+		}
+		if source.DeletedAt != nil {
+			fmt.Printf("Deleted:    %s\n", red("upstream, "+source.DeletedAt.Format("2006-01-02 15:04:05")))
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(blueBold("Walruscan Explorer"))
+	fmt.Printf("URL: %s\n", blue(fmt.Sprintf("https://walruscan.com/testnet/blob/%s", entry.BlobID)))
+	fmt.Println()
+}
+
 func handleCostModern(client *backend.WalrusClient, size int64, epochs int) error {
 	cost, err := client.EstimateStorageCost(size, epochs)
 	if err != nil {
@@ -398,6 +739,9 @@ func handleCostModern(client *backend.WalrusClient, size int64, epochs int) erro
 	fmt.Printf("Duration:   %d epochs\n", epochs)
 	fmt.Printf("Cost:       %s\n", green(formatWAL(cost)+" WAL"))
 	fmt.Printf("USD Value:  %s\n", green(fmt.Sprintf("~$%.4f", float64(cost)/1_000_000_000*0.425)))
+	if stats := dedupStats(); stats != "" {
+		fmt.Printf("Dedup:      %s\n", stats)
+	}
 	fmt.Println()
 
 	return nil
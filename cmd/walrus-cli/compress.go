@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/justmert/walrus-cli/backend"
+)
+
+// compressUpload compresses file with codec into a temporary spool, unless
+// its content type is already compressed or compressing does not make it
+// smaller. It returns the spool and what was done, or a nil spool and the
+// reason it was skipped. The caller closes and removes the spool.
+func compressUpload(file *os.File, contentType string, size int64, codec string) (*os.File, *backend.CompressionInfo, string, error) {
+	if !backend.Compressible(contentType) {
+		return nil, nil, fmt.Sprintf("skipped (%s is already compressed)", contentType), nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, "", err
+	}
+	spool, err := backend.CompressToFile(file, codec)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	stat, err := spool.Stat()
+	if err != nil || stat.Size() >= size {
+		spool.Close()
+		os.Remove(spool.Name())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return nil, nil, "skipped (the file does not get smaller)", nil
+	}
+	info := &backend.CompressionInfo{Codec: codec, Size: stat.Size()}
+	return spool, info, fmt.Sprintf("%s, %s → %s", codec, formatBytes(size), formatBytes(stat.Size())), nil
+}
+
+// compressionCodec returns the codec an index entry was compressed with, or ""
+func compressionCodec(info *backend.CompressionInfo) string {
+	if info == nil {
+		return ""
+	}
+	return info.Codec
+}
+
+// compressionSummary describes how a file is compressed, e.g. "zstd (1.2 MB stored)"
+func compressionSummary(info *backend.CompressionInfo) string {
+	return fmt.Sprintf("%s (%s stored)", info.Codec, formatBytes(info.Size))
+}
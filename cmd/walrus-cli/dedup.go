@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/justmert/walrus-cli/backend"
+	"github.com/schollz/progressbar/v3"
+)
+
+// handleDedupUpload stores a file in content-defined chunks, referencing the
+// chunks the local chunk store already holds instead of uploading them again
+func handleDedupUpload(ctx context.Context, client *backend.WalrusClient, index *FileIndex, filePath string, opts uploadOptions) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	store, err := backend.LoadChunkStore(backend.ChunkStorePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading chunk store: %v\n", err)
+		os.Exit(1)
+	}
+
+	fileName := filepath.Base(filePath)
+	epochs := opts.Epochs
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = detectContentType(file, fileName)
+	}
+
+	plan, err := client.PlanDedup(file, store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error chunking file: %v\n", err)
+		os.Exit(1)
+	}
+	cost, err := client.EstimateDedupCost(plan, epochs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error estimating cost: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("File: %s\n", fileName)
+	fmt.Printf("Size: %s\n", formatBytes(plan.Size))
+	fmt.Printf("Content Type: %s\n", contentType)
+	printTags(opts.Tags)
+	fmt.Printf("Epochs: %d\n", epochs)
+	if opts.Deletable {
+		fmt.Println("Deletable: yes")
+	}
+	fmt.Printf("Chunks: %d (%d new)\n", len(plan.Chunks), plan.NewChunks)
+	fmt.Printf("Dedup Saves: %s\n", dedupSavings(plan.SavedBytes(), plan.Size))
+	fmt.Printf("Estimated Cost: %s\n", formatWALWithUSD(cost))
+
+	if client.UseUploadRelay {
+		fmt.Printf("Upload Relay: %s\n", client.UploadRelayURL)
+		if err := client.RelayReady(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		tip, err := client.RelayTipConfig(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching relay tip config: %v\n", err)
+			os.Exit(1)
+		}
+		// Every new chunk and the manifest pay a tip of their own
+		total := tip.TipFor(int64(len(plan.Chunks))*200, client.ShardCount)
+		for _, size := range plan.NewSizes() {
+			total += tip.TipFor(size, client.ShardCount)
+		}
+		fmt.Printf("Relay Tip: %s\n", formatRelayTip(total))
+	}
+
+	if opts.DryRun {
+		fmt.Println("\n✓ Dry run complete (no data uploaded)")
+		return
+	}
+
+	fmt.Println()
+	bar := progressbar.DefaultBytes(plan.Size, "Uploading")
+
+	storeOpts := backend.StoreOptions{Epochs: epochs, Deletable: opts.Deletable}
+	if opts.wantsAttributes(client) {
+		storeOpts.Attributes = backend.BlobAttributes(contentType, fileName, opts.Tags)
+	}
+	resp, manifest, err := client.StoreDeduplicatedContext(ctx, file, plan, store, storeOpts, bar)
+
+	// Chunks stored before a failure can still be referenced next time
+	if saveErr := store.Save(); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to save chunk store: %v\n", saveErr)
+	}
+	if err != nil && resp == nil {
+		fmt.Fprintf(os.Stderr, "\nError uploading: %v\n", err)
+		os.Exit(1)
+	}
+	bar.Finish()
+
+	fmt.Println("✓ Upload complete")
+	if err != nil {
+		// The file is stored; only the attributes are missing
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	// The file is readable until its first part expires
+	expiryEpoch := 0
+	if resp.EndEpoch != nil {
+		expiryEpoch = int(*resp.EndEpoch)
+	}
+	index.Files[fileName] = &FileEntry{
+		BlobID:       resp.BlobID,
+		Size:         plan.Size,
+		ModTime:      time.Now(),
+		ExpiryEpoch:  expiryEpoch,
+		OriginalPath: filePath,
+		ContentType:  contentType,
+		Tags:         opts.Tags,
+		SuiObjectID:  resp.SuiObjectID,
+		Deletable:    resp.Deletable,
+		Parts:        manifest.Parts,
+	}
+	if err := saveIndex(index); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to save index: %v\n", err)
+	}
+
+	fmt.Printf("\n%s\n", color.GreenString("🎉 Successfully uploaded to Walrus"))
+	fmt.Printf("  %s %s\n", color.CyanString("Blob ID:"), color.BlueString(resp.BlobID))
+	fmt.Printf("  %s %s\n", color.CyanString("Dedup:"), dedupSavings(plan.SavedBytes(), plan.Size))
+	fmt.Printf("  %s %s\n", color.YellowString("Expires:"), color.YellowString("Epoch %d", expiryEpoch))
+	fmt.Printf("  %s %s\n", color.MagentaString("Walruscan:"), color.BlueString("https://walruscan.com/testnet/blob/%s", resp.BlobID))
+}
+
+// dedupSavings describes saved bytes out of total, e.g. "1.2 GB of 1.5 GB (80%)"
+func dedupSavings(saved, total int64) string {
+	if total == 0 {
+		return formatBytes(saved)
+	}
+	return fmt.Sprintf("%s of %s (%.0f%%)", formatBytes(saved), formatBytes(total), float64(saved)/float64(total)*100)
+}
+
+// dedupStats describes what deduplicated uploads have saved so far, or
+// returns "" if there have been none
+func dedupStats() string {
+	store, err := backend.LoadChunkStore(backend.ChunkStorePath())
+	if err != nil || len(store.Chunks) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s saved by reusing chunks; %d chunks (%s) stored",
+		formatBytes(store.SavedBytes), len(store.Chunks), formatBytes(store.StoredBytes()))
+}
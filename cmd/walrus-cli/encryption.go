@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/justmert/walrus-cli/backend"
+)
+
+// passphraseEnv holds the passphrase for --encrypt and --decrypt when no key
+// file is given, for scripts that cannot answer a prompt
+const passphraseEnv = "WALRUS_PASSPHRASE"
+
+// loadEncryptionKey returns the key in keyFile, or a key derived from a
+// passphrase taken from $WALRUS_PASSPHRASE or asked for. A passphrase that
+// will encrypt new data is asked for twice, so a typo cannot lock it away.
+func loadEncryptionKey(keyFile string, confirm bool) (*backend.EncryptionKey, error) {
+	if keyFile != "" {
+		return backend.LoadKeyFile(keyFile)
+	}
+
+	passphrase := os.Getenv(passphraseEnv)
+	if passphrase == "" {
+		prompt := &survey.Password{Message: "Encryption passphrase:"}
+		if err := survey.AskOne(prompt, &passphrase); err != nil {
+			return nil, err
+		}
+		if confirm {
+			var again string
+			if err := survey.AskOne(&survey.Password{Message: "Repeat the passphrase:"}, &again); err != nil {
+				return nil, err
+			}
+			if again != passphrase {
+				return nil, errors.New("passphrases do not match")
+			}
+		}
+	}
+	return backend.NewPassphraseKey(passphrase)
+}
+
+// decryptKey returns the key for download --decrypt, or nil without it. The
+// flags are checked before any passphrase is asked for.
+func decryptKey(decrypt bool, keyFile string, opts backend.DownloadOptions) (*backend.EncryptionKey, error) {
+	if !decrypt {
+		if keyFile != "" {
+			return nil, errors.New("--key-file is only used with --decrypt")
+		}
+		return nil, nil
+	}
+	if opts.Resume || opts.Range != nil {
+		return nil, errors.New("--decrypt downloads the whole blob and cannot be combined with --resume or --range")
+	}
+	return loadEncryptionKey(keyFile, false)
+}
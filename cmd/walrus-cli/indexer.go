@@ -29,17 +29,7 @@ var listBlobsCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		// Default Sui RPC URLs
-		suiRPCURL := "https://fullnode.testnet.sui.io:443"
-		if strings.Contains(config.Walrus.AggregatorURL, "mainnet") {
-			suiRPCURL = "https://fullnode.mainnet.sui.io:443"
-		}
-
-		indexer := backend.NewBlobIndexerService(
-			suiRPCURL,
-			config.Walrus.AggregatorURL,
-			config.Walrus.PublisherURL,
-		)
+		indexer := newBlobIndexerService(config)
 
 		query, _ := cmd.Flags().GetString("query")
 
@@ -47,9 +37,9 @@ var listBlobsCmd = &cobra.Command{
 		var err2 error
 
 		if query != "" {
-			blobs, err2 = indexer.SearchBlobs(userAddress, query)
+			blobs, err2 = indexer.SearchBlobsContext(cmd.Context(), userAddress, query)
 		} else {
-			blobs, err2 = indexer.GetUserBlobs(userAddress)
+			blobs, err2 = indexer.GetUserBlobsContext(cmd.Context(), userAddress)
 		}
 
 		if err2 != nil {
@@ -86,18 +76,9 @@ var getBlobCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		suiRPCURL := "https://fullnode.testnet.sui.io:443"
-		if strings.Contains(config.Walrus.AggregatorURL, "mainnet") {
-			suiRPCURL = "https://fullnode.mainnet.sui.io:443"
-		}
-
-		indexer := backend.NewBlobIndexerService(
-			suiRPCURL,
-			config.Walrus.AggregatorURL,
-			config.Walrus.PublisherURL,
-		)
+		indexer := newBlobIndexerService(config)
 
-		blob, err := indexer.GetBlobDetails(blobID)
+		blob, err := indexer.GetBlobDetailsContext(cmd.Context(), blobID)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error getting blob details: %v\n", err)
 			os.Exit(1)
@@ -119,6 +100,24 @@ var getBlobCmd = &cobra.Command{
 	},
 }
 
+// newBlobIndexerService builds an indexer for the configured network, picking
+// the Sui RPC endpoint from the aggregator URL (heuristic)
+func newBlobIndexerService(config *backend.Config) *backend.BlobIndexerService {
+	suiRPCURL := "https://fullnode.testnet.sui.io:443"
+	if strings.Contains(config.Walrus.AggregatorURL, "mainnet") {
+		suiRPCURL = "https://fullnode.mainnet.sui.io:443"
+	}
+
+	indexer := backend.NewBlobIndexerService(
+		suiRPCURL,
+		config.Walrus.AggregatorURL,
+		config.Walrus.PublisherURL,
+	)
+	indexer.SetWalrusClient(newWalrusClient(config))
+	indexer.SetRetryPolicy(retryPolicy(config))
+	return indexer
+}
+
 func printBlobsTable(blobs []backend.IndexedBlob) {
 	if len(blobs) == 0 {
 		fmt.Println("No blobs found.")
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -13,6 +18,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/justmert/walrus-cli/backend"
+	"github.com/schollz/progressbar/v3"
 )
 
 // FileIndex manages local file mappings
@@ -21,14 +27,44 @@ type FileIndex struct {
 }
 
 type FileEntry struct {
-	BlobID       string    `json:"blob_id"`
-	Size         int64     `json:"size"`
-	ModTime      time.Time `json:"mod_time"`
-	ExpiryEpoch  int       `json:"expiry_epoch"`
-	OriginalPath string    `json:"original_path"`
+	BlobID       string                   `json:"blob_id"`
+	Size         int64                    `json:"size"`
+	ModTime      time.Time                `json:"mod_time"`
+	FileModTime  *time.Time               `json:"file_mod_time,omitempty"` // Modification time of the file when it was uploaded
+	SHA256       string                   `json:"sha256,omitempty"`        // Hex digest of the file's content, recorded by tree uploads and sync
+	ExpiryEpoch  int                      `json:"expiry_epoch"`
+	OriginalPath string                   `json:"original_path"`
+	QuiltPatchID string                   `json:"quilt_patch_id,omitempty"` // Set when BlobID is a quilt holding this file
+	ContentType  string                   `json:"content_type,omitempty"`
+	Tags         map[string]string        `json:"tags,omitempty"`
+	SuiObjectID  string                   `json:"sui_object_id,omitempty"`
+	Deletable    bool                     `json:"deletable,omitempty"`
+	Parts        []backend.ChunkPart      `json:"parts,omitempty"`       // Set when BlobID is the manifest of a chunked file
+	Compression  *backend.CompressionInfo `json:"compression,omitempty"` // Set when the file was compressed before it was stored
 }
 
-func mainLegacy() {
+// uploadOptions collects the per-upload settings shared by the cobra and
+// legacy front ends
+type uploadOptions struct {
+	Epochs      int
+	DryRun      bool
+	Deletable   bool
+	ContentType string            // Explicit content type; detected from the file when empty
+	Tags        map[string]string // Stored as blob attributes and in the index
+	Compress    string            // Codec to compress the file with, "" for none
+	Include     []string          // Globs a path below the directory must match, for recursive uploads
+	Exclude     []string          // Globs that leave a path out of a recursive upload
+	Parallel    int               // Files a recursive upload sends at once
+}
+
+// wantsAttributes reports whether the upload should attach blob attributes:
+// whenever client can sign for them, so a download by blob ID gets the file
+// name back, and otherwise only when tags or a content type were asked for.
+func (o uploadOptions) wantsAttributes(client *backend.WalrusClient) bool {
+	return o.ContentType != "" || len(o.Tags) > 0 || client.CanSetAttributes()
+}
+
+func mainLegacy(ctx context.Context) {
 	// Define commands
 	uploadCmd := flag.NewFlagSet("upload", flag.ExitOnError)
 	downloadCmd := flag.NewFlagSet("download", flag.ExitOnError)
@@ -36,13 +72,58 @@ func mainLegacy() {
 	costCmd := flag.NewFlagSet("cost", flag.ExitOnError)
 	infoCmd := flag.NewFlagSet("info", flag.ExitOnError)
 	statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
+	deleteCmd := flag.NewFlagSet("delete", flag.ExitOnError)
+	extendCmd := flag.NewFlagSet("extend", flag.ExitOnError)
+	renewCmd := flag.NewFlagSet("renew", flag.ExitOnError)
+	syncCmd := flag.NewFlagSet("sync", flag.ExitOnError)
 
 	// Upload flags
 	uploadEpochs := uploadCmd.Int("epochs", 5, "Number of epochs to store")
 	uploadDryRun := uploadCmd.Bool("dry-run", false, "Estimate cost without uploading")
+	uploadDeletable := uploadCmd.Bool("deletable", false, "Store a blob that can be deleted before it expires")
+	uploadQuilt := uploadCmd.Bool("quilt", false, "Store all given files together as one quilt")
+	uploadDedup := uploadCmd.Bool("dedup", false, "Split the file into content-defined chunks and skip chunks already stored")
+	uploadCompress := uploadCmd.String("compress", "", "Compress the file before storing it (zstd or gzip)")
+	uploadRecursive := uploadCmd.Bool("r", false, "Upload a directory and every file below it")
+	var uploadInclude, uploadExclude stringList
+	uploadCmd.Var(&uploadInclude, "include", "With -r, only upload paths matching this pattern (repeatable)")
+	uploadCmd.Var(&uploadExclude, "exclude", "With -r, skip paths matching this pattern (repeatable)")
+	uploadParallel := uploadCmd.Int("parallel", 3, "With -r, number of files uploaded at once (1-10)")
+	uploadContentType := uploadCmd.String("content-type", "", "Content type stored with the blob (detected when omitted)")
+	var uploadTags stringList
+	uploadCmd.Var(&uploadTags, "tag", "Attach a key=value tag (repeatable)")
+	uploadRelay := uploadCmd.String("upload-relay", "", "Store through an upload relay URL (\"config\" for the configured one)")
 
 	// Download flags
-	downloadOutput := downloadCmd.String("output", "", "Output file path")
+	downloadOutput := downloadCmd.String("output", "", "Output file path (with -r, the directory to rebuild the tree in)")
+	downloadRecursive := downloadCmd.Bool("r", false, "Download every file whose name starts with the given prefix")
+	downloadParallel := downloadCmd.Int("parallel", 3, "With -r, number of files downloaded at once (1-10)")
+	downloadResume := downloadCmd.Bool("resume", false, "Resume a partial download")
+	downloadRange := downloadCmd.String("range", "", "Byte range to fetch (start-end or start-)")
+	downloadDecrypt := downloadCmd.Bool("decrypt", false, "Decrypt a blob stored by s3 transfer --encrypt")
+	downloadKeyFile := downloadCmd.String("key-file", "", "Key file for -decrypt (default: ask for the passphrase)")
+
+	// Sync flags
+	syncEpochs := syncCmd.Int("epochs", 5, "Number of epochs to store new and changed files")
+	syncDryRun := syncCmd.Bool("dry-run", false, "Show what would be uploaded and deleted without doing it")
+	syncDelete := syncCmd.Bool("delete", false, "Delete indexed files under the prefix that are gone from the directory (needs a prefix other than /)")
+	syncForce := syncCmd.Bool("force", false, "Delete without asking for confirmation")
+	syncDeletable := syncCmd.Bool("deletable", false, "Store blobs that can be deleted before they expire")
+	syncCompress := syncCmd.String("compress", "", "Compress files before storing them (zstd or gzip)")
+	var syncInclude, syncExclude stringList
+	syncCmd.Var(&syncInclude, "include", "Only sync paths matching this pattern (repeatable)")
+	syncCmd.Var(&syncExclude, "exclude", "Skip paths matching this pattern (repeatable)")
+	syncParallel := syncCmd.Int("parallel", 3, "Number of files uploaded at once (1-10)")
+
+	// Delete flags
+	deleteForce := deleteCmd.Bool("force", false, "Delete without asking for confirmation")
+
+	// Extend and renew flags
+	extendEpochs := extendCmd.Int("epochs", 5, "Epochs to extend by")
+	extendForce := extendCmd.Bool("force", false, "Extend without asking for confirmation")
+	renewWithin := renewCmd.Int("expiring-within", 0, "Renew blobs expiring within this many epochs")
+	renewEpochs := renewCmd.Int("epochs", 5, "Epochs to extend each blob by")
+	renewForce := renewCmd.Bool("force", false, "Renew without asking for confirmation")
 
 	// Cost flags
 	costSize := costCmd.Int64("size", 0, "File size in bytes")
@@ -61,10 +142,7 @@ func mainLegacy() {
 	}
 
 	// Create client
-	client := backend.NewWalrusClient(
-		config.Walrus.AggregatorURL,
-		config.Walrus.PublisherURL,
-	)
+	client := newWalrusClient(config)
 
 	// Load file index
 	index := loadIndex()
@@ -76,7 +154,56 @@ func mainLegacy() {
 			fmt.Println("Error: Please provide a file to upload")
 			os.Exit(1)
 		}
-		handleUpload(client, index, uploadCmd.Arg(0), *uploadEpochs, *uploadDryRun)
+		if *uploadRelay != "" {
+			uploadRelayFlag = *uploadRelay
+			client = newWalrusClient(config)
+		}
+		tags, err := backend.ParseTags(uploadTags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		codec, err := backend.ParseCompression(*uploadCompress)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts := uploadOptions{
+			Epochs:      *uploadEpochs,
+			DryRun:      *uploadDryRun,
+			Deletable:   *uploadDeletable,
+			ContentType: *uploadContentType,
+			Tags:        tags,
+			Compress:    codec,
+			Include:     uploadInclude,
+			Exclude:     uploadExclude,
+			Parallel:    *uploadParallel,
+		}
+		switch {
+		case *uploadQuilt && *uploadDedup:
+			fmt.Fprintln(os.Stderr, "Error: --quilt and --dedup cannot be combined")
+			os.Exit(1)
+		case codec != "" && (*uploadQuilt || *uploadDedup):
+			fmt.Fprintln(os.Stderr, "Error: --compress cannot be combined with --quilt or --dedup")
+			os.Exit(1)
+		case *uploadRecursive && (*uploadQuilt || *uploadDedup):
+			fmt.Fprintln(os.Stderr, "Error: -r cannot be combined with --quilt or --dedup")
+			os.Exit(1)
+		case *uploadDeletable && !client.OwnsStoredBlobs():
+			fmt.Fprintf(os.Stderr, "Error: %v\n", errDeletableNotOwned)
+			os.Exit(1)
+		case !*uploadRecursive && (len(uploadInclude) > 0 || len(uploadExclude) > 0):
+			fmt.Fprintln(os.Stderr, "Error: --include and --exclude are only used with -r")
+			os.Exit(1)
+		case *uploadRecursive:
+			handleRecursiveUpload(ctx, client, index, uploadCmd.Arg(0), opts)
+		case *uploadQuilt:
+			handleQuiltUpload(ctx, client, index, uploadCmd.Args(), opts)
+		case *uploadDedup:
+			handleDedupUpload(ctx, client, index, uploadCmd.Arg(0), opts)
+		default:
+			handleUpload(ctx, client, index, uploadCmd.Arg(0), opts)
+		}
 
 	case "download":
 		downloadCmd.Parse(os.Args[2:])
@@ -84,7 +211,30 @@ func mainLegacy() {
 			fmt.Println("Error: Please provide a filename to download")
 			os.Exit(1)
 		}
-		handleDownload(client, index, downloadCmd.Arg(0), *downloadOutput)
+		opts := backend.DownloadOptions{Resume: *downloadResume}
+		if *downloadRange != "" {
+			rng, err := backend.ParseByteRange(*downloadRange)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts.Range = rng
+		}
+		if *downloadRecursive {
+			if opts.Range != nil || *downloadDecrypt {
+				fmt.Fprintln(os.Stderr, "Error: --range and --decrypt cannot be combined with -r")
+				os.Exit(1)
+			}
+			handleRecursiveDownload(ctx, client, index, downloadCmd.Arg(0), *downloadOutput, opts, *downloadParallel)
+			break
+		}
+		key, err := decryptKey(*downloadDecrypt, *downloadKeyFile, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Decrypt = key
+		handleDownload(ctx, client, index, downloadCmd.Arg(0), *downloadOutput, opts)
 
 	case "list", "ls":
 		listCmd.Parse(os.Args[2:])
@@ -111,9 +261,60 @@ func mainLegacy() {
 		}
 		handleInfo(index, infoCmd.Arg(0))
 
+	case "sync":
+		syncCmd.Parse(os.Args[2:])
+		if syncCmd.NArg() < 2 {
+			fmt.Println("Error: Please provide a directory and a remote prefix")
+			os.Exit(1)
+		}
+		codec, err := backend.ParseCompression(*syncCompress)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts := uploadOptions{
+			Epochs:    *syncEpochs,
+			DryRun:    *syncDryRun,
+			Deletable: *syncDeletable,
+			Compress:  codec,
+			Include:   syncInclude,
+			Exclude:   syncExclude,
+			Parallel:  *syncParallel,
+		}
+		handleSync(ctx, client, index, syncCmd.Arg(0), syncCmd.Arg(1), opts, *syncDelete, *syncForce)
+
+	case "delete":
+		deleteCmd.Parse(os.Args[2:])
+		if deleteCmd.NArg() < 1 {
+			fmt.Println("Error: Please provide a filename or blob ID")
+			os.Exit(1)
+		}
+		if err := handleDelete(ctx, client, index, deleteCmd.Arg(0), *deleteForce); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "extend":
+		extendCmd.Parse(os.Args[2:])
+		if extendCmd.NArg() < 1 {
+			fmt.Println("Error: Please provide a filename or blob ID")
+			os.Exit(1)
+		}
+		if err := handleExtend(ctx, client, index, extendCmd.Arg(0), *extendEpochs, *extendForce); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "renew":
+		renewCmd.Parse(os.Args[2:])
+		if err := handleRenew(ctx, client, index, *renewWithin, *renewEpochs, *renewForce); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
 	case "status":
 		statusCmd.Parse(os.Args[2:])
-		handleStatus(config)
+		handleStatus(ctx, config)
 
 	default:
 		printUsage()
@@ -121,19 +322,58 @@ func mainLegacy() {
 	}
 }
 
-func handleUpload(client *backend.WalrusClient, index *FileIndex, filePath string, epochs int, dryRun bool) {
-	// Read file
-	data, err := os.ReadFile(filePath)
+func handleUpload(ctx context.Context, client *backend.WalrusClient, index *FileIndex, filePath string, opts uploadOptions) {
+	// Open file; the contents are streamed to the publisher rather than read into memory
+	file, err := os.Open(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
 		os.Exit(1)
 	}
+	if stat.IsDir() {
+		fmt.Fprintf(os.Stderr, "Error: %s is a directory (use -r to upload it recursively)\n", filePath)
+		os.Exit(1)
+	}
 
 	fileName := filepath.Base(filePath)
-	fileSize := int64(len(data))
+	fileSize := stat.Size()
+	fileModTime := stat.ModTime()
+	epochs := opts.Epochs
+
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = detectContentType(file, fileName)
+	}
+
+	// A compressed file is uploaded from a temporary spool, so its size and
+	// cost are known up front
+	source, uploadSize := file, fileSize
+	var compression *backend.CompressionInfo
+	var compressNote string
+	if opts.Compress != "" {
+		var spool *os.File
+		spool, compression, compressNote, err = compressUpload(file, contentType, fileSize, opts.Compress)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error compressing file: %v\n", err)
+			os.Exit(1)
+		}
+		if spool != nil {
+			defer func() {
+				spool.Close()
+				os.Remove(spool.Name())
+			}()
+			source, uploadSize = spool, compression.Size
+		}
+	}
 
 	// Estimate cost
-	cost, err := client.EstimateStorageCost(fileSize, epochs)
+	cost, err := client.EstimateStorageCost(uploadSize, epochs)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error estimating cost: %v\n", err)
 		os.Exit(1)
@@ -141,24 +381,72 @@ func handleUpload(client *backend.WalrusClient, index *FileIndex, filePath strin
 
 	fmt.Printf("File: %s\n", fileName)
 	fmt.Printf("Size: %s\n", formatBytes(fileSize))
+	fmt.Printf("Content Type: %s\n", contentType)
+	printTags(opts.Tags)
 	fmt.Printf("Epochs: %d\n", epochs)
-	fmt.Printf("Estimated Cost: %s\n", formatWALWithUSD(cost))
+	if opts.Deletable {
+		fmt.Println("Deletable: yes")
+	}
+	if compressNote != "" {
+		fmt.Printf("Compression: %s\n", compressNote)
+	}
+	chunked := client.NeedsChunking(uploadSize)
+	if chunked {
+		fmt.Printf("Parts: %d (larger than the max blob size)\n", client.PartCount(uploadSize, false))
+	}
+	if compression != nil {
+		uncompressed, err := client.EstimateStorageCost(fileSize, epochs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error estimating cost: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Estimated Cost: %s (%s uncompressed)\n", formatWALWithUSD(cost), formatWAL(uncompressed))
+	} else {
+		fmt.Printf("Estimated Cost: %s\n", formatWALWithUSD(cost))
+	}
 
-	if dryRun {
+	if client.UseUploadRelay {
+		fmt.Printf("Upload Relay: %s\n", client.UploadRelayURL)
+		if err := client.RelayReady(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		tip, err := client.RelayTipConfig(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching relay tip config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Relay Tip: %s\n", formatRelayTip(tip.TipFor(uploadSize, client.ShardCount)))
+	}
+
+	if opts.DryRun {
 		fmt.Println("\n✓ Dry run complete (no data uploaded)")
 		return
 	}
 
-	fmt.Print("\nUploading... ")
+	fmt.Println()
+	bar := progressbar.DefaultBytes(uploadSize, "Uploading")
 
 	// Upload to Walrus
-	resp, err := client.StoreBlob(data, epochs)
-	if err != nil {
+	storeOpts := backend.StoreOptions{Epochs: epochs, Deletable: opts.Deletable}
+	if opts.wantsAttributes(client) {
+		storeOpts.Attributes = backend.BlobAttributes(contentType, fileName, opts.Tags)
+		if compression != nil {
+			storeOpts.Attributes[backend.AttributeCompression] = compression.Codec
+		}
+	}
+	resp, parts, err := storeFile(ctx, client, source, uploadSize, storeOpts, bar)
+	if err != nil && resp == nil {
 		fmt.Fprintf(os.Stderr, "\nError uploading: %v\n", err)
 		os.Exit(1)
 	}
+	bar.Finish()
 
-	fmt.Println("✓")
+	fmt.Println("✓ Upload complete")
+	if err != nil {
+		// The blob is stored; only the attributes are missing
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
 
 	// Update index
 	expiryEpoch := 0
@@ -169,8 +457,15 @@ func handleUpload(client *backend.WalrusClient, index *FileIndex, filePath strin
 		BlobID:       resp.BlobID,
 		Size:         fileSize,
 		ModTime:      time.Now(),
+		FileModTime:  &fileModTime,
 		ExpiryEpoch:  expiryEpoch,
 		OriginalPath: filePath,
+		ContentType:  contentType,
+		Tags:         opts.Tags,
+		SuiObjectID:  resp.SuiObjectID,
+		Deletable:    resp.Deletable,
+		Parts:        parts,
+		Compression:  compression,
 	}
 
 	// Save index
@@ -181,41 +476,296 @@ func handleUpload(client *backend.WalrusClient, index *FileIndex, filePath strin
 	fmt.Printf("\n%s\n", color.GreenString("🎉 Successfully uploaded to Walrus"))
 	fmt.Printf("  %s %s\n", color.CyanString("Blob ID:"), color.BlueString(resp.BlobID))
 	fmt.Printf("  %s %s\n", color.YellowString("Expires:"), color.YellowString("Epoch %d", expiryEpoch))
+	if resp.RelayTip > 0 {
+		fmt.Printf("  %s %s\n", color.CyanString("Relay tip:"), formatRelayTip(resp.RelayTip))
+	}
 	fmt.Printf("  %s %s\n", color.MagentaString("Walruscan:"), color.BlueString("https://walruscan.com/testnet/blob/%s", resp.BlobID))
 }
 
-func handleDownload(client *backend.WalrusClient, index *FileIndex, fileName, outputPath string) {
-	// Find file in index
-	entry, exists := index.Files[fileName]
-	if !exists {
-		fmt.Fprintf(os.Stderr, "Error: File '%s' not found in index\n", fileName)
-		fmt.Println("Use 'walrus-cli list' to see available files")
+// storeFile stores the first size bytes of source, in parts and a manifest
+// when they do not fit in one blob, and returns the parts if it did that.
+// Bytes sent are added to bar; a retried upload takes back what the failed
+// attempt added, so the bar can be shared between files.
+func storeFile(ctx context.Context, client *backend.WalrusClient, source *os.File, size int64, storeOpts backend.StoreOptions, bar *progressbar.ProgressBar) (*backend.StoreResponse, []backend.ChunkPart, error) {
+	if client.NeedsChunking(size) {
+		upload := backend.ChunkedUpload{
+			Size: size,
+			Open: func(offset, length int64) (io.ReadCloser, error) {
+				return io.NopCloser(io.NewSectionReader(source, offset, length)), nil
+			},
+			Progress: bar,
+		}
+		resp, manifest, err := client.StoreChunkedContext(ctx, upload, storeOpts)
+		if manifest == nil {
+			return resp, nil, err
+		}
+		return resp, manifest.Parts, err
+	}
+
+	// Each retry rewinds the file
+	sent := &attemptProgress{bar: bar}
+	open := func() (io.ReadCloser, error) {
+		if _, err := source.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		sent.reset()
+		return io.NopCloser(io.TeeReader(io.LimitReader(source, size), sent)), nil
+	}
+	resp, err := client.StoreBlobWithOptionsContext(ctx, open, size, storeOpts)
+	return resp, nil, err
+}
+
+// attemptProgress feeds a progress bar and remembers how much one upload
+// attempt added to it
+type attemptProgress struct {
+	bar *progressbar.ProgressBar
+	n   int64
+}
+
+func (p *attemptProgress) Write(b []byte) (int, error) {
+	p.bar.Add(len(b))
+	p.n += int64(len(b))
+	return len(b), nil
+}
+
+// reset takes the bytes of the previous attempt back off the bar
+func (p *attemptProgress) reset() {
+	p.bar.Add64(-p.n)
+	p.n = 0
+}
+
+// handleQuiltUpload stores several files as a single quilt and indexes each
+// of them under its own name, pointing at the quilt and its patch
+func handleQuiltUpload(ctx context.Context, client *backend.WalrusClient, index *FileIndex, filePaths []string, opts uploadOptions) {
+	epochs := opts.Epochs
+	var totalSize int64
+	var separateCost int64
+	patches := make([]backend.QuiltPatch, 0, len(filePaths))
+	sizes := make(map[string]int64, len(filePaths))
+	contentTypes := make(map[string]string, len(filePaths))
+	for _, filePath := range filePaths {
+		stat, err := os.Stat(filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			os.Exit(1)
+		}
+		if stat.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error: %s is a directory\n", filePath)
+			os.Exit(1)
+		}
+
+		patch := backend.FileQuiltPatch(filePath)
+		contentTypes[patch.Identifier] = opts.ContentType
+		if contentTypes[patch.Identifier] == "" {
+			contentTypes[patch.Identifier] = detectFileContentType(filePath)
+		}
+		if opts.wantsAttributes(client) {
+			patch.Tags = backend.BlobAttributes(contentTypes[patch.Identifier], "", opts.Tags)
+		}
+		if _, dup := sizes[patch.Identifier]; dup {
+			fmt.Fprintf(os.Stderr, "Error: more than one file is named %s\n", patch.Identifier)
+			os.Exit(1)
+		}
+		patches = append(patches, patch)
+		sizes[patch.Identifier] = stat.Size()
+		totalSize += stat.Size()
+
+		cost, _ := client.EstimateStorageCost(stat.Size(), epochs)
+		separateCost += cost
+	}
+
+	cost, err := client.EstimateStorageCost(totalSize, epochs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error estimating cost: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Downloading %s (Blob ID: %s)... ", fileName, entry.BlobID[:12]+"...")
+	fmt.Printf("Files: %d (as one quilt)\n", len(patches))
+	fmt.Printf("Total Size: %s\n", formatBytes(totalSize))
+	fmt.Printf("Epochs: %d\n", epochs)
+	fmt.Printf("Estimated Cost: %s\n", formatWALWithUSD(cost))
+	if len(patches) > 1 {
+		fmt.Printf("Cost as separate blobs: %s\n", formatWALWithUSD(separateCost))
+	}
+	printTags(opts.Tags)
+
+	if opts.DryRun {
+		fmt.Println("\n✓ Dry run complete (no data uploaded)")
+		return
+	}
 
-	// Download from Walrus
-	data, err := client.RetrieveBlob(entry.BlobID)
+	fmt.Println()
+	fmt.Println("Uploading quilt...")
+	resp, err := client.StoreQuiltWithOptionsContext(ctx, patches, backend.StoreOptions{Epochs: epochs, Deletable: opts.Deletable})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "\nError downloading: %v\n", err)
+		fmt.Fprintf(os.Stderr, "\nError uploading: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("✓")
+	fmt.Println("✓ Upload complete")
+
+	expiryEpoch := 0
+	if resp.Blob.EndEpoch != nil {
+		expiryEpoch = int(*resp.Blob.EndEpoch)
+	}
+	for i, patch := range patches {
+		patchID, ok := resp.PatchID(patch.Identifier)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: no patch ID returned for %s\n", patch.Identifier)
+			continue
+		}
+		index.Files[patch.Identifier] = &FileEntry{
+			BlobID:       resp.Blob.BlobID,
+			Size:         sizes[patch.Identifier],
+			ModTime:      time.Now(),
+			ExpiryEpoch:  expiryEpoch,
+			OriginalPath: filePaths[i],
+			QuiltPatchID: patchID,
+			ContentType:  contentTypes[patch.Identifier],
+			Tags:         opts.Tags,
+			SuiObjectID:  resp.Blob.SuiObjectID,
+			Deletable:    resp.Blob.Deletable,
+		}
+	}
+
+	if err := saveIndex(index); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to save index: %v\n", err)
+	}
+
+	fmt.Printf("\n%s\n", color.GreenString("🎉 Successfully uploaded quilt to Walrus"))
+	fmt.Printf("  %s %s\n", color.CyanString("Quilt ID:"), color.BlueString(resp.Blob.BlobID))
+	fmt.Printf("  %s %s\n", color.YellowString("Expires:"), color.YellowString("Epoch %d", expiryEpoch))
+	for _, patch := range resp.Patches {
+		fmt.Printf("  %s %s\n", color.MagentaString(patch.Identifier+":"), patch.QuiltPatchID)
+	}
+}
+
+func handleDownload(ctx context.Context, client *backend.WalrusClient, index *FileIndex, fileName, outputPath string, opts backend.DownloadOptions) {
+	// Find file in the upload or S3 transfer index, or treat the argument as
+	// a blob ID and recover the original name and content type from the
+	// blob's attributes
+	var encryption *backend.EncryptionInfo
+	entry, exists := index.Files[fileName]
+	if !exists {
+		if name, transferred := transferredEntry(fileName); transferred != nil {
+			entry = &FileEntry{BlobID: transferred.BlobID, Size: transferred.Size, ContentType: transferred.ContentType, Parts: transferred.Parts, Compression: transferred.Compression}
+			encryption = transferred.Encryption
+			fileName = path.Base(name)
+		} else {
+			info, err := client.GetBlobStatusContext(ctx, fileName)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: File '%s' not found in index\n", fileName)
+				fmt.Println("Use 'walrus-cli list' to see available files")
+				os.Exit(1)
+			}
+			entry = &FileEntry{BlobID: info.BlobID, Size: info.Size, ContentType: info.ContentType}
+			fileName = restoredFileName(info)
+		}
+	}
+
+	// Compressed files are decompressed on the way to disk, unless they are
+	// also encrypted and no key was given
+	if entry.Compression != nil && (encryption == nil || opts.Decrypt != nil) {
+		if opts.Resume || opts.Range != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s is stored %s-compressed and can only be downloaded whole, without --resume or --range\n", fileName, entry.Compression.Codec)
+			os.Exit(1)
+		}
+		opts.Decompress = entry.Compression.Codec
+	}
 
 	// Determine output path
 	if outputPath == "" {
 		outputPath = fileName
+		if opts.Decrypt != nil {
+			outputPath = strings.TrimSuffix(fileName, ".enc")
+		}
 	}
 
-	// Write to file
-	if err := os.WriteFile(outputPath, data, 0644); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+	// Work out how many bytes we expect so the progress bar is meaningful;
+	// it counts stored bytes, which are compressed and encrypted ones if the
+	// file was, and chunked files count the stored bytes of their parts
+	total := entry.Size
+	if entry.Compression != nil {
+		total = entry.Compression.Size
+	}
+	if encryption != nil {
+		total = backend.EncryptedSize(total)
+	}
+	if len(entry.Parts) > 0 {
+		total = 0
+		for _, part := range entry.Parts {
+			total += part.Size
+		}
+	}
+	if opts.Range != nil {
+		end := opts.Range.End
+		if end < 0 || end >= total {
+			end = total - 1
+		}
+		total = end - opts.Range.Start + 1
+	}
+
+	var resumed int64
+	if opts.Resume {
+		if stat, err := os.Stat(backend.PartialPath(outputPath)); err == nil {
+			resumed = stat.Size()
+		}
+	}
+
+	fmt.Printf("Downloading %s (Blob ID: %s)\n", fileName, entry.BlobID[:12]+"...")
+	if entry.ContentType != "" {
+		fmt.Printf("Content Type: %s\n", entry.ContentType)
+	}
+	if opts.Range != nil {
+		fmt.Printf("Range: bytes %s\n", opts.Range)
+	}
+	if len(entry.Parts) > 0 {
+		fmt.Printf("Parts: %d (reassembled and checked against their hashes)\n", len(entry.Parts))
+	}
+	if resumed > 0 {
+		fmt.Printf("Resuming from %s\n", formatBytes(resumed))
+	}
+	switch {
+	case opts.Decrypt != nil:
+		fmt.Printf("Decrypting with %s\n", opts.Decrypt.Source())
+	case encryption != nil:
+		fmt.Printf("Encrypted with key %s (%s); add --decrypt to get the plaintext\n", encryption.KeyID, encryption.KeySource)
+	}
+	if opts.Decompress != "" {
+		fmt.Printf("Decompressing %s (%s stored)\n", opts.Decompress, formatBytes(entry.Compression.Size))
+	}
+
+	if total <= 0 {
+		total = -1 // Size unknown; show a spinner instead
+	}
+	bar := progressbar.DefaultBytes(total, "Downloading")
+	bar.Add64(resumed)
+	opts.Progress = bar
+
+	written, err := downloadEntry(ctx, client, entry, outputPath, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nError downloading: %v\n", err)
+		if opts.Decrypt == nil && opts.Decompress == "" {
+			fmt.Fprintf(os.Stderr, "Partial data kept in %s; rerun with --resume to continue\n", backend.PartialPath(outputPath))
+		}
 		os.Exit(1)
 	}
+	bar.Finish()
 
-	fmt.Printf("✓ Saved to: %s (%s)\n", outputPath, formatBytes(int64(len(data))))
+	fmt.Printf("✓ Saved to: %s (%s)\n", outputPath, formatBytes(written))
+}
+
+// downloadEntry downloads an indexed file to outputPath. Files stored in a quilt
+// are fetched by patch, chunked files part by part.
+func downloadEntry(ctx context.Context, client *backend.WalrusClient, entry *FileEntry, outputPath string, opts backend.DownloadOptions) (int64, error) {
+	switch {
+	case entry.QuiltPatchID != "":
+		return client.DownloadQuiltPatchToFileContext(ctx, entry.QuiltPatchID, outputPath, opts)
+	case len(entry.Parts) > 0:
+		return client.DownloadChunkedToFileContext(ctx, entry.BlobID, outputPath, opts)
+	default:
+		return client.DownloadBlobToFileContext(ctx, entry.BlobID, outputPath, opts)
+	}
 }
 
 func handleList(index *FileIndex) {
@@ -273,6 +823,19 @@ func handleInfo(index *FileIndex, nameOrID string) {
 		fmt.Printf("Name: %s\n", nameOrID)
 		fmt.Printf("Size: %s\n", formatBytes(entry.Size))
 		fmt.Printf("Blob ID: %s\n", entry.BlobID)
+		if entry.QuiltPatchID != "" {
+			fmt.Printf("Quilt Patch ID: %s\n", entry.QuiltPatchID)
+		}
+		if len(entry.Parts) > 0 {
+			fmt.Printf("Parts: %d (Blob ID is the manifest)\n", len(entry.Parts))
+		}
+		if entry.Compression != nil {
+			fmt.Printf("Compressed: %s\n", compressionSummary(entry.Compression))
+		}
+		if entry.ContentType != "" {
+			fmt.Printf("Content Type: %s\n", entry.ContentType)
+		}
+		printTags(entry.Tags)
 		fmt.Printf("Uploaded: %s\n", entry.ModTime.Format("2006-01-02 15:04:05"))
 		fmt.Printf("Expires: Epoch %d\n", entry.ExpiryEpoch)
 		if entry.BlobID != "" {
@@ -290,6 +853,10 @@ func handleInfo(index *FileIndex, nameOrID string) {
 			fmt.Printf("Blob ID: %s\n", entry.BlobID)
 			fmt.Printf("File Name: %s\n", name)
 			fmt.Printf("Size: %s\n", formatBytes(entry.Size))
+			if entry.ContentType != "" {
+				fmt.Printf("Content Type: %s\n", entry.ContentType)
+			}
+			printTags(entry.Tags)
 			fmt.Printf("Uploaded: %s\n", entry.ModTime.Format("2006-01-02 15:04:05"))
 			fmt.Printf("Expires: Epoch %d\n", entry.ExpiryEpoch)
 			fmt.Printf("\nWalruscan URL:\n")
@@ -298,11 +865,86 @@ func handleInfo(index *FileIndex, nameOrID string) {
 		}
 	}
 
+	if name, entry := transferredEntry(nameOrID); entry != nil {
+		handleTransferredInfo(name, entry)
+		return
+	}
+
 	fmt.Printf("Error: File or blob ID '%s' not found in index\n", nameOrID)
 	fmt.Println("Use 'walrus-cli list' to see available files")
 }
 
-func handleStatus(config *backend.Config) {
+// handleTransferredInfo shows a file copied from S3, with its provenance
+func handleTransferredInfo(name string, entry *backend.SimpleFileEntry) {
+	fmt.Printf("File Information\n")
+	fmt.Printf("================\n")
+	fmt.Printf("Name: %s\n", name)
+	fmt.Printf("Size: %s\n", formatBytes(entry.Size))
+	fmt.Printf("Blob ID: %s\n", entry.BlobID)
+	if entry.ContentType != "" {
+		fmt.Printf("Content Type: %s\n", entry.ContentType)
+	}
+	printTags(entry.Tags)
+	if entry.Checksums != nil {
+		fmt.Printf("SHA-256: %s\n", entry.Checksums.SHA256)
+	}
+	if entry.Compression != nil {
+		fmt.Printf("Compressed: %s\n", compressionSummary(entry.Compression))
+	}
+	fmt.Printf("Copied: %s\n", entry.ModTime.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Expires: Epoch %d\n", entry.ExpiryEpoch)
+
+	if source := entry.Source; source != nil {
+		fmt.Printf("\nSource: s3://%s/%s\n", source.Bucket, source.Key)
+		if source.VersionID != "" {
+			fmt.Printf("Version ID: %s\n", source.VersionID)
+		}
+		if source.ETag != "" {
+			fmt.Printf("ETag: %s\n", source.ETag)
+		}
+		if !source.LastModified.IsZero() {
+			fmt.Printf("Last Modified: %s\n", source.LastModified.Format("2006-01-02 15:04:05"))
+		}
+		if source.CacheControl != "" {
+			fmt.Printf("Cache Control: %s\n", source.CacheControl)
+		}
+		for _, key := range sortedKeys(source.Metadata) {
+			fmt.Printf("Metadata: %s=%s\n", key, source.Metadata[key])
+		}
+		if source.DeletedAt != nil {
+			fmt.Printf("Deleted upstream: %s\n", source.DeletedAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	fmt.Printf("\nWalruscan URL:\n")
+	fmt.Printf("https://walruscan.com/testnet/blob/%s\n", entry.BlobID)
+}
+
+// transferredEntry looks a name or blob ID up in the S3 transfer index
+func transferredEntry(nameOrID string) (string, *backend.SimpleFileEntry) {
+	simpleFS := backend.NewSimpleFs("", "")
+	if err := simpleFS.LoadIndex(); err != nil {
+		return "", nil
+	}
+
+	files := simpleFS.List()
+	if entry, exists := files[nameOrID]; exists {
+		return nameOrID, entry
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if files[name].BlobID == nameOrID {
+			return name, files[name]
+		}
+	}
+	return "", nil
+}
+
+func handleStatus(ctx context.Context, config *backend.Config) {
 	fmt.Println("Walrus CLI Configuration Status")
 	fmt.Println("===============================")
 	fmt.Println()
@@ -322,6 +964,21 @@ func handleStatus(config *backend.Config) {
 	fmt.Printf("Publisher:     %s\n", config.Walrus.PublisherURL)
 	fmt.Printf("Default Epochs: %d\n", config.Walrus.Epochs)
 
+	fmt.Println()
+	fmt.Println("Endpoint health:")
+	aggregators, publishers := probeEndpointHealth(ctx, config)
+	for _, status := range append(aggregators, publishers...) {
+		line := fmt.Sprintf("  %-50s %s", status.URL, status.Health)
+		if status.Latency > 0 {
+			line += fmt.Sprintf(" (%s)", status.Latency.Round(time.Millisecond))
+		}
+		if status.LastError != "" {
+			line += " - " + status.LastError
+		}
+		fmt.Println(line)
+	}
+	fmt.Println()
+
 	if config.Walrus.Wallet.PrivateKey != "" {
 		fmt.Printf("Wallet:        Configured (%s...)\n", config.Walrus.Wallet.PrivateKey[:15])
 		fmt.Printf("Status:        ✅ Ready for uploads\n")
@@ -388,6 +1045,9 @@ func handleCost(client *backend.WalrusClient, size int64, epochs int) {
 	fmt.Printf("File Size: %s\n", formatBytes(size))
 	fmt.Printf("Duration: %d epochs\n", epochs)
 	fmt.Printf("Estimated Cost: %s\n", formatWALWithUSD(cost))
+	if stats := dedupStats(); stats != "" {
+		fmt.Printf("Dedup: %s\n", stats)
+	}
 }
 
 func handleInit() {
@@ -458,6 +1118,74 @@ func formatBytes(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+// restoredFileName picks a local name for a blob downloaded by ID: the file
+// name stored with it, or the blob ID with an extension matching its type
+func restoredFileName(info *backend.BlobInfo) string {
+	if info.Identifier != "" {
+		return filepath.Base(info.Identifier)
+	}
+	if exts, _ := mime.ExtensionsByType(info.ContentType); len(exts) > 0 {
+		return info.BlobID + exts[0]
+	}
+	return info.BlobID
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// detectContentType guesses a file's content type from its extension, falling
+// back to sniffing the first bytes. The read position of file is unchanged.
+func detectContentType(file *os.File, name string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType
+	}
+	head := make([]byte, 512)
+	n, _ := file.ReadAt(head, 0)
+	return http.DetectContentType(head[:n])
+}
+
+// detectFileContentType is detectContentType for a path
+func detectFileContentType(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer file.Close()
+	return detectContentType(file, path)
+}
+
+// printTags lists tags in key order
+func printTags(tags map[string]string) {
+	for _, key := range sortedKeys(tags) {
+		fmt.Printf("Tag: %s=%s\n", key, tags[key])
+	}
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatRelayTip renders an upload relay tip, which is paid in SUI
+func formatRelayTip(mist uint64) string {
+	if mist == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%.9f SUI (%d MIST)", float64(mist)/1e9, mist)
+}
+
 func formatWAL(frost int64) string {
 	wal := float64(frost) / 1_000_000_000
 
@@ -498,9 +1226,45 @@ func printUsage() {
 	fmt.Println("  upload <file> [flags]    Upload a file to Walrus")
 	fmt.Println("    --epochs <n>           Number of epochs to store (default: 5)")
 	fmt.Println("    --dry-run              Estimate cost without uploading")
+	fmt.Println("    --quilt <files...>     Store several small files as one quilt")
+	fmt.Println("    --dedup                Skip chunks already stored by earlier uploads")
+	fmt.Println("    --compress <codec>     Compress before storing (zstd or gzip)")
+	fmt.Println("    -r <directory>         Upload every file below a directory")
+	fmt.Println("    --include <pattern>    With -r, only upload matching paths (repeatable)")
+	fmt.Println("    --exclude <pattern>    With -r, skip matching paths (repeatable)")
+	fmt.Println("    --parallel <n>         With -r, files uploaded at once (default: 3)")
+	fmt.Println("    --deletable            Allow deleting the blob before it expires")
+	fmt.Println("    --tag <key=value>      Attach a tag (repeatable)")
+	fmt.Println("    --content-type <type>  Content type stored with the blob")
+	fmt.Println("    --upload-relay <url>   Store through an upload relay (\"config\" = configured relay)")
 	fmt.Println()
 	fmt.Println("  download <name> [flags]  Download a file from Walrus")
 	fmt.Println("    --output <path>        Output file path")
+	fmt.Println("    --resume               Resume a partial download")
+	fmt.Println("    --range <start-end>    Fetch only a byte range")
+	fmt.Println("    -r <prefix>            Download every file below a prefix, rebuilding the tree")
+	fmt.Println("    --parallel <n>         With -r, files downloaded at once (default: 3)")
+	fmt.Println()
+	fmt.Println("  sync <dir> <prefix>      Upload new and changed files under a prefix")
+	fmt.Println("    --delete               Also delete indexed files gone from the directory")
+	fmt.Println("    --force                Skip the confirmation prompt for deletions")
+	fmt.Println("    --dry-run              Show the plan without changing anything")
+	fmt.Println("    --epochs <n>           Number of epochs to store (default: 5)")
+	fmt.Println("    --include <pattern>    Only sync matching paths (repeatable)")
+	fmt.Println("    --exclude <pattern>    Skip matching paths (repeatable)")
+	fmt.Println("    --parallel <n>         Files uploaded at once (default: 3)")
+	fmt.Println()
+	fmt.Println("  delete <name/blob-id>    Delete a deletable blob and its index entry")
+	fmt.Println("    --force                Skip the confirmation prompt")
+	fmt.Println()
+	fmt.Println("  extend <name/blob-id>    Extend how long a blob is stored")
+	fmt.Println("    --epochs <n>           Epochs to extend by (default: 5)")
+	fmt.Println("    --force                Skip the confirmation prompt")
+	fmt.Println()
+	fmt.Println("  renew [flags]            Extend every blob that expires soon")
+	fmt.Println("    --expiring-within <n>  Renew blobs expiring within n epochs")
+	fmt.Println("    --epochs <n>           Epochs to extend each blob by (default: 5)")
+	fmt.Println("    --force                Skip the confirmation prompt")
 	fmt.Println()
 	fmt.Println("  list                     List stored files")
 	fmt.Println()
@@ -0,0 +1,586 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/justmert/walrus-cli/backend"
+)
+
+// entriesForBlob resolves a file name or blob ID to the index entries that
+// share its blob. Files stored in one quilt all come back together, since
+// they live and die with the same blob.
+func entriesForBlob(index *FileIndex, nameOrID string) []string {
+	blobID := nameOrID
+	if entry, exists := index.Files[nameOrID]; exists {
+		blobID = entry.BlobID
+	}
+
+	var names []string
+	for name, entry := range index.Files {
+		if entry.BlobID == blobID {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errDeletableNotOwned refuses --deletable for blobs the publisher would
+// keep: only the owner of a blob object can delete it
+var errDeletableNotOwned = errors.New("--deletable needs wallet.address configured or an upload relay, or the publisher keeps the blob and only it can delete it")
+
+// confirm asks a yes/no question, defaulting to no
+func confirm(message string) (bool, error) {
+	var ok bool
+	prompt := &survey.Confirm{Message: message, Default: false}
+	if err := survey.AskOne(prompt, &ok); err != nil {
+		return false, fmt.Errorf("confirmation needed (rerun with --force to skip it): %w", err)
+	}
+	return ok, nil
+}
+
+// handleDelete deletes a blob on-chain when it was stored as deletable and
+// removes it from the local indexes. Permanent blobs cannot be deleted early,
+// so for those only the index entries go.
+func handleDelete(ctx context.Context, client *backend.WalrusClient, index *FileIndex, nameOrID string, force bool) error {
+	names := entriesForBlob(index, nameOrID)
+
+	// S3 transfers keep their own index
+	simpleFS := backend.NewSimpleFs(client.AggregatorURL, client.PublisherURL)
+	simpleLoaded := simpleFS.LoadIndex() == nil
+
+	blobID, objectID := nameOrID, ""
+	deletable, known := false, len(names) > 0
+	expiry := 0
+	var parts []backend.ChunkPart
+	if len(names) > 0 {
+		entry := index.Files[names[0]]
+		blobID, objectID, deletable, expiry = entry.BlobID, entry.SuiObjectID, entry.Deletable, entry.ExpiryEpoch
+		parts = entry.Parts
+	} else if simpleLoaded {
+		for name, entry := range simpleFS.List() {
+			if name == nameOrID || entry.BlobID == nameOrID {
+				blobID, objectID, deletable, expiry = entry.BlobID, entry.SuiObjectID, entry.Deletable, entry.ExpiryEpoch
+				parts = entry.Parts
+				known = true
+				break
+			}
+		}
+	}
+	if !known {
+		// Nothing says the blob is deletable or that the wallet owns it, so
+		// it is only tried on-chain when asked for
+		if !force {
+			fmt.Printf("%s is not in the local index.\n", nameOrID)
+			fmt.Println("Rerun with --force to try deleting it on-chain with the configured wallet.")
+			return nil
+		}
+		deletable = true
+	}
+
+	fmt.Printf("Blob ID: %s\n", blobID)
+	for _, name := range names {
+		fmt.Printf("File: %s (%s)\n", name, formatBytes(index.Files[name].Size))
+	}
+	if len(names) > 1 {
+		fmt.Printf("%s\n", yellow(fmt.Sprintf("These %d files share one quilt blob and are all removed together.", len(names))))
+	}
+	// Chunks shared with other deduplicated files stay stored
+	var simpleIndex *backend.SimpleFs
+	if simpleLoaded {
+		simpleIndex = simpleFS
+	}
+	parts, kept := unsharedParts(parts, blobID, index, simpleIndex)
+	if len(parts) > 0 {
+		fmt.Printf("Parts: %d (removed with the manifest)\n", len(parts))
+	}
+	if kept > 0 {
+		fmt.Printf("Shared chunks: %d (kept for other files)\n", kept)
+	}
+	if !deletable {
+		fmt.Printf("%s\n", yellow(fmt.Sprintf("This blob was not stored as deletable and stays on Walrus until epoch %d.", expiry)))
+		fmt.Println("Only the local index entry will be removed.")
+	}
+
+	if !force {
+		ok, err := confirm("Delete this blob?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Cancelled")
+			return nil
+		}
+	}
+
+	if deletable {
+		if err := deleteStoredBlob(ctx, client, blobID, objectID, parts); err != nil {
+			return err
+		}
+		fmt.Println(green("✓ Blob deleted on-chain and storage reclaimed"))
+	}
+
+	for _, name := range names {
+		delete(index.Files, name)
+	}
+	if len(names) > 0 {
+		if err := saveIndex(index); err != nil {
+			return fmt.Errorf("saving index: %w", err)
+		}
+	}
+
+	if simpleLoaded {
+		if removed := simpleFS.RemoveBlob(blobID); len(removed) > 0 {
+			if err := simpleFS.SaveIndex(); err != nil {
+				return fmt.Errorf("saving transfer index: %w", err)
+			}
+			names = append(names, removed...)
+		}
+	}
+
+	fmt.Printf("✓ Removed %d index entr%s\n", len(names), pluralY(len(names)))
+	return nil
+}
+
+// deleteStoredBlob deletes a deletable blob on-chain, along with the parts
+// it is the manifest of, and drops those parts from the chunk store
+func deleteStoredBlob(ctx context.Context, client *backend.WalrusClient, blobID, objectID string, parts []backend.ChunkPart) error {
+	if err := client.DeleteBlobContext(ctx, blobID, objectID); err != nil {
+		return err
+	}
+	store, _ := backend.LoadChunkStore(backend.ChunkStorePath())
+	for i, part := range parts {
+		if err := client.DeleteBlobContext(ctx, part.BlobID, part.SuiObjectID); err != nil {
+			return fmt.Errorf("part %d of %d: %w", i+1, len(parts), err)
+		}
+		if store != nil {
+			store.RemoveBlob(part.BlobID)
+		}
+	}
+	if store != nil && len(parts) > 0 {
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("saving chunk store: %w", err)
+		}
+	}
+	return nil
+}
+
+// unsharedParts returns the distinct part blobs that no chunked file other
+// than the one with manifest blobID references, and how many others do
+func unsharedParts(parts []backend.ChunkPart, blobID string, index *FileIndex, simpleFS *backend.SimpleFs) ([]backend.ChunkPart, int) {
+	if len(parts) == 0 {
+		return nil, 0
+	}
+	shared := make(map[string]bool)
+	for _, entry := range index.Files {
+		if entry.BlobID != blobID {
+			for _, part := range entry.Parts {
+				shared[part.BlobID] = true
+			}
+		}
+	}
+	if simpleFS != nil {
+		for _, entry := range simpleFS.List() {
+			if entry.BlobID != blobID {
+				for _, part := range entry.Parts {
+					shared[part.BlobID] = true
+				}
+			}
+		}
+	}
+
+	// A chunk repeated within the file is deleted once
+	var own []backend.ChunkPart
+	kept := 0
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		switch {
+		case seen[part.BlobID]:
+		case shared[part.BlobID]:
+			kept++
+		default:
+			own = append(own, part)
+		}
+		seen[part.BlobID] = true
+	}
+	return own, kept
+}
+
+// storedBlob is one blob as recorded across the local indexes. Files stored
+// in one quilt share a blob, so a blob can carry several names.
+type storedBlob struct {
+	BlobID      string
+	ObjectID    string
+	Size        int64
+	ExpiryEpoch int
+	Names       []string
+}
+
+// indexedBlobs groups the entries of both local indexes by blob ID. The
+// parts of a chunked file are blobs of their own and are listed under the
+// file's name with their part number.
+func indexedBlobs(index *FileIndex, simpleFS *backend.SimpleFs) map[string]*storedBlob {
+	blobs := make(map[string]*storedBlob)
+	add := func(name, blobID, objectID string, size int64, expiry int) {
+		blob, exists := blobs[blobID]
+		if !exists {
+			blob = &storedBlob{BlobID: blobID, ExpiryEpoch: expiry}
+			blobs[blobID] = blob
+		}
+		if blob.ObjectID == "" {
+			blob.ObjectID = objectID
+		}
+		if expiry > blob.ExpiryEpoch {
+			blob.ExpiryEpoch = expiry
+		}
+		blob.Size += size
+		blob.Names = append(blob.Names, name)
+	}
+	addFile := func(name, blobID, objectID string, size int64, expiry int, parts []backend.ChunkPart) {
+		if len(parts) == 0 {
+			add(name, blobID, objectID, size, expiry)
+			return
+		}
+		// The manifest itself is small; the wallet reports its real size
+		add(name, blobID, objectID, 0, expiry)
+		for i, part := range parts {
+			// Deduplicated files share chunks; count each blob's size once
+			size := part.Size
+			if _, exists := blobs[part.BlobID]; exists {
+				size = 0
+			}
+			add(partName(name, i, len(parts)), part.BlobID, part.SuiObjectID, size, expiry)
+		}
+	}
+
+	for name, entry := range index.Files {
+		addFile(name, entry.BlobID, entry.SuiObjectID, entry.Size, entry.ExpiryEpoch, entry.Parts)
+	}
+	if simpleFS != nil {
+		for name, entry := range simpleFS.List() {
+			addFile(name, entry.BlobID, entry.SuiObjectID, entry.Size, entry.ExpiryEpoch, entry.Parts)
+		}
+	}
+	for _, blob := range blobs {
+		sort.Strings(blob.Names)
+	}
+	return blobs
+}
+
+// partName names part i of a chunked file in lifecycle listings
+func partName(name string, i, count int) string {
+	return fmt.Sprintf("%s (part %d/%d)", name, i+1, count)
+}
+
+// loadSimpleFs loads the S3 transfer index, returning nil if there is none
+func loadSimpleFs(client *backend.WalrusClient) *backend.SimpleFs {
+	simpleFS := backend.NewSimpleFs(client.AggregatorURL, client.PublisherURL)
+	if err := simpleFS.LoadIndex(); err != nil {
+		return nil
+	}
+	return simpleFS
+}
+
+// applyOwnedBlobs fills in object IDs, sizes and expiry epochs from the blob
+// objects the wallet owns. The chain is authoritative for expiry; when the
+// wallet holds several objects for one blob, the longest-lived one wins.
+func applyOwnedBlobs(blobs []*storedBlob, owned []backend.OwnedBlob) {
+	for _, blob := range blobs {
+		var best *backend.OwnedBlob
+		for i := range owned {
+			obj := &owned[i]
+			if obj.BlobID != blob.BlobID {
+				continue
+			}
+			if obj.ObjectID == blob.ObjectID {
+				best = obj
+				break
+			}
+			if best == nil || obj.EndEpoch > best.EndEpoch {
+				best = obj
+			}
+		}
+		if best == nil {
+			continue
+		}
+		blob.ObjectID = best.ObjectID
+		if best.EndEpoch > 0 {
+			blob.ExpiryEpoch = best.EndEpoch
+		}
+		if blob.Size == 0 {
+			blob.Size = best.Size
+		}
+	}
+}
+
+// recordExtension writes a blob's new expiry into both indexes
+func recordExtension(index *FileIndex, simpleFS *backend.SimpleFs, blob *storedBlob) {
+	for _, name := range blob.Names {
+		if entry, exists := index.Files[name]; exists && entry.BlobID == blob.BlobID {
+			entry.ExpiryEpoch = blob.ExpiryEpoch
+			if entry.SuiObjectID == "" {
+				entry.SuiObjectID = blob.ObjectID
+			}
+			index.Files[name] = entry
+		}
+	}
+	if simpleFS != nil {
+		simpleFS.SetBlobExpiry(blob.BlobID, blob.ObjectID, blob.ExpiryEpoch)
+	}
+}
+
+// saveIndexes persists both indexes after their expiry epochs changed
+func saveIndexes(index *FileIndex, simpleFS *backend.SimpleFs) error {
+	if err := saveIndex(index); err != nil {
+		return fmt.Errorf("saving index: %w", err)
+	}
+	if simpleFS != nil {
+		if err := simpleFS.SaveIndex(); err != nil {
+			return fmt.Errorf("saving transfer index: %w", err)
+		}
+	}
+	return nil
+}
+
+// handleExtend extends a single blob's lifetime by epochs after showing what
+// it will cost
+func handleExtend(ctx context.Context, client *backend.WalrusClient, index *FileIndex, nameOrID string, epochs int, force bool) error {
+	if epochs <= 0 {
+		return fmt.Errorf("--epochs must be positive")
+	}
+
+	simpleFS := loadSimpleFs(client)
+	blobs := indexedBlobs(index, simpleFS)
+
+	blobID := nameOrID
+	var parts []backend.ChunkPart
+	if entry, exists := index.Files[nameOrID]; exists {
+		blobID, parts = entry.BlobID, entry.Parts
+	} else if simpleFS != nil {
+		if entry, exists := simpleFS.List()[nameOrID]; exists {
+			blobID, parts = entry.BlobID, entry.Parts
+		}
+	}
+	blob, exists := blobs[blobID]
+	if !exists {
+		blob = &storedBlob{BlobID: blobID}
+	}
+
+	// A chunked file is only readable while its manifest and every part are
+	targets := []*storedBlob{blob}
+	seen := map[string]bool{blob.BlobID: true}
+	for _, part := range parts {
+		if partBlob, exists := blobs[part.BlobID]; exists && !seen[part.BlobID] {
+			targets = append(targets, partBlob)
+			seen[part.BlobID] = true
+		}
+	}
+
+	// Older entries predate recording the object ID; ask the wallet for it
+	var unresolved bool
+	for _, target := range targets {
+		if target.ObjectID == "" || target.ExpiryEpoch == 0 {
+			unresolved = true
+		}
+	}
+	if unresolved {
+		owned, err := client.OwnedBlobs(ctx)
+		if err != nil {
+			return err
+		}
+		applyOwnedBlobs(targets, owned)
+		for _, target := range targets {
+			if target.ObjectID == "" {
+				return fmt.Errorf("no blob object for %s is owned by the configured wallet", target.BlobID)
+			}
+		}
+	}
+
+	var cost int64
+	for _, target := range targets {
+		targetCost, err := client.EstimateStorageCost(target.Size, epochs)
+		if err != nil {
+			return fmt.Errorf("estimating cost: %w", err)
+		}
+		cost += targetCost
+	}
+
+	fmt.Printf("Blob ID: %s\n", blob.BlobID)
+	for _, name := range blob.Names {
+		fmt.Printf("File: %s\n", name)
+	}
+	if len(parts) > 0 {
+		fmt.Printf("Parts:      %d (extended with the manifest)\n", len(parts))
+	}
+	fmt.Printf("Expires:    Epoch %d → %s\n", blob.ExpiryEpoch, green(fmt.Sprintf("Epoch %d", blob.ExpiryEpoch+epochs)))
+	fmt.Printf("Cost:       %s\n", green(formatWALWithUSD(cost)))
+
+	if !force {
+		message := fmt.Sprintf("Extend this blob by %d epoch%s?", epochs, pluralS(epochs))
+		if len(targets) > 1 {
+			message = fmt.Sprintf("Extend these %d blobs by %d epoch%s?", len(targets), epochs, pluralS(epochs))
+		}
+		ok, err := confirm(message)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Cancelled")
+			return nil
+		}
+	}
+
+	for _, target := range targets {
+		if err := client.ExtendBlobContext(ctx, target.ObjectID, epochs); err != nil {
+			// Keep the record of the blobs that were extended before this one
+			if saveErr := saveIndexes(index, simpleFS); saveErr != nil {
+				fmt.Printf("%s %v\n", red("✗"), saveErr)
+			}
+			return err
+		}
+		target.ExpiryEpoch += epochs
+		recordExtension(index, simpleFS, target)
+	}
+	fmt.Println(green(fmt.Sprintf("✓ Blob now expires at epoch %d", blob.ExpiryEpoch)))
+
+	return saveIndexes(index, simpleFS)
+}
+
+// handleRenew extends every indexed blob that expires within the next
+// `within` epochs
+func handleRenew(ctx context.Context, client *backend.WalrusClient, index *FileIndex, within, epochs int, force bool) error {
+	if within <= 0 {
+		return fmt.Errorf("--expiring-within must be positive")
+	}
+	if epochs <= 0 {
+		return fmt.Errorf("--epochs must be positive")
+	}
+
+	current, err := client.CurrentEpoch(ctx)
+	if err != nil {
+		return err
+	}
+	owned, err := client.OwnedBlobs(ctx)
+	if err != nil {
+		return err
+	}
+
+	simpleFS := loadSimpleFs(client)
+	var all []*storedBlob
+	for _, blob := range indexedBlobs(index, simpleFS) {
+		all = append(all, blob)
+	}
+	applyOwnedBlobs(all, owned)
+
+	var due []*storedBlob
+	var expired, notOwned int
+	for _, blob := range all {
+		switch {
+		case blob.ExpiryEpoch == 0:
+			// Unknown expiry and no blob object in the wallet to ask
+		case blob.ExpiryEpoch <= current:
+			expired++
+		case blob.ExpiryEpoch > current+within:
+		case blob.ObjectID == "":
+			notOwned++
+		default:
+			due = append(due, blob)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].ExpiryEpoch != due[j].ExpiryEpoch {
+			return due[i].ExpiryEpoch < due[j].ExpiryEpoch
+		}
+		return due[i].Names[0] < due[j].Names[0]
+	})
+
+	fmt.Printf("Current epoch: %d\n", current)
+	if expired > 0 {
+		fmt.Printf("%s\n", yellow(fmt.Sprintf("%d blob%s already expired and can no longer be extended.", expired, pluralS(expired))))
+	}
+	if notOwned > 0 {
+		fmt.Printf("%s\n", yellow(fmt.Sprintf("%d expiring blob%s not owned by the configured wallet and will be skipped.", notOwned, pluralS(notOwned))))
+	}
+	if len(due) == 0 {
+		fmt.Printf("No blobs expire within %d epoch%s\n", within, pluralS(within))
+		return nil
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tBLOB ID\tEXPIRY\tNEW EXPIRY\tCOST")
+	for _, blob := range due {
+		cost, err := client.EstimateStorageCost(blob.Size, epochs)
+		if err != nil {
+			return fmt.Errorf("estimating cost: %w", err)
+		}
+		total += cost
+
+		blobIDDisplay := blob.BlobID
+		if len(blobIDDisplay) > 12 {
+			blobIDDisplay = blobIDDisplay[:12] + "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\tEpoch %d\tEpoch %d\t%s WAL\n",
+			strings.Join(blob.Names, ", "), blobIDDisplay, blob.ExpiryEpoch, blob.ExpiryEpoch+epochs, formatWAL(cost))
+	}
+	w.Flush()
+	fmt.Printf("Total cost: %s\n", green(formatWALWithUSD(total)))
+
+	if !force {
+		ok, err := confirm(fmt.Sprintf("Extend %d blob%s by %d epoch%s?", len(due), pluralS(len(due)), epochs, pluralS(epochs)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Cancelled")
+			return nil
+		}
+	}
+
+	failed := 0
+	for _, blob := range due {
+		if err := client.ExtendBlobContext(ctx, blob.ObjectID, epochs); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			fmt.Printf("%s %s: %v\n", red("✗"), blob.Names[0], err)
+			failed++
+			continue
+		}
+		blob.ExpiryEpoch += epochs
+		recordExtension(index, simpleFS, blob)
+		fmt.Printf("%s %s now expires at epoch %d\n", green("✓"), strings.Join(blob.Names, ", "), blob.ExpiryEpoch)
+	}
+
+	if err := saveIndexes(index, simpleFS); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d blobs could not be extended", failed, len(due))
+	}
+	return nil
+}
+
+func pluralS(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+func pluralY(n int) string {
+	if n == 1 {
+		return "y"
+	}
+	return "ies"
+}
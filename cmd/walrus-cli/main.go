@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/fatih/color"
 )
@@ -32,10 +35,14 @@ func main() {
 		}
 	}
 
+	// Ctrl+C cancels in-flight uploads, downloads and transfers
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	if useModern {
 		// Use modern Cobra-based CLI
 		rootCmd := createRootCmd()
-		if err := rootCmd.Execute(); err != nil {
+		if err := rootCmd.ExecuteContext(ctx); err != nil {
 			color.Red("Error: %v", err)
 			os.Exit(1)
 		}
@@ -51,7 +58,7 @@ func main() {
 		os.Args = newArgs
 
 		// Call original main function
-		mainLegacy()
+		mainLegacy(ctx)
 	}
 }
 
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
@@ -308,8 +310,40 @@ func getNetworkDisplay(network string) string {
 	}
 }
 
+// probeEndpointHealth checks every configured aggregator and publisher and
+// returns their health snapshots
+func probeEndpointHealth(ctx context.Context, config *backend.Config) (aggregators, publishers []backend.EndpointStatus) {
+	client := newWalrusClient(config)
+	ctx, cancel := context.WithTimeout(ctx, endpointProbeTimeout)
+	defer cancel()
+	client.ProbeEndpoints(ctx)
+	return client.Aggregators.Status(), client.Publishers.Status()
+}
+
+// endpointProbeTimeout bounds how long status waits on a slow endpoint
+const endpointProbeTimeout = 5 * time.Second
+
+func formatEndpointHealth(status backend.EndpointStatus) string {
+	var health string
+	switch {
+	case status.State == backend.CircuitClosed && status.Failures == 0:
+		health = green(status.Health)
+	case status.State != backend.CircuitOpen:
+		health = yellow(status.Health)
+	default:
+		health = red(status.Health)
+	}
+	if status.Latency > 0 {
+		health += fmt.Sprintf(" (%s)", status.Latency.Round(time.Millisecond))
+	}
+	if status.LastError != "" {
+		health += " - " + status.LastError
+	}
+	return health
+}
+
 // ModernStatusDisplay shows colorized status information
-func ModernStatusDisplay(config *backend.Config) {
+func ModernStatusDisplay(ctx context.Context, config *backend.Config) {
 	fmt.Println()
 	fmt.Println(cyanBold("Walrus CLI Status"))
 	fmt.Println(strings.Repeat("=", 25))
@@ -331,6 +365,17 @@ func ModernStatusDisplay(config *backend.Config) {
 	fmt.Printf("Publisher:      %s\n", config.Walrus.PublisherURL)
 	fmt.Printf("Default Epochs: %d\n", config.Walrus.Epochs)
 
+	// Endpoint health
+	fmt.Println()
+	fmt.Println(blueBold("Endpoint Health"))
+	aggregators, publishers := probeEndpointHealth(ctx, config)
+	for _, status := range aggregators {
+		fmt.Printf("Aggregator:     %s %s\n", status.URL, formatEndpointHealth(status))
+	}
+	for _, status := range publishers {
+		fmt.Printf("Publisher:      %s %s\n", status.URL, formatEndpointHealth(status))
+	}
+
 	// Wallet status
 	fmt.Println()
 	fmt.Println(yellowBold("Wallet Status"))
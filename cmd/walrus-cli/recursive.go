@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/justmert/walrus-cli/backend"
+	"github.com/schollz/progressbar/v3"
+)
+
+// treeFile is a file found under a directory being uploaded
+type treeFile struct {
+	path    string // On disk
+	name    string // In the index: a prefix and the path below the directory
+	size    int64
+	modTime time.Time
+	sha256  string // Set once the file has been hashed
+}
+
+// treeName returns the prefix a recursive upload of root indexes its files
+// under: the directory's own name, so that trees uploaded from different
+// places don't collide
+func treeName(root string) (string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	base := filepath.Base(abs)
+	if base == string(filepath.Separator) || base == "." {
+		return "", nil
+	}
+	return base, nil
+}
+
+// walkTree lists the regular files under root whose path below root passes
+// the include and exclude globs, naming each one prefix/path
+func walkTree(root, prefix string, include, exclude []string) ([]treeFile, error) {
+	var files []treeFile
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Symlinks and other special files are left out
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !backend.MatchesPatterns(rel, include, exclude) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, treeFile{
+			path:    p,
+			name:    path.Join(prefix, rel),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		return nil
+	})
+	return files, err
+}
+
+// hashFile returns the hex SHA-256 of the file at path
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// handleRecursiveUpload uploads every file under a directory, several at a
+// time, and indexes each one under its path
+func handleRecursiveUpload(ctx context.Context, client *backend.WalrusClient, index *FileIndex, root string, opts uploadOptions) {
+	stat, err := os.Stat(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading directory: %v\n", err)
+		os.Exit(1)
+	}
+	if !stat.IsDir() {
+		fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", root)
+		os.Exit(1)
+	}
+
+	prefix, err := treeName(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading directory: %v\n", err)
+		os.Exit(1)
+	}
+	files, err := walkTree(root, prefix, opts.Include, opts.Exclude)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading directory: %v\n", err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Println("No files match the specified criteria")
+		return
+	}
+
+	var totalSize int64
+	for _, f := range files {
+		totalSize += f.size
+	}
+	fmt.Printf("Directory: %s\n", root)
+	fmt.Printf("Files: %d (%s)\n", len(files), formatBytes(totalSize))
+	printTags(opts.Tags)
+	fmt.Printf("Epochs: %d\n", opts.Epochs)
+	if opts.Deletable {
+		fmt.Println("Deletable: yes")
+	}
+	printTreeCost(ctx, client, files, opts)
+
+	if opts.DryRun {
+		fmt.Println()
+		for _, f := range files {
+			fmt.Printf("  %s (%s)\n", f.name, formatBytes(f.size))
+		}
+		fmt.Println("\n✓ Dry run complete (no data uploaded)")
+		return
+	}
+
+	fmt.Println()
+	progress, err := uploadTree(ctx, client, index, files, opts)
+	if !printTaskResults(progress, err) {
+		os.Exit(1)
+	}
+}
+
+// printTreeCost prints what storing files would cost. Every file is a blob
+// of its own, with its own storage overhead. With compression, each file is
+// compressed once to learn the size it is stored at.
+func printTreeCost(ctx context.Context, client *backend.WalrusClient, files []treeFile, opts uploadOptions) {
+	sizes := make([]int64, len(files))
+	var cost, uncompressedCost, fileBytes, storedBytes int64
+	for i, f := range files {
+		sizes[i] = f.size
+		if opts.Compress != "" {
+			size, err := compressedSize(f, opts.ContentType, opts.Compress)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error compressing %s: %v\n", f.name, err)
+				os.Exit(1)
+			}
+			sizes[i] = size
+		}
+		fileCost, err := client.EstimateStorageCost(sizes[i], opts.Epochs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error estimating cost: %v\n", err)
+			os.Exit(1)
+		}
+		cost += fileCost
+		if opts.Compress != "" {
+			fileCost, err := client.EstimateStorageCost(f.size, opts.Epochs)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error estimating cost: %v\n", err)
+				os.Exit(1)
+			}
+			uncompressedCost += fileCost
+		}
+		fileBytes += f.size
+		storedBytes += sizes[i]
+	}
+	if opts.Compress != "" {
+		fmt.Printf("Compression: %s, %s → %s (already-compressed types are skipped)\n", opts.Compress, formatBytes(fileBytes), formatBytes(storedBytes))
+		fmt.Printf("Estimated Cost: %s (%s uncompressed)\n", formatWALWithUSD(cost), formatWAL(uncompressedCost))
+	} else {
+		fmt.Printf("Estimated Cost: %s\n", formatWALWithUSD(cost))
+	}
+
+	if client.UseUploadRelay {
+		fmt.Printf("Upload Relay: %s\n", client.UploadRelayURL)
+		if err := client.RelayReady(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		tip, err := client.RelayTipConfig(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching relay tip config: %v\n", err)
+			os.Exit(1)
+		}
+		var total uint64
+		for _, size := range sizes {
+			total += tip.TipFor(size, client.ShardCount)
+		}
+		fmt.Printf("Relay Tip: %s\n", formatRelayTip(total))
+	}
+}
+
+// compressedSize returns the size f is stored at when compressed with codec,
+// which is its own size when uploadTreeFile would leave it uncompressed
+func compressedSize(f treeFile, contentType, codec string) (int64, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if contentType == "" {
+		contentType = detectContentType(file, f.name)
+	}
+	if !backend.Compressible(contentType) {
+		return f.size, nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	size, err := backend.CompressedSize(file, codec)
+	if err != nil {
+		return 0, err
+	}
+	return min(size, f.size), nil
+}
+
+// uploadTree uploads files on a TransferManager's workers, indexing each one
+// as soon as it is stored, and saves the index once they are done
+func uploadTree(ctx context.Context, client *backend.WalrusClient, index *FileIndex, files []treeFile, opts uploadOptions) (*backend.TransferProgress, error) {
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	var mu sync.Mutex
+	tm := backend.NewTransferManager(nil, client, nil, opts.Parallel)
+	progress, err := tm.RunTasks(ctx, fmt.Sprintf("Uploading %d files", len(files)), total, len(files), func(i int, bar *progressbar.ProgressBar) backend.TransferResult {
+		f := files[i]
+		result := backend.TransferResult{SourceKey: f.path, TargetName: f.name, Size: f.size, UploadTime: time.Now()}
+		entry, err := uploadTreeFile(ctx, client, f, opts, bar)
+		result.Error = err
+		if entry == nil {
+			return result
+		}
+		result.Success = true
+		result.BlobID = entry.BlobID
+		result.SuiObjectID = entry.SuiObjectID
+
+		mu.Lock()
+		index.Files[f.name] = entry
+		mu.Unlock()
+		return result
+	})
+
+	if err := saveIndex(index); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to save index: %v\n", err)
+	}
+	return progress, err
+}
+
+// printTaskResults prints the summary of a run of tasks, with its warnings
+// and failures, and reports whether every file made it
+func printTaskResults(progress *backend.TransferProgress, err error) bool {
+	if err != nil {
+		fmt.Println(color.YellowString("\n⚠️  Interrupted"))
+	} else {
+		fmt.Println(color.GreenString("\n✅ Complete"))
+	}
+	fmt.Println(progress.GetSummary())
+
+	for _, result := range progress.Results {
+		if result.Success && result.Error != nil {
+			// The file is stored; only the attributes are missing
+			fmt.Println(color.YellowString("⚠️  %s: %v", result.TargetName, result.Error))
+		}
+	}
+	if progress.FailedFiles > 0 {
+		fmt.Println(color.RedString("\n❌ Failed:"))
+		for _, result := range progress.Results {
+			if !result.Success {
+				fmt.Printf("  • %s: %v\n", result.TargetName, result.Error)
+			}
+		}
+	}
+	return err == nil && progress.FailedFiles == 0
+}
+
+// uploadTreeFile uploads one file of a tree and returns its index entry,
+// hashing the file first unless f.sha256 is already set. Like
+// StoreBlobWithOptionsContext, it can return an entry along with an error
+// when only setting the blob's attributes failed.
+func uploadTreeFile(ctx context.Context, client *backend.WalrusClient, f treeFile, opts uploadOptions, bar *progressbar.ProgressBar) (*FileEntry, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = detectContentType(file, f.name)
+	}
+
+	source, uploadSize := file, f.size
+	var compression *backend.CompressionInfo
+	if opts.Compress != "" {
+		var spool *os.File
+		if spool, compression, _, err = compressUpload(file, contentType, f.size, opts.Compress); err != nil {
+			return nil, fmt.Errorf("compressing: %w", err)
+		}
+		if spool != nil {
+			defer func() {
+				spool.Close()
+				os.Remove(spool.Name())
+			}()
+			source, uploadSize = spool, compression.Size
+		}
+	}
+
+	storeOpts := backend.StoreOptions{Epochs: opts.Epochs, Deletable: opts.Deletable}
+	if opts.wantsAttributes(client) {
+		storeOpts.Attributes = backend.BlobAttributes(contentType, path.Base(f.name), opts.Tags)
+		if compression != nil {
+			storeOpts.Attributes[backend.AttributeCompression] = compression.Codec
+		}
+	}
+	sum := f.sha256
+	if sum == "" {
+		if sum, err = hashFile(f.path); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, parts, err := storeFile(ctx, client, source, uploadSize, storeOpts, bar)
+	if resp == nil {
+		return nil, err
+	}
+	// The bar counts file bytes, so make up for the ones compression saved
+	bar.Add64(f.size - uploadSize)
+
+	expiryEpoch := 0
+	if resp.EndEpoch != nil {
+		expiryEpoch = int(*resp.EndEpoch)
+	}
+	return &FileEntry{
+		BlobID:       resp.BlobID,
+		Size:         f.size,
+		ModTime:      time.Now(),
+		FileModTime:  &f.modTime,
+		SHA256:       sum,
+		ExpiryEpoch:  expiryEpoch,
+		OriginalPath: f.path,
+		ContentType:  contentType,
+		Tags:         opts.Tags,
+		SuiObjectID:  resp.SuiObjectID,
+		Deletable:    resp.Deletable,
+		Parts:        parts,
+		Compression:  compression,
+	}, err
+}
+
+// handleRecursiveDownload downloads every indexed file whose name starts with
+// prefix into outputDir, rebuilding the directory tree below the prefix and
+// restoring each file's modification time. opts cannot have a range or a key.
+func handleRecursiveDownload(ctx context.Context, client *backend.WalrusClient, index *FileIndex, prefix, outputDir string, opts backend.DownloadOptions, parallel int) {
+	// "docs" and "docs/" both mean the docs directory, not docs2/
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	if outputDir == "" {
+		outputDir = filepath.FromSlash(strings.TrimSuffix(prefix, "/"))
+		if outputDir == "" {
+			outputDir = "."
+		}
+	}
+
+	type treeDownload struct {
+		name   string
+		target string
+		entry  *FileEntry
+	}
+	var downloads []treeDownload
+	var total int64
+	for _, name := range sortedKeys(index.Files) {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		// Index names come from elsewhere; never write outside outputDir
+		rel := filepath.FromSlash(strings.TrimPrefix(name, prefix))
+		if !filepath.IsLocal(rel) {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s, which would land outside %s\n", name, outputDir)
+			continue
+		}
+		entry := index.Files[name]
+		downloads = append(downloads, treeDownload{name: name, target: filepath.Join(outputDir, rel), entry: entry})
+		total += storedSize(entry)
+	}
+	if len(downloads) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: No files in the index start with '%s'\n", prefix)
+		fmt.Println("Use 'walrus-cli list' to see available files")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Downloading %d files (%s) to %s\n\n", len(downloads), formatBytes(total), outputDir)
+
+	tm := backend.NewTransferManager(nil, client, nil, parallel)
+	progress, err := tm.RunTasks(ctx, fmt.Sprintf("Downloading %d files", len(downloads)), total, len(downloads), func(i int, bar *progressbar.ProgressBar) backend.TransferResult {
+		d := downloads[i]
+		result := backend.TransferResult{SourceKey: d.name, TargetName: d.target, UploadTime: time.Now()}
+		result.Size, result.Error = downloadTreeFile(ctx, client, d.entry, d.target, opts, bar)
+		result.BlobID = d.entry.BlobID
+		result.Success = result.Error == nil
+		return result
+	})
+	if !printTaskResults(progress, err) {
+		fmt.Fprintln(os.Stderr, "Rerun with --resume to continue where this run stopped")
+		os.Exit(1)
+	}
+}
+
+// downloadTreeFile downloads one file of a recursive download to target and
+// gives it back its modification time. When resuming, a file an earlier run
+// finished is left alone.
+func downloadTreeFile(ctx context.Context, client *backend.WalrusClient, entry *FileEntry, target string, opts backend.DownloadOptions, bar *progressbar.ProgressBar) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+
+	if opts.Resume {
+		stat, err := os.Stat(target)
+		if _, partErr := os.Stat(backend.PartialPath(target)); err == nil && stat.Size() == entry.Size && os.IsNotExist(partErr) {
+			bar.Add64(storedSize(entry))
+			return 0, nil
+		}
+	}
+
+	opts.Progress = bar
+	if entry.Compression != nil {
+		// Compressed files can only be fetched whole
+		opts.Decompress = entry.Compression.Codec
+		opts.Resume = false
+	}
+	if opts.Resume {
+		if stat, err := os.Stat(backend.PartialPath(target)); err == nil {
+			bar.Add64(stat.Size())
+		}
+	}
+
+	n, err := downloadEntry(ctx, client, entry, target, opts)
+	if err != nil {
+		return n, err
+	}
+	if entry.FileModTime != nil {
+		if err := os.Chtimes(target, *entry.FileModTime, *entry.FileModTime); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// storedSize returns how many bytes of an indexed file are stored on Walrus:
+// the compressed size if it was compressed, and the parts if it was chunked
+func storedSize(entry *FileEntry) int64 {
+	if len(entry.Parts) > 0 {
+		var total int64
+		for _, part := range entry.Parts {
+			total += part.Size
+		}
+		return total
+	}
+	if entry.Compression != nil {
+		return entry.Compression.Size
+	}
+	return entry.Size
+}
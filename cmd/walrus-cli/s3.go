@@ -1,26 +1,40 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
 	"github.com/justmert/walrus-cli/backend"
 )
 
 var s3Cmd = &cobra.Command{
 	Use:   "s3",
-	Short: "Transfer files from AWS S3 to Walrus",
-	Long:  `Commands for transferring files from AWS S3 buckets to Walrus decentralized storage`,
+	Short: "Transfer files between AWS S3 and Walrus",
+	Long: `Commands for transferring files from AWS S3 buckets to Walrus decentralized storage,
+and for exporting them back.
+
+Credentials come from --access-key/--secret-key if given, otherwise from the
+AWS credential chain: environment variables, ~/.aws/config and credentials
+(select a profile with --profile or s3.profile in config.yaml), SSO, web
+identity and instance metadata.
+  walrus-cli s3 list-buckets --profile prod
+
+S3-compatible stores work too; point --endpoint at them:
+  walrus-cli s3 list-buckets --endpoint http://localhost:9000 --path-style       # MinIO
+  walrus-cli s3 list-buckets --endpoint https://<account>.r2.cloudflarestorage.com --region auto`,
 }
 
 var s3ConfigureCmd = &cobra.Command{
 	Use:   "configure",
-	Short: "Configure AWS S3 credentials",
+	Short: "Save the S3 profile, region and endpoint to config.yaml",
 	RunE:  runS3Configure,
 }
 
@@ -55,25 +69,113 @@ Examples:
   walrus-cli s3 transfer --bucket my-bucket --dry-run
 
   # Transfer with parallel uploads
-  walrus-cli s3 transfer --bucket my-bucket --parallel 5`,
+  walrus-cli s3 transfer --bucket my-bucket --parallel 5
+
+  # Store data/2024/a/report.pdf as archive/a/report.pdf
+  walrus-cli s3 transfer --bucket my-bucket --prefix data/2024/ --strip-prefix data/2024/ --add-prefix archive/
+
+  # Continue an interrupted transfer without uploading finished objects again
+  walrus-cli s3 transfer --bucket my-bucket --resume
+
+  # Record key, blob ID, size, expiry and Sui object ID of every object
+  walrus-cli s3 transfer --bucket my-bucket --report migration.csv
+
+  # Check every object end to end and keep its hashes
+  walrus-cli s3 transfer --bucket my-bucket --verify --report migration.json
+
+  # Encrypt objects with a key file before they leave this machine
+  walrus-cli s3 transfer --bucket my-bucket --encrypt --key-file walrus.key
+
+Objects keep their full key as their name unless --strip-prefix, --add-prefix
+or --flatten say otherwise. If two objects would end up with the same name the
+transfer stops before uploading anything.
+
+Every finished object is recorded in a journal under ~/.walrus-transfers, keyed
+by bucket, key and ETag. --resume skips objects already transferred unchanged.
+
+--verify hashes each object as it streams to Walrus, compares the MD5 with
+the object's ETag when it is a single-part upload, then downloads the blob
+and compares its SHA-256. Objects that do not match are marked failed and
+left out of the index. ETags of SSE-KMS encrypted objects are not MD5s, so
+those objects fail verification.
+
+Each object's content type, cache control, user metadata, tags and version ID
+are kept in the index, and shown by 'walrus-cli info'. --attributes also
+stores them as blob attributes, which takes a wallet transaction per object.
+
+--encrypt encrypts every object on this machine with AES-256-GCM before it is
+uploaded, and names it with a .enc suffix. The key comes from --key-file (32
+raw bytes, hex or base64, e.g. from 'openssl rand -out walrus.key 32') or is
+derived from a passphrase, read from $WALRUS_PASSPHRASE or asked for. The
+index records the scheme and key ID; get the plaintext back with
+'walrus-cli download --decrypt'. Blob attributes are public and stay
+unencrypted, so leave out --attributes for confidential metadata.`,
 	RunE: runS3Transfer,
 }
 
+var s3SyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Mirror new and changed S3 objects to Walrus",
+	Long: `Upload only the objects that are new or changed since the last transfer or
+sync of the bucket. Objects are compared by ETag, falling back to size and
+modification time, against what the transfer index recorded for them.
+
+With --delete, index entries whose object was deleted upstream are marked as
+deleted. Their blobs stay on Walrus until they expire or are deleted.
+
+Examples:
+  # Mirror a bucket prefix
+  walrus-cli s3 sync --bucket my-bucket --prefix data/
+
+  # Also tombstone entries for objects removed from the bucket
+  walrus-cli s3 sync --bucket my-bucket --delete`,
+	Args: cobra.NoArgs,
+	RunE: runS3Sync,
+}
+
+var s3RetryFailedCmd = &cobra.Command{
+	Use:   "retry-failed",
+	Short: "Retry only the objects that failed in earlier transfers",
+	Long: `Re-run the objects the transfer journal recorded as failed for a bucket.
+Takes the same filter and naming flags as transfer.
+
+Example:
+  walrus-cli s3 transfer retry-failed --bucket my-bucket`,
+	Args: cobra.NoArgs,
+	RunE: runS3RetryFailed,
+}
+
 var (
-	s3Bucket      string
-	s3Prefix      string
-	s3Include     []string
-	s3Exclude     []string
-	s3MinSize     int64
-	s3MaxSize     int64
-	s3Parallel    int
-	s3DryRun      bool
-	s3Encrypt     bool
-	s3Epochs      int
-	s3AccessKey   string
-	s3SecretKey   string
+	s3Bucket       string
+	s3Prefix       string
+	s3Include      []string
+	s3Exclude      []string
+	s3MinSize      int64
+	s3MaxSize      int64
+	s3Parallel     int
+	s3DryRun       bool
+	s3Deletable    bool
+	s3Encrypt      bool
+	s3KeyFile      string
+	s3Compress     string
+	s3Epochs       int
+	s3StripPrefix  string
+	s3AddPrefix    string
+	s3Flatten      bool
+	s3Resume       bool
+	s3SyncDelete   bool
+	s3Report       string
+	s3Verify       bool
+	s3Attributes   bool
+	s3AccessKey    string
+	s3SecretKey    string
 	s3SessionToken string
-	s3Region      string
+	s3Region       string
+	s3Profile      string
+	s3Endpoint     string
+	s3PathStyle    bool
+	s3Insecure     bool
+	s3CACert       string
 )
 
 func init() {
@@ -81,99 +183,179 @@ func init() {
 	s3Cmd.AddCommand(s3ListBucketsCmd)
 	s3Cmd.AddCommand(s3ListObjectsCmd)
 	s3Cmd.AddCommand(s3TransferCmd)
+	s3TransferCmd.AddCommand(s3RetryFailedCmd)
+	s3Cmd.AddCommand(s3SyncCmd)
+	s3Cmd.AddCommand(s3ExportCmd)
 
 	s3ListObjectsCmd.Flags().StringVar(&s3Bucket, "bucket", "", "S3 bucket name")
 	s3ListObjectsCmd.Flags().StringVar(&s3Prefix, "prefix", "", "Object key prefix filter")
 	s3ListObjectsCmd.MarkFlagRequired("bucket")
 
-	s3TransferCmd.Flags().StringVar(&s3Bucket, "bucket", "", "S3 bucket name")
-	s3TransferCmd.Flags().StringVar(&s3Prefix, "prefix", "", "Object key prefix filter")
-	s3TransferCmd.Flags().StringSliceVar(&s3Include, "include", nil, "Include patterns (e.g., *.pdf)")
-	s3TransferCmd.Flags().StringSliceVar(&s3Exclude, "exclude", nil, "Exclude patterns (e.g., temp/*)")
-	s3TransferCmd.Flags().Int64Var(&s3MinSize, "min-size", 0, "Minimum file size in bytes")
-	s3TransferCmd.Flags().Int64Var(&s3MaxSize, "max-size", 0, "Maximum file size in bytes")
-	s3TransferCmd.Flags().IntVar(&s3Parallel, "parallel", 3, "Number of parallel transfers (1-10)")
-	s3TransferCmd.Flags().BoolVar(&s3DryRun, "dry-run", false, "Preview transfer without uploading")
-	s3TransferCmd.Flags().BoolVar(&s3Encrypt, "encrypt", false, "Enable Seal encryption for transferred files")
-	s3TransferCmd.Flags().IntVar(&s3Epochs, "epochs", 5, "Storage duration in epochs")
-	s3TransferCmd.MarkFlagRequired("bucket")
+	s3ExportCmd.Flags().StringVar(&s3Bucket, "bucket", "", "Destination S3 bucket")
+	s3ExportCmd.Flags().StringVar(&s3Prefix, "prefix", "", "Prepend this to every object key (e.g. backup/)")
+	s3ExportCmd.Flags().StringSliceVar(&s3ExportBlobIDs, "blob-id", nil, "Also export this blob ID, stored under its ID (repeatable)")
+	s3ExportCmd.Flags().IntVar(&s3Parallel, "parallel", 3, "Number of parallel transfers (1-10)")
+	s3ExportCmd.Flags().BoolVar(&s3DryRun, "dry-run", false, "Preview export without uploading")
+	s3ExportCmd.Flags().StringVar(&s3Report, "report", "", "Write a per-file report to this .json or .csv file")
+	s3ExportCmd.MarkFlagRequired("bucket")
+
+	addTransferFlags(s3TransferCmd.PersistentFlags())
+	s3TransferCmd.Flags().BoolVar(&s3Resume, "resume", false, "Skip objects the transfer journal has as already transferred")
+	s3TransferCmd.MarkPersistentFlagRequired("bucket")
+
+	addTransferFlags(s3SyncCmd.Flags())
+	s3SyncCmd.Flags().BoolVar(&s3SyncDelete, "delete", false, "Mark index entries as deleted when their object is gone upstream")
+	s3SyncCmd.MarkFlagRequired("bucket")
 
 	s3Cmd.PersistentFlags().StringVar(&s3AccessKey, "access-key", "", "AWS Access Key ID")
 	s3Cmd.PersistentFlags().StringVar(&s3SecretKey, "secret-key", "", "AWS Secret Access Key")
 	s3Cmd.PersistentFlags().StringVar(&s3SessionToken, "session-token", "", "AWS Session Token (optional)")
-	s3Cmd.PersistentFlags().StringVar(&s3Region, "region", "us-east-1", "AWS Region")
+	s3Cmd.PersistentFlags().StringVar(&s3Region, "region", "", "AWS Region (default: from the profile, or us-east-1)")
+	s3Cmd.PersistentFlags().StringVar(&s3Profile, "profile", "", "AWS named profile from ~/.aws/config and ~/.aws/credentials")
+	s3Cmd.PersistentFlags().StringVar(&s3Endpoint, "endpoint", "", "S3-compatible endpoint URL (MinIO, R2, Wasabi, Ceph)")
+	s3Cmd.PersistentFlags().BoolVar(&s3PathStyle, "path-style", false, "Address buckets as endpoint/bucket instead of bucket.endpoint")
+	s3Cmd.PersistentFlags().BoolVar(&s3Insecure, "insecure", false, "Skip TLS certificate verification for the endpoint")
+	s3Cmd.PersistentFlags().StringVar(&s3CACert, "ca-cert", "", "PEM file with extra CA certificates to trust for the endpoint")
+}
+
+// addTransferFlags registers the filter, naming and storage flags shared by
+// transfer and sync
+func addTransferFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&s3Bucket, "bucket", "", "S3 bucket name")
+	flags.StringVar(&s3Prefix, "prefix", "", "Object key prefix filter")
+	flags.StringSliceVar(&s3Include, "include", nil, "Include patterns (e.g., *.pdf)")
+	flags.StringSliceVar(&s3Exclude, "exclude", nil, "Exclude patterns (e.g., temp/*)")
+	flags.Int64Var(&s3MinSize, "min-size", 0, "Minimum file size in bytes")
+	flags.Int64Var(&s3MaxSize, "max-size", 0, "Maximum file size in bytes")
+	flags.IntVar(&s3Parallel, "parallel", 3, "Number of parallel transfers (1-10)")
+	flags.BoolVar(&s3DryRun, "dry-run", false, "Preview transfer without uploading")
+	flags.BoolVar(&s3Encrypt, "encrypt", false, "Encrypt objects with AES-256-GCM before they are uploaded")
+	flags.StringVar(&s3KeyFile, "key-file", "", "256-bit key for --encrypt (default: ask for a passphrase or read $WALRUS_PASSPHRASE)")
+	flags.StringVar(&s3Compress, "compress", "", "Compress objects before they are uploaded (zstd or gzip); already-compressed types are stored as they are")
+	flags.BoolVar(&s3Deletable, "deletable", false, "Store blobs as deletable")
+	flags.IntVar(&s3Epochs, "epochs", 5, "Storage duration in epochs")
+	flags.StringVar(&s3StripPrefix, "strip-prefix", "", "Remove this prefix from object keys when naming files")
+	flags.StringVar(&s3AddPrefix, "add-prefix", "", "Prepend this prefix to file names")
+	flags.BoolVar(&s3Flatten, "flatten", false, "Name files by the last path element of their key only")
+	flags.BoolVar(&s3Attributes, "attributes", false, "Also store content type, metadata, tags and source as blob attributes (one wallet transaction per object)")
+	flags.BoolVar(&s3Verify, "verify", false, "Hash each object while uploading and read the blob back to check it")
+	flags.StringVar(&s3Report, "report", "", "Write a per-object report to this .json or .csv file")
 }
 
+// getS3Credentials combines the s3 flags with the s3 section of config.yaml.
+// Flags win over the config file; anything left unset falls through to the
+// AWS credential chain.
 func getS3Credentials() (backend.S3Credentials, error) {
 	creds := backend.S3Credentials{
 		AccessKeyID:     s3AccessKey,
 		SecretAccessKey: s3SecretKey,
 		SessionToken:    s3SessionToken,
 		Region:          s3Region,
+		Profile:         s3Profile,
+
+		Endpoint:           s3Endpoint,
+		UsePathStyle:       s3PathStyle,
+		InsecureSkipVerify: s3Insecure,
+		CACertFile:         s3CACert,
+	}
+
+	if (creds.AccessKeyID == "") != (creds.SecretAccessKey == "") {
+		return creds, fmt.Errorf("--access-key and --secret-key must be given together")
 	}
 
-	if creds.AccessKeyID == "" {
-		creds.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	config, err := backend.LoadConfig("")
+	if err != nil {
+		return creds, fmt.Errorf("failed to load config: %w", err)
 	}
-	if creds.SecretAccessKey == "" {
-		creds.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if creds.Profile == "" {
+		creds.Profile = config.S3.Profile
 	}
-	if creds.SessionToken == "" {
-		creds.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	if creds.Region == "" {
+		creds.Region = config.S3.Region
 	}
-	if creds.Region == "" || creds.Region == "us-east-1" {
-		if region := os.Getenv("AWS_REGION"); region != "" {
-			creds.Region = region
-		}
+	if creds.Endpoint == "" {
+		creds.Endpoint = config.S3.Endpoint
 	}
-
-	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
-		return creds, fmt.Errorf("AWS credentials not found. Please set --access-key and --secret-key flags or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables")
+	if creds.Endpoint == "" {
+		creds.Endpoint = os.Getenv("AWS_ENDPOINT_URL_S3")
+	}
+	if !creds.UsePathStyle {
+		creds.UsePathStyle = config.S3.UsePathStyle
+	}
+	if creds.CACertFile == "" {
+		creds.CACertFile = config.S3.CACertFile
 	}
 
 	return creds, nil
 }
 
 func runS3Configure(cmd *cobra.Command, args []string) error {
-	fmt.Println(color.CyanString("🔧 Configure AWS S3 Credentials"))
+	fmt.Println(color.CyanString("🔧 Configure AWS S3 Source"))
 	fmt.Println(strings.Repeat("=", 40))
 
-	var accessKey, secretKey, sessionToken, region string
-
-	prompt := &survey.Input{
-		Message: "AWS Access Key ID:",
-		Default: os.Getenv("AWS_ACCESS_KEY_ID"),
+	configPath := backend.DefaultConfigPath()
+	config, err := backend.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
 	}
-	survey.AskOne(prompt, &accessKey)
-
-	prompt = &survey.Input{
-		Message: "AWS Secret Access Key:",
-		Default: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	s3Config := config.S3
+
+	defaultProfile := s3Config.Profile
+	if defaultProfile == "" {
+		defaultProfile = os.Getenv("AWS_PROFILE")
+	}
+
+	questions := []*survey.Question{
+		{
+			Name: "Profile",
+			Prompt: &survey.Input{
+				Message: "AWS profile (optional, blank for the default credential chain):",
+				Default: defaultProfile,
+			},
+		},
+		{
+			Name: "Region",
+			Prompt: &survey.Input{
+				Message: "AWS Region (optional, blank to use the profile's):",
+				Default: s3Config.Region,
+			},
+		},
+		{
+			Name: "Endpoint",
+			Prompt: &survey.Input{
+				Message: "Endpoint URL (optional, for S3-compatible stores):",
+				Default: s3Config.Endpoint,
+			},
+		},
+	}
+	if err := survey.Ask(questions, &s3Config); err != nil {
+		return err
 	}
-	survey.AskOne(prompt, &secretKey)
 
-	prompt = &survey.Input{
-		Message: "AWS Session Token (optional):",
-		Default: os.Getenv("AWS_SESSION_TOKEN"),
+	if s3Config.Endpoint != "" {
+		prompt := &survey.Confirm{
+			Message: "Use path-style addressing (endpoint/bucket)?",
+			Default: s3Config.UsePathStyle,
+		}
+		if err := survey.AskOne(prompt, &s3Config.UsePathStyle); err != nil {
+			return err
+		}
+	} else {
+		s3Config.UsePathStyle = false
 	}
-	survey.AskOne(prompt, &sessionToken)
 
-	prompt = &survey.Input{
-		Message: "AWS Region:",
-		Default: "us-east-1",
+	config.S3 = s3Config
+	if err := backend.SaveConfig(config, configPath); err != nil {
+		return fmt.Errorf("saving config: %w", err)
 	}
-	survey.AskOne(prompt, &region)
 
-	fmt.Println(color.GreenString("\n✅ S3 credentials configured for this session"))
-	fmt.Println("\nTo persist credentials, you can:")
-	fmt.Println("1. Set environment variables: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY")
-	fmt.Println("2. Use AWS CLI: aws configure")
-	fmt.Println("3. Pass credentials as flags: --access-key, --secret-key")
-
-	s3AccessKey = accessKey
-	s3SecretKey = secretKey
-	s3SessionToken = sessionToken
-	s3Region = region
+	fmt.Println(color.GreenString("\n✅ S3 settings saved to %s", configPath))
+	fmt.Println("\nAccess keys are not stored. The s3 commands find them through the AWS")
+	fmt.Println("credential chain:")
+	fmt.Println("1. Environment variables: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY")
+	fmt.Println("2. A profile set up with `aws configure` or `aws configure sso`")
+	fmt.Println("3. Web identity or instance metadata when running in AWS")
+	fmt.Println("4. Flags on a single command: --access-key, --secret-key")
 
 	return nil
 }
@@ -189,7 +371,7 @@ func runS3ListBuckets(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create S3 client: %w", err)
 	}
 
-	ctx := context.Background()
+	ctx := cmd.Context()
 	buckets, err := s3Client.ListBuckets(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list buckets: %w", err)
@@ -225,7 +407,7 @@ func runS3ListObjects(cmd *cobra.Command, args []string) error {
 		Prefix: s3Prefix,
 	}
 
-	ctx := context.Background()
+	ctx := cmd.Context()
 	objects, err := s3Client.ListObjects(ctx, s3Bucket, filter)
 	if err != nil {
 		return fmt.Errorf("failed to list objects: %w", err)
@@ -258,6 +440,23 @@ func runS3ListObjects(cmd *cobra.Command, args []string) error {
 }
 
 func runS3Transfer(cmd *cobra.Command, args []string) error {
+	selection := backend.SelectAll
+	if s3Resume {
+		selection = backend.SelectPending
+	}
+	return transferFromS3(cmd, selection)
+}
+
+func runS3RetryFailed(cmd *cobra.Command, args []string) error {
+	return transferFromS3(cmd, backend.SelectFailed)
+}
+
+func runS3Sync(cmd *cobra.Command, args []string) error {
+	return transferFromS3(cmd, backend.SelectChanged)
+}
+
+// transferFromS3 runs a journaled bucket transfer over the objects selection picks
+func transferFromS3(cmd *cobra.Command, selection backend.TransferSelection) error {
 	creds, err := getS3Credentials()
 	if err != nil {
 		return err
@@ -273,12 +472,54 @@ func runS3Transfer(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	walrusClient := backend.NewWalrusClient(config.Walrus.AggregatorURL, config.Walrus.PublisherURL)
+	walrusClient := newWalrusClient(config)
 	simpleFS := backend.NewSimpleFs(config.Walrus.AggregatorURL, config.Walrus.PublisherURL)
+	if err := simpleFS.LoadIndex(); err != nil {
+		return fmt.Errorf("failed to load transfer index: %w", err)
+	}
+
+	if s3Flatten && s3StripPrefix != "" {
+		return fmt.Errorf("--flatten and --strip-prefix cannot be used together")
+	}
+	if s3Report != "" {
+		if _, err := backend.ReportFormat(s3Report); err != nil {
+			return err
+		}
+	}
+	if s3Attributes && !walrusClient.CanSetAttributes() {
+		return fmt.Errorf("--attributes needs wallet.address configured or an upload relay")
+	}
+	if s3Deletable && !walrusClient.OwnsStoredBlobs() {
+		return errDeletableNotOwned
+	}
+	if s3KeyFile != "" && !s3Encrypt {
+		return fmt.Errorf("--key-file is only used with --encrypt")
+	}
+	codec, err := backend.ParseCompression(s3Compress)
+	if err != nil {
+		return err
+	}
+
+	var encryptionConfig *backend.EncryptionSettings
+	if s3Encrypt {
+		key, err := loadEncryptionKey(s3KeyFile, true)
+		if err != nil {
+			return err
+		}
+		encryptionConfig = &backend.EncryptionSettings{Key: key}
+	}
 
 	transferManager := backend.NewTransferManager(s3Client, walrusClient, simpleFS, s3Parallel)
 	transferManager.SetDryRun(s3DryRun)
-	transferManager.SetEncryption(s3Encrypt)
+	transferManager.SetDeletable(s3Deletable)
+	transferManager.SetVerify(s3Verify)
+	transferManager.SetAttributes(s3Attributes)
+	transferManager.SetCompression(codec)
+	transferManager.SetKeyMapping(backend.KeyMapping{
+		StripPrefix: s3StripPrefix,
+		AddPrefix:   s3AddPrefix,
+		Flatten:     s3Flatten,
+	})
 
 	filter := &backend.S3TransferFilter{
 		Prefix:  s3Prefix,
@@ -288,9 +529,13 @@ func runS3Transfer(cmd *cobra.Command, args []string) error {
 		MaxSize: s3MaxSize,
 	}
 
-	ctx := context.Background()
+	ctx := cmd.Context()
 
-	fmt.Println(color.CyanString("\n🚀 S3 to Walrus Transfer"))
+	if selection == backend.SelectChanged {
+		fmt.Println(color.CyanString("\n🔄 S3 to Walrus Sync"))
+	} else {
+		fmt.Println(color.CyanString("\n🚀 S3 to Walrus Transfer"))
+	}
 	fmt.Println(strings.Repeat("=", 50))
 	fmt.Printf("Bucket: %s\n", s3Bucket)
 	if s3Prefix != "" {
@@ -304,37 +549,93 @@ func runS3Transfer(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Printf("Parallel transfers: %d\n", s3Parallel)
 	fmt.Printf("Storage duration: %d epochs\n", s3Epochs)
-	if s3Encrypt {
-		fmt.Println(color.YellowString("Encryption: Enabled (Seal)"))
+	if encryptionConfig != nil {
+		key := encryptionConfig.Key
+		fmt.Printf("Encryption: %s, key %s (%s)\n", backend.EncryptionScheme, key.ID(), key.Source())
+	}
+	if codec != "" {
+		fmt.Printf("Compression: %s (already-compressed types are skipped)\n", codec)
+	}
+	if s3Verify {
+		fmt.Println("Verification: MD5/SHA-256 and read-back")
 	}
 	if s3DryRun {
 		fmt.Println(color.YellowString("Mode: DRY RUN (preview only)"))
 	}
 	fmt.Println(strings.Repeat("=", 50))
 
-	totalSize, fileCount, err := s3Client.EstimateTransferSize(ctx, s3Bucket, filter)
+	journal, err := backend.OpenTransferJournal(s3Bucket)
 	if err != nil {
-		return fmt.Errorf("failed to estimate transfer size: %w", err)
+		return err
+	}
+	defer journal.Close()
+	transferManager.SetJournal(journal)
+	transferManager.SetSelection(selection)
+
+	plan, err := transferManager.PlanTransfer(ctx, s3Bucket, filter)
+	if err != nil {
+		var mappingErr *backend.KeyMappingError
+		if errors.As(err, &mappingErr) {
+			return fmt.Errorf("cannot map object keys to file names: %w", err)
+		}
+		return err
 	}
 
-	if fileCount == 0 {
-		fmt.Println(color.YellowString("\nNo files match the specified criteria"))
+	var deleted []string
+	if selection == backend.SelectChanged && s3SyncDelete {
+		if deleted, err = transferManager.DeletedUpstream(ctx, s3Bucket, s3Prefix); err != nil {
+			return err
+		}
+	}
+
+	if len(plan.Jobs) == 0 && len(deleted) == 0 {
+		switch {
+		case selection == backend.SelectFailed:
+			fmt.Println(color.GreenString("\nNo failed transfers to retry"))
+		case selection == backend.SelectChanged && plan.Skipped > 0:
+			fmt.Println(color.GreenString(fmt.Sprintf("\nAlready in sync (%d files unchanged)", plan.Skipped)))
+		case plan.Skipped > 0:
+			fmt.Println(color.GreenString(fmt.Sprintf("\nAll %d matching files were already transferred", plan.Skipped)))
+		default:
+			fmt.Println(color.YellowString("\nNo files match the specified criteria"))
+		}
+		if !s3DryRun {
+			writeS3Report(&backend.TransferProgress{SkippedFiles: plan.Skipped, StartTime: time.Now()}, s3Epochs)
+		}
 		return nil
 	}
 
-	fmt.Printf("\nFound %d files to transfer (%s total)\n", fileCount, formatS3Bytes(totalSize))
+	fmt.Printf("\nFound %d files to transfer (%s total)\n", len(plan.Jobs), formatS3Bytes(plan.TotalBytes))
+	if plan.Skipped > 0 {
+		fmt.Printf("Skipping %d files %s\n", plan.Skipped, skipReason(selection))
+	}
+	if len(deleted) > 0 {
+		fmt.Printf("%d files were deleted upstream:\n", len(deleted))
+		for _, key := range deleted {
+			fmt.Printf("  - %s\n", key)
+		}
+	}
 
-	totalCost, _, err := transferManager.EstimateTransferCost(ctx, s3Bucket, filter, s3Epochs)
-	if err != nil {
-		return fmt.Errorf("failed to estimate cost: %w", err)
+	if codec != "" {
+		// Compressed sizes are only known once each object has been read
+		fmt.Printf("Estimated cost: %.6f WAL uncompressed; compressed objects are charged for their compressed size\n", plan.EstimatedCost(s3Epochs))
+	} else {
+		fmt.Printf("Estimated cost: %.6f WAL\n", plan.EstimatedCost(s3Epochs))
 	}
 
-	fmt.Printf("Estimated cost: %.6f WAL\n", totalCost)
+	if s3DryRun {
+		if len(plan.Jobs) == 0 {
+			return nil
+		}
+	} else {
+		message := fmt.Sprintf("Proceed with transfer of %d files?", len(plan.Jobs))
+		if len(deleted) > 0 {
+			message = fmt.Sprintf("Proceed with transfer of %d files and mark %d as deleted?", len(plan.Jobs), len(deleted))
+		}
 
-	if !s3DryRun {
 		var confirm bool
 		prompt := &survey.Confirm{
-			Message: fmt.Sprintf("Proceed with transfer of %d files?", fileCount),
+			Message: message,
 			Default: true,
 		}
 		survey.AskOne(prompt, &confirm)
@@ -347,21 +648,41 @@ func runS3Transfer(cmd *cobra.Command, args []string) error {
 
 	fmt.Println()
 
-	var encryptionConfig *backend.EncryptionSettings
-	if s3Encrypt {
-		encryptionConfig = &backend.EncryptionSettings{
-			Enabled:   true,
-			Threshold: 2,
+	if len(deleted) > 0 && !s3DryRun {
+		marked, err := transferManager.Tombstone(s3Bucket, deleted)
+		if err != nil {
+			return err
 		}
+		fmt.Printf("Marked %d index entr%s as deleted upstream\n", len(marked), pluralY(len(marked)))
+	}
+	if len(plan.Jobs) == 0 {
+		writeS3Report(&backend.TransferProgress{SkippedFiles: plan.Skipped, StartTime: time.Now()}, s3Epochs)
+		return nil
 	}
 
-	progress, err := transferManager.TransferBatch(ctx, s3Bucket, filter, s3Epochs, encryptionConfig)
+	progress, err := transferManager.RunPlan(ctx, plan, s3Epochs, encryptionConfig)
 	if err != nil {
-		return fmt.Errorf("transfer failed: %w", err)
+		if progress == nil {
+			return fmt.Errorf("transfer failed: %w", err)
+		}
+		fmt.Println(color.YellowString("\n⚠️  Transfer interrupted"))
+		fmt.Println(progress.GetSummary())
+		writeS3Report(progress, s3Epochs)
+		fmt.Printf("\nRun the same command with --resume to continue where it stopped\n")
+		return err
 	}
 
 	fmt.Println(color.GreenString("\n✅ Transfer Complete"))
 	fmt.Println(progress.GetSummary())
+	if !s3DryRun {
+		writeS3Report(progress, s3Epochs)
+	}
+
+	for _, result := range progress.Results {
+		if result.Success && result.Error != nil {
+			fmt.Println(color.YellowString("⚠️  %s: %v", result.SourceKey, result.Error))
+		}
+	}
 
 	if progress.FailedFiles > 0 {
 		fmt.Println(color.RedString("\n❌ Failed Transfers:"))
@@ -370,11 +691,40 @@ func runS3Transfer(cmd *cobra.Command, args []string) error {
 				fmt.Printf("  • %s: %v\n", result.SourceKey, result.Error)
 			}
 		}
+		fmt.Printf("\nRetry them with: walrus-cli s3 transfer retry-failed --bucket %s\n", s3Bucket)
 	}
 
 	return nil
 }
 
+// writeS3Report saves the --report file for a run. A report that cannot be
+// written is a warning: the transfer itself has already happened.
+func writeS3Report(progress *backend.TransferProgress, epochs int) {
+	if s3Report == "" {
+		return
+	}
+	report := backend.NewTransferReport(s3Bucket, s3Prefix, epochs, progress)
+	if err := report.Write(s3Report); err != nil {
+		fmt.Println(color.YellowString("Warning: %v", err))
+		return
+	}
+	fmt.Printf("Report written to %s\n", s3Report)
+}
+
+// skipReason explains why a selection left objects out of a run
+func skipReason(selection backend.TransferSelection) string {
+	switch selection {
+	case backend.SelectPending:
+		return "already transferred"
+	case backend.SelectFailed:
+		return "that did not fail"
+	case backend.SelectChanged:
+		return "unchanged since they were copied"
+	default:
+		return "according to the journal"
+	}
+}
+
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -393,4 +743,4 @@ func formatS3Bytes(bytes int64) string {
 		exp++
 	}
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
\ No newline at end of file
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/justmert/walrus-cli/backend"
+	"github.com/spf13/cobra"
+)
+
+var s3ExportCmd = &cobra.Command{
+	Use:   "export [names...]",
+	Short: "Copy files from Walrus into an S3 bucket",
+	Long: `Stream files from the Walrus aggregator into an S3 bucket, for disaster
+recovery or moving data back. Each file is stored under --prefix plus its name
+in the index, with its content type and tags as object metadata and its blob
+ID as x-amz-meta-walrus-blob-id. Existing objects with the same key are
+overwritten.
+
+Without names or --blob-id, every file in the upload and S3 transfer indexes
+is exported. Blobs given by ID are stored under their blob ID.
+
+Examples:
+  # Export everything under backup/
+  walrus-cli s3 export --bucket dr-bucket --prefix backup/
+
+  # Export two files and a blob that is not in the index
+  walrus-cli s3 export --bucket dr-bucket report.pdf notes.txt --blob-id <blob-id>`,
+	RunE: runS3Export,
+}
+
+var s3ExportBlobIDs []string
+
+func runS3Export(cmd *cobra.Command, args []string) error {
+	if s3Report != "" {
+		if _, err := backend.ReportFormat(s3Report); err != nil {
+			return err
+		}
+	}
+
+	creds, err := getS3Credentials()
+	if err != nil {
+		return err
+	}
+
+	s3Client, err := backend.NewS3Client(creds)
+	if err != nil {
+		return fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	config, err := backend.LoadConfig("")
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	walrusClient := newWalrusClient(config)
+	jobs, err := exportJobs(loadIndex(), loadSimpleFs(walrusClient), args, s3ExportBlobIDs)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		fmt.Println(color.YellowString("No files to export"))
+		return nil
+	}
+
+	var totalSize int64
+	for _, job := range jobs {
+		totalSize += job.Size
+	}
+
+	fmt.Println(color.CyanString("\n📤 Walrus to S3 Export"))
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Printf("Bucket: %s\n", s3Bucket)
+	if s3Prefix != "" {
+		fmt.Printf("Prefix: %s\n", s3Prefix)
+	}
+	fmt.Printf("Files: %d (%s)\n", len(jobs), formatS3Bytes(totalSize))
+	fmt.Printf("Parallel transfers: %d\n", s3Parallel)
+	if s3DryRun {
+		fmt.Println(color.YellowString("Mode: DRY RUN (preview only)"))
+	}
+	fmt.Println(strings.Repeat("=", 50))
+
+	if !s3DryRun {
+		var confirm bool
+		prompt := &survey.Confirm{
+			Message: fmt.Sprintf("Export %d files to s3://%s/%s?", len(jobs), s3Bucket, s3Prefix),
+			Default: true,
+		}
+		survey.AskOne(prompt, &confirm)
+
+		if !confirm {
+			fmt.Println(color.YellowString("Export cancelled"))
+			return nil
+		}
+	}
+
+	transferManager := backend.NewTransferManager(s3Client, walrusClient, nil, s3Parallel)
+	transferManager.SetDryRun(s3DryRun)
+
+	progress, err := transferManager.Export(cmd.Context(), s3Bucket, s3Prefix, jobs)
+	if err != nil {
+		fmt.Println(color.YellowString("\n⚠️  Export interrupted"))
+		fmt.Println(progress.GetSummary())
+		writeS3Report(progress, 0)
+		return err
+	}
+	if s3DryRun {
+		return nil
+	}
+
+	fmt.Println(color.GreenString("\n✅ Export Complete"))
+	fmt.Println(progress.GetSummary())
+	writeS3Report(progress, 0)
+
+	if progress.FailedFiles > 0 {
+		fmt.Println(color.RedString("\n❌ Failed Exports:"))
+		for _, result := range progress.Results {
+			if !result.Success && result.Error != nil {
+				fmt.Printf("  • %s: %v\n", result.SourceKey, result.Error)
+			}
+		}
+		return fmt.Errorf("%d of %d files failed to export", progress.FailedFiles, len(jobs))
+	}
+
+	return nil
+}
+
+// exportJobs picks what to export: the named files and the given blob IDs,
+// or every file in the upload and S3 transfer indexes when neither is given
+func exportJobs(index *FileIndex, simpleFS *backend.SimpleFs, names, blobIDs []string) ([]backend.ExportJob, error) {
+	candidates := make(map[string][]backend.ExportJob)
+	if simpleFS != nil {
+		for name, entry := range simpleFS.List() {
+			job := backend.ExportJob{
+				Name:        name,
+				BlobID:      entry.BlobID,
+				Size:        entry.Size,
+				Chunked:     len(entry.Parts) > 0,
+				ContentType: entry.ContentType,
+				Tags:        entry.Tags,
+			}
+			// Objects copied from S3 go back with the metadata they had
+			if entry.Source != nil {
+				job.Metadata = entry.Source.Metadata
+			}
+			// Encrypted blobs are exported as ciphertext, compressed or not
+			if entry.Encryption == nil {
+				job.Compression = compressionCodec(entry.Compression)
+			}
+			candidates[name] = append(candidates[name], job)
+		}
+	}
+	// Upload index entries go last so they win, keeping the S3 metadata of
+	// the same blob in the transfer index
+	for name, entry := range index.Files {
+		candidates[name] = append(candidates[name], backend.ExportJob{
+			Name:         name,
+			BlobID:       entry.BlobID,
+			QuiltPatchID: entry.QuiltPatchID,
+			Chunked:      len(entry.Parts) > 0,
+			Compression:  compressionCodec(entry.Compression),
+			Size:         entry.Size,
+			ContentType:  entry.ContentType,
+			Tags:         entry.Tags,
+		})
+	}
+
+	if len(names) == 0 && len(blobIDs) == 0 {
+		for name := range candidates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var jobs []backend.ExportJob
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		found := candidates[name]
+		if len(found) == 0 {
+			return nil, fmt.Errorf("file not found in index: %s", name)
+		}
+		if len(found) > 1 && found[0].BlobID != found[len(found)-1].BlobID {
+			return nil, fmt.Errorf("%s is a different blob in the upload index (%s) and the S3 transfer index (%s); export it by --blob-id instead",
+				name, found[len(found)-1].BlobID, found[0].BlobID)
+		}
+		job := found[len(found)-1]
+		if job.Metadata == nil {
+			job.Metadata = found[0].Metadata
+		}
+		jobs = append(jobs, job)
+	}
+
+	for _, blobID := range blobIDs {
+		if seen[blobID] {
+			continue
+		}
+		seen[blobID] = true
+		jobs = append(jobs, backend.ExportJob{Name: blobID, BlobID: blobID})
+	}
+
+	return jobs, nil
+}
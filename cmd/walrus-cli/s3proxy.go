@@ -60,20 +60,15 @@ func handleS3Proxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate credentials
-	if req.Credentials.AccessKeyID == "" || req.Credentials.SecretAccessKey == "" {
-		sendS3ProxyError(w, "AWS credentials are required")
-		return
-	}
-
 	// Create S3 client
-	s3Client, err := backend.NewS3Client(req.Credentials)
+	s3Client, err := newProxyS3Client(req.Credentials)
 	if err != nil {
 		sendS3ProxyError(w, "Failed to create S3 client: "+err.Error())
 		return
 	}
 
-	ctx := context.Background()
+	// Tie S3 calls to the request so a disconnected browser cancels them
+	ctx := r.Context()
 
 	switch req.Action {
 	case "listBuckets":
@@ -89,6 +84,23 @@ func handleS3Proxy(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// newProxyS3Client creates an S3 client from credentials sent by the web UI,
+// including a custom endpoint, path-style addressing and TLS verification
+// settings. A CA file path is not taken from the request; the browser has no
+// business pointing the server at files on disk.
+//
+// Explicit keys are required. Falling back to the AWS credential chain here
+// would let any page that can reach the proxy act with the local user's AWS
+// identity, so profiles are not taken from the request either.
+func newProxyS3Client(creds backend.S3Credentials) (*backend.S3Client, error) {
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS credentials are required")
+	}
+	creds.CACertFile = ""
+	creds.Profile = ""
+	return backend.NewS3Client(creds)
+}
+
 func handleListBuckets(ctx context.Context, w http.ResponseWriter, client *backend.S3Client) {
 	buckets, err := client.ListBuckets(ctx)
 	if err != nil {
@@ -243,9 +255,15 @@ func handleS3Transfer(w http.ResponseWriter, r *http.Request) {
 		sendS3ProxyError(w, "Invalid request: "+err.Error())
 		return
 	}
+	// The proxy has no way to take a key from the browser safely; refuse
+	// rather than store plaintext the caller believes is encrypted
+	if req.Encrypt {
+		sendS3ProxyError(w, "Encryption is not available through the proxy; use walrus-cli s3 transfer --encrypt")
+		return
+	}
 
 	// Create S3 client
-	s3Client, err := backend.NewS3Client(req.Credentials)
+	s3Client, err := newProxyS3Client(req.Credentials)
 	if err != nil {
 		sendS3ProxyError(w, "Failed to create S3 client: "+err.Error())
 		return
@@ -258,7 +276,7 @@ func handleS3Transfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	walrusClient := backend.NewWalrusClient(config.Walrus.AggregatorURL, config.Walrus.PublisherURL)
+	walrusClient := newWalrusClient(config)
 	simpleFS := backend.NewSimpleFs(config.Walrus.AggregatorURL, config.Walrus.PublisherURL)
 
 	// Create transfer manager
@@ -267,7 +285,12 @@ func handleS3Transfer(w http.ResponseWriter, r *http.Request) {
 	// Transfer each file
 	results := []map[string]interface{}{}
 	for _, key := range req.Keys {
-		result, err := transferManager.TransferSingle(context.Background(), req.Bucket, key, req.Epochs)
+		if r.Context().Err() != nil {
+			// Browser went away; don't start any more uploads
+			return
+		}
+
+		result, err := transferManager.TransferSingle(r.Context(), req.Bucket, key, req.Epochs)
 		if err != nil {
 			results = append(results, map[string]interface{}{
 				"key":     key,
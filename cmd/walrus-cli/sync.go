@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/justmert/walrus-cli/backend"
+)
+
+// syncPlan is what it takes to make the index entries under a prefix match
+// a local directory
+type syncPlan struct {
+	uploads   []treeFile      // New and changed files
+	changed   map[string]bool // Names among uploads that are already indexed
+	touched   []treeFile      // Files whose modification time moved but whose content did not
+	deletes   []syncDelete    // Indexed files no longer on disk, when deleting
+	unchanged int
+}
+
+// syncDelete is an index entry a sync removes
+type syncDelete struct {
+	name    string
+	onChain bool // The blob is deletable and no remaining entry uses it
+}
+
+// planSync compares the files under a directory with their index entries.
+// A file with the indexed size and modification time is taken as unchanged;
+// one whose time moved is hashed and compared with the recorded SHA-256.
+func planSync(index *FileIndex, files []treeFile, prefix string, opts uploadOptions, deleteMissing bool) (*syncPlan, error) {
+	plan := &syncPlan{changed: make(map[string]bool)}
+	local := make(map[string]bool)
+	for _, f := range files {
+		local[f.name] = true
+		entry, exists := index.Files[f.name]
+		switch {
+		case !exists:
+			plan.uploads = append(plan.uploads, f)
+			continue
+		case entry.Size != f.size:
+			// Changed
+		case entry.FileModTime != nil && entry.FileModTime.Equal(f.modTime):
+			plan.unchanged++
+			continue
+		default:
+			sum, err := hashFile(f.path)
+			if err != nil {
+				return nil, err
+			}
+			f.sha256 = sum
+			if sum == entry.SHA256 {
+				plan.touched = append(plan.touched, f)
+				continue
+			}
+		}
+		plan.uploads = append(plan.uploads, f)
+		plan.changed[f.name] = true
+	}
+
+	if !deleteMissing {
+		return plan, nil
+	}
+	gone := make(map[string]bool)
+	for _, name := range sortedKeys(index.Files) {
+		if strings.HasPrefix(name, prefix) && !local[name] &&
+			backend.MatchesPatterns(strings.TrimPrefix(name, prefix), opts.Include, opts.Exclude) {
+			gone[name] = true
+		}
+	}
+	// A blob is only deleted once nothing left in the index points at it,
+	// which rules out quilts holding files that are still there
+	inUse := make(map[string]bool)
+	for name, entry := range index.Files {
+		if !gone[name] {
+			inUse[entry.BlobID] = true
+		}
+	}
+	for _, name := range sortedKeys(gone) {
+		entry := index.Files[name]
+		plan.deletes = append(plan.deletes, syncDelete{name: name, onChain: entry.Deletable && !inUse[entry.BlobID]})
+		inUse[entry.BlobID] = true
+	}
+	return plan, nil
+}
+
+// handleSync makes the index entries under prefix match the directory dir:
+// new and changed files are uploaded, and with deleteMissing, entries whose
+// file is gone are deleted. Replaced versions stay stored until they expire.
+func handleSync(ctx context.Context, client *backend.WalrusClient, index *FileIndex, dir, prefix string, opts uploadOptions, deleteMissing, force bool) {
+	stat, err := os.Stat(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading directory: %v\n", err)
+		os.Exit(1)
+	}
+	if !stat.IsDir() {
+		fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", dir)
+		os.Exit(1)
+	}
+
+	// "docs" and "docs/" both mean the docs directory, not docs2/
+	prefix = strings.Trim(prefix, "/")
+	if deleteMissing && prefix == "" {
+		// Every indexed file is under the empty prefix, so everything not in
+		// dir would be deleted, including files uploaded from elsewhere
+		fmt.Fprintln(os.Stderr, "Error: --delete needs a prefix; syncing to / would delete every indexed file that is not in the directory")
+		os.Exit(1)
+	}
+	if opts.Deletable && !client.OwnsStoredBlobs() {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", errDeletableNotOwned)
+		os.Exit(1)
+	}
+	files, err := walkTree(dir, prefix, opts.Include, opts.Exclude)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading directory: %v\n", err)
+		os.Exit(1)
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+	remote := prefix
+	if remote == "" {
+		remote = "/"
+	}
+	plan, err := planSync(index, files, prefix, opts, deleteMissing)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error comparing files: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Directory: %s\n", dir)
+	fmt.Printf("Prefix: %s\n", remote)
+	var uploadSize int64
+	for _, f := range plan.uploads {
+		uploadSize += f.size
+	}
+	fmt.Printf("To upload: %d (%s, %d changed)\n", len(plan.uploads), formatBytes(uploadSize), len(plan.changed))
+	if deleteMissing {
+		fmt.Printf("To delete: %d\n", len(plan.deletes))
+	}
+	fmt.Printf("Unchanged: %d\n", plan.unchanged+len(plan.touched))
+	if len(plan.uploads) > 0 {
+		printTags(opts.Tags)
+		fmt.Printf("Epochs: %d\n", opts.Epochs)
+		if opts.Deletable {
+			fmt.Println("Deletable: yes")
+		}
+		printTreeCost(ctx, client, plan.uploads, opts)
+	}
+
+	if len(plan.uploads) == 0 && len(plan.deletes) == 0 && len(plan.touched) == 0 {
+		fmt.Println("\n✓ Already in sync")
+		return
+	}
+
+	if len(plan.uploads) > 0 || len(plan.deletes) > 0 {
+		fmt.Println()
+	}
+	for _, f := range plan.uploads {
+		mark := color.GreenString("+")
+		if plan.changed[f.name] {
+			mark = color.YellowString("~")
+		}
+		fmt.Printf("  %s %s (%s)\n", mark, f.name, formatBytes(f.size))
+	}
+	for _, d := range plan.deletes {
+		note := ""
+		if !d.onChain {
+			note = " (index only)"
+		}
+		fmt.Printf("  %s %s%s\n", color.RedString("-"), d.name, note)
+	}
+
+	if opts.DryRun {
+		fmt.Println("\n✓ Dry run complete (nothing uploaded or deleted)")
+		return
+	}
+
+	if len(plan.deletes) > 0 && !force {
+		ok, err := confirm(fmt.Sprintf("Delete %d file%s missing from %s?", len(plan.deletes), pluralS(len(plan.deletes)), dir))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !ok {
+			fmt.Println("Cancelled")
+			return
+		}
+	}
+
+	synced := true
+	if len(plan.uploads) > 0 {
+		fmt.Println()
+		progress, err := uploadTree(ctx, client, index, plan.uploads, opts)
+		synced = printTaskResults(progress, err)
+	}
+
+	// Only the modification time is out of date for these
+	for _, f := range plan.touched {
+		index.Files[f.name].FileModTime = &f.modTime
+	}
+
+	if len(plan.deletes) > 0 {
+		if synced {
+			synced = applySyncDeletes(ctx, client, index, plan.deletes)
+		} else {
+			// Like rsync, leave deletions alone after a failure
+			fmt.Println(color.YellowString("Deletions skipped because some uploads failed"))
+		}
+	}
+
+	if err := saveIndex(index); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to save index: %v\n", err)
+	}
+	if !synced {
+		os.Exit(1)
+	}
+	fmt.Printf("\n✓ %s is in sync with %s\n", remote, dir)
+}
+
+// applySyncDeletes removes deleted files from the index, deleting their
+// blobs on-chain when planned, and reports whether every one went
+func applySyncDeletes(ctx context.Context, client *backend.WalrusClient, index *FileIndex, deletes []syncDelete) bool {
+	// S3 transfers keep their own index, which may share chunks
+	simpleFS := backend.NewSimpleFs(client.AggregatorURL, client.PublisherURL)
+	if simpleFS.LoadIndex() != nil {
+		simpleFS = nil
+	}
+
+	removed, reclaimed := 0, 0
+	failures := make(map[string]error)
+	for _, d := range deletes {
+		if ctx.Err() != nil {
+			break
+		}
+		entry := index.Files[d.name]
+		if d.onChain {
+			parts, _ := unsharedParts(entry.Parts, entry.BlobID, index, simpleFS)
+			if err := deleteStoredBlob(ctx, client, entry.BlobID, entry.SuiObjectID, parts); err != nil {
+				failures[d.name] = err
+				continue
+			}
+			reclaimed++
+		}
+		delete(index.Files, d.name)
+		removed++
+	}
+
+	fmt.Printf("\n✓ Removed %d index entr%s (%d blob%s deleted on-chain)\n", removed, pluralY(removed), reclaimed, pluralS(reclaimed))
+	if len(failures) > 0 {
+		fmt.Println(color.RedString("\n❌ Failed to delete:"))
+		for _, name := range sortedKeys(failures) {
+			fmt.Printf("  • %s: %v\n", name, failures[name])
+		}
+	}
+	return removed == len(deletes)
+}
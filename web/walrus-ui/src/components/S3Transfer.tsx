@@ -16,7 +16,6 @@ import {
   XCircle,
   RefreshCw,
   Filter,
-  Shield,
   Eye,
   EyeOff,
   ExternalLink,
@@ -222,6 +221,40 @@ export function S3Transfer({ walrusConfig }: S3TransferProps) {
                 />
               </div>
 
+              <div className="space-y-2">
+                <label className="text-sm font-medium">Endpoint URL (Optional)</label>
+                <Input
+                  placeholder="For MinIO, R2, Wasabi or Ceph, e.g. http://localhost:9000"
+                  value={credentials.endpoint || ''}
+                  onChange={(e) => setCredentials({ ...credentials, endpoint: e.target.value })}
+                />
+              </div>
+
+              {credentials.endpoint && (
+                <div className="space-y-2">
+                  <div className="flex items-center space-x-2">
+                    <Checkbox
+                      id="path-style"
+                      checked={credentials.usePathStyle || false}
+                      onCheckedChange={(checked) => setCredentials({ ...credentials, usePathStyle: checked === true })}
+                    />
+                    <label htmlFor="path-style" className="text-sm font-medium leading-none">
+                      Path-style addressing
+                    </label>
+                  </div>
+                  <div className="flex items-center space-x-2">
+                    <Checkbox
+                      id="insecure-tls"
+                      checked={credentials.insecureSkipVerify || false}
+                      onCheckedChange={(checked) => setCredentials({ ...credentials, insecureSkipVerify: checked === true })}
+                    />
+                    <label htmlFor="insecure-tls" className="text-sm font-medium leading-none">
+                      Skip TLS certificate verification
+                    </label>
+                  </div>
+                </div>
+              )}
+
               {s3Error && (
                 <Alert variant="destructive">
                   <AlertCircle className="w-4 h-4" />
@@ -440,17 +473,6 @@ export function S3Transfer({ walrusConfig }: S3TransferProps) {
               </div>
 
               <div className="space-y-4">
-                <div className="flex items-center space-x-2">
-                  <Checkbox id="encrypt" />
-                  <label
-                    htmlFor="encrypt"
-                    className="text-sm font-medium leading-none peer-disabled:cursor-not-allowed peer-disabled:opacity-70"
-                  >
-                    <Shield className="w-4 h-4 inline mr-2" />
-                    Enable Seal encryption for transferred files
-                  </label>
-                </div>
-
                 <div className="flex items-center space-x-2">
                   <Checkbox id="parallel" defaultChecked />
                   <label
@@ -5,6 +5,9 @@ interface S3Credentials {
   secretAccessKey: string
   region: string
   sessionToken?: string
+  endpoint?: string
+  usePathStyle?: boolean
+  insecureSkipVerify?: boolean
 }
 
 interface S3ContextType {
@@ -5,6 +5,9 @@ interface S3Credentials {
   secretAccessKey: string
   region: string
   sessionToken?: string
+  endpoint?: string
+  usePathStyle?: boolean
+  insecureSkipVerify?: boolean
 }
 
 interface S3Object {
@@ -80,7 +83,10 @@ export function useS3Transfer(
             accessKeyID: credentials.accessKeyId,
             secretAccessKey: credentials.secretAccessKey,
             region: credentials.region,
-            sessionToken: credentials.sessionToken || ''
+            sessionToken: credentials.sessionToken || '',
+            endpoint: credentials.endpoint || '',
+            usePathStyle: credentials.usePathStyle || false,
+            insecureSkipVerify: credentials.insecureSkipVerify || false
           },
           ...data
         })
@@ -199,7 +205,10 @@ export function useS3Transfer(
             accessKeyID: credentials.accessKeyId,
             secretAccessKey: credentials.secretAccessKey,
             region: credentials.region,
-            sessionToken: credentials.sessionToken || ''
+            sessionToken: credentials.sessionToken || '',
+            endpoint: credentials.endpoint || '',
+            usePathStyle: credentials.usePathStyle || false,
+            insecureSkipVerify: credentials.insecureSkipVerify || false
           },
           bucket,
           keys: [key],